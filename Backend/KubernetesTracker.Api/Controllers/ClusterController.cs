@@ -45,6 +45,35 @@ public class clustersController : ControllerBase
         return Ok(cluster);
     }
 
+    [HttpGet("uid/{uid}")]
+    public async Task<ActionResult<ClusterResponseDto>> GetClusterByUid(string uid)
+    {
+        var cluster = await _clusterService.GetClusterByUidAsync(uid);
+        if (cluster == null)
+        {
+            return NotFound();
+        }
+
+        return Ok(cluster);
+    }
+
+    // e.g. addons/cert-manager?olderThan=1.12 lists the clusters still on an
+    // older cert-manager
+    [HttpGet("addons/{name}")]
+    public async Task<ActionResult<IEnumerable<AddonUsageDto>>> GetClustersByAddon(
+        string name, [FromQuery] string? version, [FromQuery] string? olderThan)
+    {
+        try
+        {
+            var addons = await _clusterService.GetClustersByAddonAsync(name, version, olderThan);
+            return Ok(addons);
+        }
+        catch (ArgumentException ex)
+        {
+            return BadRequest(ex.Message);
+        }
+    }
+
     [HttpPost]
     public async Task<ActionResult<ClusterResponseDto>> CreateCluster(ClusterCreateDto clusterDto)
     {
@@ -0,0 +1,106 @@
+using Microsoft.AspNetCore.Mvc;
+using Microsoft.EntityFrameworkCore;
+
+namespace KubernetesTracker.Api.Controllers;
+
+[ApiController]
+[Route("api/[controller]")]
+public class nodeController : ControllerBase
+{
+    private readonly INodeService _nodeService;
+
+    public nodeController(INodeService nodeService)
+    {
+        _nodeService = nodeService;
+    }
+
+    [HttpGet]
+    public async Task<ActionResult<IEnumerable<NodeResponseDto>>> GetNodes()
+    {
+        var nodes = await _nodeService.GetAllNodesAsync();
+        return Ok(nodes);
+    }
+
+    [HttpGet("cluster/{clusterName}")]
+    public async Task<ActionResult<IEnumerable<NodeResponseDto>>> GetNodesByCluster(string clusterName)
+    {
+        var nodes = await _nodeService.GetNodesByClusterAsync(clusterName);
+        return Ok(nodes);
+    }
+
+    [HttpGet("{id}")]
+    public async Task<ActionResult<NodeResponseDto>> GetNode(int id)
+    {
+        var node = await _nodeService.GetNodeAsync(id);
+        if (node == null)
+        {
+            return NotFound($"Node with ID {id} not found");
+        }
+
+        return Ok(node);
+    }
+
+    [HttpPost]
+    public async Task<ActionResult<NodeResponseDto>> CreateNode(NodeCreateDto nodeDto)
+    {
+        try
+        {
+            var node = await _nodeService.CreateNodeAsync(nodeDto);
+            return CreatedAtAction(nameof(GetNode), new { id = node.Id }, node);
+        }
+        catch (NotFoundException ex)
+        {
+            return NotFound(ex.Message);
+        }
+        catch (DbUpdateException ex)
+        {
+            return Conflict(ex.Message);
+        }
+    }
+
+    // upserts and deletes many records in one request, answering 207 with
+    // the outcome of each when some of them failed
+    [HttpPost("bulk")]
+    public async Task<ActionResult<BulkResponseDto>> BulkApplyNodes(BulkRequestDto<NodeCreateDto> request)
+    {
+        var response = await _nodeService.BulkApplyNodesAsync(request);
+        if (response.Results.Any(r => !r.Success))
+        {
+            return StatusCode(StatusCodes.Status207MultiStatus, response);
+        }
+
+        return Ok(response);
+    }
+
+    [HttpPut("{id}")]
+    public async Task<IActionResult> UpdateNode(int id, NodeCreateDto nodeDto)
+    {
+        try
+        {
+            var node = await _nodeService.UpdateNodeAsync(id, nodeDto);
+            return Ok(node);
+        }
+        catch (NotFoundException ex)
+        {
+            return NotFound(ex.Message);
+        }
+        catch (DbUpdateException ex)
+        {
+            return Conflict(ex.Message);
+        }
+    }
+
+    [HttpDelete("{id}")]
+    public async Task<IActionResult> DeleteNode(int id)
+    {
+        try
+        {
+            await _nodeService.DeleteNodeAsync(id);
+            return Ok();
+        }
+        catch (NotFoundException ex)
+        {
+            return NotFound(ex.Message);
+        }
+    }
+}
\ No newline at end of file
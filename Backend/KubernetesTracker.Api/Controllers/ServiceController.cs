@@ -58,6 +58,20 @@ public class serviceController : ControllerBase
         }
     }
 
+    // upserts and deletes many records in one request, answering 207 with
+    // the outcome of each when some of them failed
+    [HttpPost("bulk")]
+    public async Task<ActionResult<BulkResponseDto>> BulkApplyServices(BulkRequestDto<ServiceCreateDto> request)
+    {
+        var response = await _kubernetesService.BulkApplyServicesAsync(request);
+        if (response.Results.Any(r => !r.Success))
+        {
+            return StatusCode(StatusCodes.Status207MultiStatus, response);
+        }
+
+        return Ok(response);
+    }
+
     [HttpPut("{id}")]
     public async Task<IActionResult> UpdateService(int id, ServiceCreateDto serviceDto)
     {
@@ -0,0 +1,32 @@
+using Microsoft.AspNetCore.Mvc.ModelBinding.Validation;
+
+// Every change of one kind for a cluster in a single request. Upserts are
+// checked one by one when applied, so a bad item fails on its own instead of
+// failing the request.
+public class BulkRequestDto<T>
+{
+    public string ClusterName { get; set; } = null!;
+    [ValidateNever]
+    public List<T> Upserts { get; set; } = new();
+    public List<BulkDeleteDto> Deletes { get; set; } = new();
+}
+
+public class BulkDeleteDto
+{
+    public string? Namespace { get; set; }
+    public string Name { get; set; } = null!;
+}
+
+public class BulkResultDto
+{
+    public string? Namespace { get; set; }
+    public string Name { get; set; } = null!;
+    public string Operation { get; set; } = null!;
+    public bool Success { get; set; }
+    public string? Error { get; set; }
+}
+
+public class BulkResponseDto
+{
+    public List<BulkResultDto> Results { get; set; } = new();
+}
\ No newline at end of file
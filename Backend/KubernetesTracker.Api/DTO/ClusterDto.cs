@@ -1,20 +1,94 @@
 public class ClusterCreateDto
 {
     public string ClusterName { get; set; } = null!;
+    public string? ClusterUid { get; set; }
     public string ApiserverVersion { get; set; } = null!;
     public List<string> KubeletVersions { get; set; } = new();
     public List<string> KernelVersions { get; set; } = new();
+    public string Environment { get; set; } = "";
+    public string Distribution { get; set; } = "";
+    public string Provider { get; set; } = "";
+    public List<string> ContainerRuntimes { get; set; } = new();
+    public List<string> OsImages { get; set; } = new();
+    public List<string> Architectures { get; set; } = new();
+    public int NodeCount { get; set; }
+    public NodeResourcesDto TotalCapacity { get; set; } = new();
+    public NodeResourcesDto TotalAllocatable { get; set; } = new();
+    public List<ApiGroupDto> ApiGroups { get; set; } = new();
+    public List<AddonDto> Addons { get; set; } = new();
+    public UpgradeReadinessDto? UpgradeReadiness { get; set; }
 }
 
 public class ClusterResponseDto
 {
     public int Id { get; set; }
     public string ClusterName { get; set; } = null!;
+    public string? ClusterUid { get; set; }
     public string ApiserverVersion { get; set; } = null!;
     public List<string> KubeletVersions { get; set; } = new();
     public List<string> KernelVersions { get; set; } = new();
+    public string Environment { get; set; } = "";
+    public string Distribution { get; set; } = "";
+    public string Provider { get; set; } = "";
+    public List<string> ContainerRuntimes { get; set; } = new();
+    public List<string> OsImages { get; set; } = new();
+    public List<string> Architectures { get; set; } = new();
+    public int NodeCount { get; set; }
+    public NodeResourcesDto TotalCapacity { get; set; } = new();
+    public NodeResourcesDto TotalAllocatable { get; set; } = new();
+    public List<ApiGroupDto> ApiGroups { get; set; } = new();
+    public List<AddonDto> Addons { get; set; } = new();
+    public UpgradeReadinessDto? UpgradeReadiness { get; set; }
     public List<IngressResponseDto> Ingresses { get; set; } = new();
     public List<ServiceResponseDto> Services { get; set; } = new();
     public DateTime CreatedAt { get; set; }
     public DateTime UpdatedAt { get; set; }
+}
+
+public class ApiGroupDto
+{
+    public string Group { get; set; } = null!;
+    public List<string> Versions { get; set; } = new();
+    public string PreferredVersion { get; set; } = null!;
+    public string Source { get; set; } = null!;
+    public List<string> Crds { get; set; } = new();
+}
+
+public class AddonDto
+{
+    public string Name { get; set; } = null!;
+    public string Version { get; set; } = null!;
+    public string? Namespace { get; set; }
+    public string DetectedBy { get; set; } = null!;
+}
+
+public class UpgradeReadinessDto
+{
+    public string CurrentVersion { get; set; } = null!;
+    public string TargetVersion { get; set; } = null!;
+    public bool MetricsAvailable { get; set; }
+    public List<DeprecatedApiUsageDto> DeprecatedApis { get; set; } = new();
+    public List<DeprecatedApiUsageDto> Blockers { get; set; } = new();
+}
+
+public class DeprecatedApiUsageDto
+{
+    public string Group { get; set; } = null!;
+    public string Version { get; set; } = null!;
+    public string Resource { get; set; } = null!;
+    public string? DeprecatedIn { get; set; }
+    public string RemovedIn { get; set; } = null!;
+    public string? Replacement { get; set; }
+    public bool Served { get; set; }
+    public bool Requested { get; set; }
+}
+
+public class AddonUsageDto
+{
+    public int ClusterId { get; set; }
+    public string ClusterName { get; set; } = null!;
+    public string Name { get; set; } = null!;
+    public string Version { get; set; } = null!;
+    public string? Namespace { get; set; }
+    public string DetectedBy { get; set; } = null!;
 }
\ No newline at end of file
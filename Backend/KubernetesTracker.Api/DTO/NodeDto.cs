@@ -0,0 +1,56 @@
+public class NodeCreateDto
+{
+    public string ClusterName { get; set; } = null!;
+    public string NodeName { get; set; } = null!;
+    public List<string> Roles { get; set; } = new();
+    public string OsImage { get; set; } = null!;
+    public string OperatingSystem { get; set; } = null!;
+    public string Architecture { get; set; } = null!;
+    public string ContainerRuntimeVersion { get; set; } = null!;
+    public string KubeletVersion { get; set; } = null!;
+    public string KernelVersion { get; set; } = null!;
+    public string ProviderId { get; set; } = null!;
+    public string InstanceType { get; set; } = null!;
+    public string Zone { get; set; } = null!;
+    public string Region { get; set; } = null!;
+    public NodeResourcesDto Capacity { get; set; } = new();
+    public NodeResourcesDto Allocatable { get; set; } = new();
+    public List<NodeTaintDto> Taints { get; set; } = new();
+    public bool Ready { get; set; }
+}
+
+public class NodeResponseDto : BaseEntity
+{
+    public int Id { get; set; }
+    public string ClusterName { get; set; } = null!;
+    public string NodeName { get; set; } = null!;
+    public List<string> Roles { get; set; } = new();
+    public string OsImage { get; set; } = null!;
+    public string OperatingSystem { get; set; } = null!;
+    public string Architecture { get; set; } = null!;
+    public string ContainerRuntimeVersion { get; set; } = null!;
+    public string KubeletVersion { get; set; } = null!;
+    public string KernelVersion { get; set; } = null!;
+    public string ProviderId { get; set; } = null!;
+    public string InstanceType { get; set; } = null!;
+    public string Zone { get; set; } = null!;
+    public string Region { get; set; } = null!;
+    public NodeResourcesDto Capacity { get; set; } = new();
+    public NodeResourcesDto Allocatable { get; set; } = new();
+    public List<NodeTaintDto> Taints { get; set; } = new();
+    public bool Ready { get; set; }
+}
+
+public class NodeResourcesDto
+{
+    public long CpuMillicores { get; set; }
+    public long MemoryBytes { get; set; }
+    public long Pods { get; set; }
+}
+
+public class NodeTaintDto
+{
+    public string Key { get; set; } = null!;
+    public string Value { get; set; } = null!;
+    public string Effect { get; set; } = null!;
+}
\ No newline at end of file
@@ -6,6 +6,14 @@ public class ServiceCreateDto
     public string? ExternalIp { get; set; }
     public List<int> Ports { get; set; } = new();
     public string ServiceType { get; set; } = null!;
+    public List<string> ClusterIps { get; set; } = new();
+    public List<string> ExternalIps { get; set; } = new();
+    public List<string> LoadBalancerIps { get; set; } = new();
+    public List<string> LoadBalancerHostnames { get; set; } = new();
+    public string? ExternalName { get; set; }
+    public List<ServicePortDto> PortDetails { get; set; } = new();
+    public int ReadyEndpoints { get; set; }
+    public int NotReadyEndpoints { get; set; }
 }
 
 public class ServiceResponseDto : BaseEntity
@@ -17,4 +25,21 @@ public class ServiceResponseDto : BaseEntity
     public List<int> Ports { get; set; } = new();
     public string ClusterName { get; set; } = null!;
     public string ServiceType { get; set; } = null!;
+    public List<string> ClusterIps { get; set; } = new();
+    public List<string> ExternalIps { get; set; } = new();
+    public List<string> LoadBalancerIps { get; set; } = new();
+    public List<string> LoadBalancerHostnames { get; set; } = new();
+    public string? ExternalName { get; set; }
+    public List<ServicePortDto> PortDetails { get; set; } = new();
+    public int ReadyEndpoints { get; set; }
+    public int NotReadyEndpoints { get; set; }
+}
+
+public class ServicePortDto
+{
+    public string Name { get; set; } = null!;
+    public string Protocol { get; set; } = null!;
+    public int Port { get; set; }
+    public string TargetPort { get; set; } = null!;
+    public int? NodePort { get; set; }
 }
\ No newline at end of file
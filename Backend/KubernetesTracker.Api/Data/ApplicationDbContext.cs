@@ -10,11 +10,13 @@ namespace KubernetesTracker.Api.Data
             Clusters = Set<Cluster>();
             Ingresses = Set<Ingress>();
             Services = Set<Service>();
+            Nodes = Set<Node>();
         }
 
         public DbSet<Cluster> Clusters { get; set; } = null!;
         public DbSet<Ingress> Ingresses { get; set; } = null!;
         public DbSet<Service> Services { get; set; } = null!;
+        public DbSet<Node> Nodes { get; set; } = null!;
 
         protected override void OnModelCreating(ModelBuilder modelBuilder)
         {
@@ -25,11 +27,38 @@ namespace KubernetesTracker.Api.Data
                 entity.HasIndex(c => c.ClusterName)
                     .IsUnique();
 
+                entity.HasIndex(c => c.ClusterUid)
+                    .IsUnique();
+
                 entity.Property(c => c.KubeletVersions)
                     .HasColumnType("text[]");
 
                 entity.Property(c => c.KernelVersions)
                     .HasColumnType("text[]");
+
+                entity.Property(c => c.ContainerRuntimes)
+                    .HasColumnType("text[]");
+
+                entity.Property(c => c.OsImages)
+                    .HasColumnType("text[]");
+
+                entity.Property(c => c.Architectures)
+                    .HasColumnType("text[]");
+
+                entity.OwnsOne(c => c.TotalCapacity, r => r.ToJson());
+
+                entity.OwnsOne(c => c.TotalAllocatable, r => r.ToJson());
+
+                entity.OwnsMany(c => c.ApiGroups, g => g.ToJson());
+
+                entity.OwnsMany(c => c.Addons, a => a.ToJson());
+
+                entity.OwnsOne(c => c.UpgradeReadiness, r =>
+                {
+                    r.ToJson();
+                    r.OwnsMany(u => u.DeprecatedApis);
+                    r.OwnsMany(u => u.Blockers);
+                });
             });
 
             modelBuilder.Entity<Ingress>(entity =>
@@ -51,6 +80,35 @@ namespace KubernetesTracker.Api.Data
 
                 entity.Property(s => s.Ports)
                     .HasColumnType("integer[]");
+
+                entity.Property(s => s.ClusterIps)
+                    .HasColumnType("text[]");
+
+                entity.Property(s => s.ExternalIps)
+                    .HasColumnType("text[]");
+
+                entity.Property(s => s.LoadBalancerIps)
+                    .HasColumnType("text[]");
+
+                entity.Property(s => s.LoadBalancerHostnames)
+                    .HasColumnType("text[]");
+
+                entity.OwnsMany(s => s.PortDetails, p => p.ToJson());
+            });
+
+            modelBuilder.Entity<Node>(entity =>
+            {
+                entity.HasIndex(n => new { n.ClusterId, n.NodeName })
+                    .IsUnique();
+
+                entity.Property(n => n.Roles)
+                    .HasColumnType("text[]");
+
+                entity.OwnsOne(n => n.Capacity, r => r.ToJson());
+
+                entity.OwnsOne(n => n.Allocatable, r => r.ToJson());
+
+                entity.OwnsMany(n => n.Taints, t => t.ToJson());
             });
         }
 
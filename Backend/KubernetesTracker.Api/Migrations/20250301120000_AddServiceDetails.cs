@@ -0,0 +1,83 @@
+﻿using System.Collections.Generic;
+using Microsoft.EntityFrameworkCore.Migrations;
+
+#nullable disable
+
+namespace KubernetesTracker.Api.Migrations
+{
+    /// <inheritdoc />
+    public partial class AddServiceDetails : Migration
+    {
+        /// <inheritdoc />
+        protected override void Up(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.AddColumn<List<string>>(
+                name: "ClusterIps",
+                table: "Services",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<List<string>>(
+                name: "ExternalIps",
+                table: "Services",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<string>(
+                name: "ExternalName",
+                table: "Services",
+                type: "text",
+                nullable: true);
+
+            migrationBuilder.AddColumn<List<string>>(
+                name: "LoadBalancerHostnames",
+                table: "Services",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<List<string>>(
+                name: "LoadBalancerIps",
+                table: "Services",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<string>(
+                name: "PortDetails",
+                table: "Services",
+                type: "jsonb",
+                nullable: true);
+        }
+
+        /// <inheritdoc />
+        protected override void Down(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.DropColumn(
+                name: "ClusterIps",
+                table: "Services");
+
+            migrationBuilder.DropColumn(
+                name: "ExternalIps",
+                table: "Services");
+
+            migrationBuilder.DropColumn(
+                name: "ExternalName",
+                table: "Services");
+
+            migrationBuilder.DropColumn(
+                name: "LoadBalancerHostnames",
+                table: "Services");
+
+            migrationBuilder.DropColumn(
+                name: "LoadBalancerIps",
+                table: "Services");
+
+            migrationBuilder.DropColumn(
+                name: "PortDetails",
+                table: "Services");
+        }
+    }
+}
@@ -0,0 +1,40 @@
+﻿using Microsoft.EntityFrameworkCore.Migrations;
+
+#nullable disable
+
+namespace KubernetesTracker.Api.Migrations
+{
+    /// <inheritdoc />
+    public partial class AddServiceEndpoints : Migration
+    {
+        /// <inheritdoc />
+        protected override void Up(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.AddColumn<int>(
+                name: "NotReadyEndpoints",
+                table: "Services",
+                type: "integer",
+                nullable: false,
+                defaultValue: 0);
+
+            migrationBuilder.AddColumn<int>(
+                name: "ReadyEndpoints",
+                table: "Services",
+                type: "integer",
+                nullable: false,
+                defaultValue: 0);
+        }
+
+        /// <inheritdoc />
+        protected override void Down(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.DropColumn(
+                name: "NotReadyEndpoints",
+                table: "Services");
+
+            migrationBuilder.DropColumn(
+                name: "ReadyEndpoints",
+                table: "Services");
+        }
+    }
+}
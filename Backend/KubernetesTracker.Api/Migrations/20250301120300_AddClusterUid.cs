@@ -0,0 +1,38 @@
+﻿using Microsoft.EntityFrameworkCore.Migrations;
+
+#nullable disable
+
+namespace KubernetesTracker.Api.Migrations
+{
+    /// <inheritdoc />
+    public partial class AddClusterUid : Migration
+    {
+        /// <inheritdoc />
+        protected override void Up(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.AddColumn<string>(
+                name: "ClusterUid",
+                table: "Clusters",
+                type: "text",
+                nullable: true);
+
+            migrationBuilder.CreateIndex(
+                name: "IX_Clusters_ClusterUid",
+                table: "Clusters",
+                column: "ClusterUid",
+                unique: true);
+        }
+
+        /// <inheritdoc />
+        protected override void Down(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.DropIndex(
+                name: "IX_Clusters_ClusterUid",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "ClusterUid",
+                table: "Clusters");
+        }
+    }
+}
@@ -0,0 +1,485 @@
+﻿// <auto-generated />
+using System;
+using System.Collections.Generic;
+using KubernetesTracker.Api.Data;
+using Microsoft.EntityFrameworkCore;
+using Microsoft.EntityFrameworkCore.Infrastructure;
+using Microsoft.EntityFrameworkCore.Migrations;
+using Microsoft.EntityFrameworkCore.Storage.ValueConversion;
+using Npgsql.EntityFrameworkCore.PostgreSQL.Metadata;
+
+#nullable disable
+
+namespace KubernetesTracker.Api.Migrations
+{
+    [DbContext(typeof(ApplicationDbContext))]
+    [Migration("20250301120400_AddClusterDetails")]
+    partial class AddClusterDetails
+    {
+        /// <inheritdoc />
+        protected override void BuildTargetModel(ModelBuilder modelBuilder)
+        {
+#pragma warning disable 612, 618
+            modelBuilder
+                .HasAnnotation("ProductVersion", "9.0.0")
+                .HasAnnotation("Relational:MaxIdentifierLength", 63);
+
+            NpgsqlModelBuilderExtensions.UseIdentityByDefaultColumns(modelBuilder);
+
+            modelBuilder.Entity("Cluster", b =>
+                {
+                    b.Property<int>("Id")
+                        .ValueGeneratedOnAdd()
+                        .HasColumnType("integer");
+
+                    NpgsqlPropertyBuilderExtensions.UseIdentityByDefaultColumn(b.Property<int>("Id"));
+
+                    b.Property<string>("ApiserverVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("Architectures")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("ClusterName")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("ClusterUid")
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("ContainerRuntimes")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<DateTime>("CreatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<string>("Distribution")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("Environment")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("KernelVersions")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.PrimitiveCollection<List<string>>("KubeletVersions")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<int>("NodeCount")
+                        .HasColumnType("integer");
+
+                    b.PrimitiveCollection<List<string>>("OsImages")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("Provider")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<DateTime>("UpdatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.HasKey("Id");
+
+                    b.HasIndex("ClusterName")
+                        .IsUnique();
+
+                    b.HasIndex("ClusterUid")
+                        .IsUnique();
+
+                    b.ToTable("Clusters");
+                });
+
+            modelBuilder.Entity("Ingress", b =>
+                {
+                    b.Property<int>("Id")
+                        .ValueGeneratedOnAdd()
+                        .HasColumnType("integer");
+
+                    NpgsqlPropertyBuilderExtensions.UseIdentityByDefaultColumn(b.Property<int>("Id"));
+
+                    b.Property<int>("ClusterId")
+                        .HasColumnType("integer");
+
+                    b.Property<DateTime>("CreatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.PrimitiveCollection<List<string>>("Hosts")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("IngressName")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("Namespace")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<int>>("Ports")
+                        .IsRequired()
+                        .HasColumnType("integer[]");
+
+                    b.Property<DateTime>("UpdatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.HasKey("Id");
+
+                    b.HasIndex("ClusterId", "Namespace", "IngressName")
+                        .IsUnique();
+
+                    b.ToTable("Ingresses");
+                });
+
+            modelBuilder.Entity("Node", b =>
+                {
+                    b.Property<int>("Id")
+                        .ValueGeneratedOnAdd()
+                        .HasColumnType("integer");
+
+                    NpgsqlPropertyBuilderExtensions.UseIdentityByDefaultColumn(b.Property<int>("Id"));
+
+                    b.Property<string>("Architecture")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<int>("ClusterId")
+                        .HasColumnType("integer");
+
+                    b.Property<string>("ContainerRuntimeVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<DateTime>("CreatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<string>("InstanceType")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("KernelVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("KubeletVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("NodeName")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("OperatingSystem")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("OsImage")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("ProviderId")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<bool>("Ready")
+                        .HasColumnType("boolean");
+
+                    b.Property<string>("Region")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("Roles")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<DateTime>("UpdatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<string>("Zone")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.HasKey("Id");
+
+                    b.HasIndex("ClusterId", "NodeName")
+                        .IsUnique();
+
+                    b.ToTable("Nodes");
+                });
+
+            modelBuilder.Entity("Service", b =>
+                {
+                    b.Property<int>("Id")
+                        .ValueGeneratedOnAdd()
+                        .HasColumnType("integer");
+
+                    NpgsqlPropertyBuilderExtensions.UseIdentityByDefaultColumn(b.Property<int>("Id"));
+
+                    b.Property<int>("ClusterId")
+                        .HasColumnType("integer");
+
+                    b.PrimitiveCollection<List<string>>("ClusterIps")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<DateTime>("CreatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<string>("ExternalIp")
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("ExternalIps")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("ExternalName")
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("LoadBalancerHostnames")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.PrimitiveCollection<List<string>>("LoadBalancerIps")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("Namespace")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<int>("NotReadyEndpoints")
+                        .HasColumnType("integer");
+
+                    b.PrimitiveCollection<List<int>>("Ports")
+                        .IsRequired()
+                        .HasColumnType("integer[]");
+
+                    b.Property<int>("ReadyEndpoints")
+                        .HasColumnType("integer");
+
+                    b.Property<string>("ServiceName")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("ServiceType")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<DateTime>("UpdatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.HasKey("Id");
+
+                    b.HasIndex("ClusterId", "Namespace", "ServiceName")
+                        .IsUnique();
+
+                    b.ToTable("Services");
+                });
+
+            modelBuilder.Entity("Cluster", b =>
+                {
+                    b.OwnsOne("NodeResources", "TotalAllocatable", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("ClusterId");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("TotalAllocatable");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+                        });
+
+                    b.OwnsOne("NodeResources", "TotalCapacity", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("ClusterId");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("TotalCapacity");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+                        });
+
+                    b.Navigation("TotalAllocatable")
+                        .IsRequired();
+
+                    b.Navigation("TotalCapacity")
+                        .IsRequired();
+                });
+
+            modelBuilder.Entity("Ingress", b =>
+                {
+                    b.HasOne("Cluster", "Cluster")
+                        .WithMany("Ingresses")
+                        .HasForeignKey("ClusterId")
+                        .OnDelete(DeleteBehavior.Cascade)
+                        .IsRequired();
+
+                    b.Navigation("Cluster");
+                });
+
+            modelBuilder.Entity("Node", b =>
+                {
+                    b.HasOne("Cluster", "Cluster")
+                        .WithMany("Nodes")
+                        .HasForeignKey("ClusterId")
+                        .OnDelete(DeleteBehavior.Cascade)
+                        .IsRequired();
+
+                    b.OwnsOne("NodeResources", "Allocatable", b1 =>
+                        {
+                            b1.Property<int>("NodeId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("NodeId");
+
+                            b1.ToTable("Nodes");
+
+                            b1.ToJson("Allocatable");
+
+                            b1.WithOwner()
+                                .HasForeignKey("NodeId");
+                        });
+
+                    b.OwnsOne("NodeResources", "Capacity", b1 =>
+                        {
+                            b1.Property<int>("NodeId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("NodeId");
+
+                            b1.ToTable("Nodes");
+
+                            b1.ToJson("Capacity");
+
+                            b1.WithOwner()
+                                .HasForeignKey("NodeId");
+                        });
+
+                    b.OwnsMany("NodeTaint", "Taints", b1 =>
+                        {
+                            b1.Property<int>("NodeId");
+
+                            b1.Property<int>("__synthesizedOrdinal")
+                                .ValueGeneratedOnAdd();
+
+                            b1.Property<string>("Effect")
+                                .IsRequired();
+
+                            b1.Property<string>("Key")
+                                .IsRequired();
+
+                            b1.Property<string>("Value")
+                                .IsRequired();
+
+                            b1.HasKey("NodeId", "__synthesizedOrdinal");
+
+                            b1.ToTable("Nodes");
+
+                            b1.ToJson("Taints");
+
+                            b1.WithOwner()
+                                .HasForeignKey("NodeId");
+                        });
+
+                    b.Navigation("Allocatable")
+                        .IsRequired();
+
+                    b.Navigation("Capacity")
+                        .IsRequired();
+
+                    b.Navigation("Cluster");
+
+                    b.Navigation("Taints");
+                });
+
+            modelBuilder.Entity("Service", b =>
+                {
+                    b.HasOne("Cluster", "Cluster")
+                        .WithMany("Services")
+                        .HasForeignKey("ClusterId")
+                        .OnDelete(DeleteBehavior.Cascade)
+                        .IsRequired();
+
+                    b.OwnsMany("ServicePort", "PortDetails", b1 =>
+                        {
+                            b1.Property<int>("ServiceId");
+
+                            b1.Property<int>("__synthesizedOrdinal")
+                                .ValueGeneratedOnAdd();
+
+                            b1.Property<string>("Name")
+                                .IsRequired();
+
+                            b1.Property<int?>("NodePort");
+
+                            b1.Property<int>("Port");
+
+                            b1.Property<string>("Protocol")
+                                .IsRequired();
+
+                            b1.Property<string>("TargetPort")
+                                .IsRequired();
+
+                            b1.HasKey("ServiceId", "__synthesizedOrdinal");
+
+                            b1.ToTable("Services");
+
+                            b1.ToJson("PortDetails");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ServiceId");
+                        });
+
+                    b.Navigation("Cluster");
+
+                    b.Navigation("PortDetails");
+                });
+
+            modelBuilder.Entity("Cluster", b =>
+                {
+                    b.Navigation("Ingresses");
+
+                    b.Navigation("Nodes");
+
+                    b.Navigation("Services");
+                });
+#pragma warning restore 612, 618
+        }
+    }
+}
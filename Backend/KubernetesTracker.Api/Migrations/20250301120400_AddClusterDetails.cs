@@ -0,0 +1,118 @@
+﻿using System.Collections.Generic;
+using Microsoft.EntityFrameworkCore.Migrations;
+
+#nullable disable
+
+namespace KubernetesTracker.Api.Migrations
+{
+    /// <inheritdoc />
+    public partial class AddClusterDetails : Migration
+    {
+        /// <inheritdoc />
+        protected override void Up(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.AddColumn<List<string>>(
+                name: "Architectures",
+                table: "Clusters",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<List<string>>(
+                name: "ContainerRuntimes",
+                table: "Clusters",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<string>(
+                name: "Distribution",
+                table: "Clusters",
+                type: "text",
+                nullable: false,
+                defaultValue: "");
+
+            migrationBuilder.AddColumn<string>(
+                name: "Environment",
+                table: "Clusters",
+                type: "text",
+                nullable: false,
+                defaultValue: "");
+
+            migrationBuilder.AddColumn<int>(
+                name: "NodeCount",
+                table: "Clusters",
+                type: "integer",
+                nullable: false,
+                defaultValue: 0);
+
+            migrationBuilder.AddColumn<List<string>>(
+                name: "OsImages",
+                table: "Clusters",
+                type: "text[]",
+                nullable: false,
+                defaultValue: new List<string>());
+
+            migrationBuilder.AddColumn<string>(
+                name: "Provider",
+                table: "Clusters",
+                type: "text",
+                nullable: false,
+                defaultValue: "");
+
+            migrationBuilder.AddColumn<string>(
+                name: "TotalAllocatable",
+                table: "Clusters",
+                type: "jsonb",
+                nullable: false,
+                defaultValue: "{}");
+
+            migrationBuilder.AddColumn<string>(
+                name: "TotalCapacity",
+                table: "Clusters",
+                type: "jsonb",
+                nullable: false,
+                defaultValue: "{}");
+        }
+
+        /// <inheritdoc />
+        protected override void Down(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.DropColumn(
+                name: "Architectures",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "ContainerRuntimes",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "Distribution",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "Environment",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "NodeCount",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "OsImages",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "Provider",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "TotalAllocatable",
+                table: "Clusters");
+
+            migrationBuilder.DropColumn(
+                name: "TotalCapacity",
+                table: "Clusters");
+        }
+    }
+}
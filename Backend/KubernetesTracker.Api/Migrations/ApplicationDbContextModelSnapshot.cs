@@ -35,13 +35,32 @@ namespace KubernetesTracker.Api.Migrations
                         .IsRequired()
                         .HasColumnType("text");
 
+                    b.PrimitiveCollection<List<string>>("Architectures")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
                     b.Property<string>("ClusterName")
                         .IsRequired()
                         .HasColumnType("text");
 
+                    b.Property<string>("ClusterUid")
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("ContainerRuntimes")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
                     b.Property<DateTime>("CreatedAt")
                         .HasColumnType("timestamp with time zone");
 
+                    b.Property<string>("Distribution")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("Environment")
+                        .IsRequired()
+                        .HasColumnType("text");
+
                     b.PrimitiveCollection<List<string>>("KernelVersions")
                         .IsRequired()
                         .HasColumnType("text[]");
@@ -50,6 +69,17 @@ namespace KubernetesTracker.Api.Migrations
                         .IsRequired()
                         .HasColumnType("text[]");
 
+                    b.Property<int>("NodeCount")
+                        .HasColumnType("integer");
+
+                    b.PrimitiveCollection<List<string>>("OsImages")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("Provider")
+                        .IsRequired()
+                        .HasColumnType("text");
+
                     b.Property<DateTime>("UpdatedAt")
                         .HasColumnType("timestamp with time zone");
 
@@ -58,6 +88,9 @@ namespace KubernetesTracker.Api.Migrations
                     b.HasIndex("ClusterName")
                         .IsUnique();
 
+                    b.HasIndex("ClusterUid")
+                        .IsUnique();
+
                     b.ToTable("Clusters");
                 });
 
@@ -102,6 +135,82 @@ namespace KubernetesTracker.Api.Migrations
                     b.ToTable("Ingresses");
                 });
 
+            modelBuilder.Entity("Node", b =>
+                {
+                    b.Property<int>("Id")
+                        .ValueGeneratedOnAdd()
+                        .HasColumnType("integer");
+
+                    NpgsqlPropertyBuilderExtensions.UseIdentityByDefaultColumn(b.Property<int>("Id"));
+
+                    b.Property<string>("Architecture")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<int>("ClusterId")
+                        .HasColumnType("integer");
+
+                    b.Property<string>("ContainerRuntimeVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<DateTime>("CreatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<string>("InstanceType")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("KernelVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("KubeletVersion")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("NodeName")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("OperatingSystem")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("OsImage")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<string>("ProviderId")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.Property<bool>("Ready")
+                        .HasColumnType("boolean");
+
+                    b.Property<string>("Region")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("Roles")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<DateTime>("UpdatedAt")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<string>("Zone")
+                        .IsRequired()
+                        .HasColumnType("text");
+
+                    b.HasKey("Id");
+
+                    b.HasIndex("ClusterId", "NodeName")
+                        .IsUnique();
+
+                    b.ToTable("Nodes");
+                });
+
             modelBuilder.Entity("Service", b =>
                 {
                     b.Property<int>("Id")
@@ -113,20 +222,45 @@ namespace KubernetesTracker.Api.Migrations
                     b.Property<int>("ClusterId")
                         .HasColumnType("integer");
 
+                    b.PrimitiveCollection<List<string>>("ClusterIps")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
                     b.Property<DateTime>("CreatedAt")
                         .HasColumnType("timestamp with time zone");
 
                     b.Property<string>("ExternalIp")
                         .HasColumnType("text");
 
+                    b.PrimitiveCollection<List<string>>("ExternalIps")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.Property<string>("ExternalName")
+                        .HasColumnType("text");
+
+                    b.PrimitiveCollection<List<string>>("LoadBalancerHostnames")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
+                    b.PrimitiveCollection<List<string>>("LoadBalancerIps")
+                        .IsRequired()
+                        .HasColumnType("text[]");
+
                     b.Property<string>("Namespace")
                         .IsRequired()
                         .HasColumnType("text");
 
+                    b.Property<int>("NotReadyEndpoints")
+                        .HasColumnType("integer");
+
                     b.PrimitiveCollection<List<int>>("Ports")
                         .IsRequired()
                         .HasColumnType("integer[]");
 
+                    b.Property<int>("ReadyEndpoints")
+                        .HasColumnType("integer");
+
                     b.Property<string>("ServiceName")
                         .IsRequired()
                         .HasColumnType("text");
@@ -146,6 +280,217 @@ namespace KubernetesTracker.Api.Migrations
                     b.ToTable("Services");
                 });
 
+            modelBuilder.Entity("Cluster", b =>
+                {
+                    b.OwnsMany("Addon", "Addons", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<int>("__synthesizedOrdinal")
+                                .ValueGeneratedOnAdd();
+
+                            b1.Property<string>("DetectedBy")
+                                .IsRequired();
+
+                            b1.Property<string>("Name")
+                                .IsRequired();
+
+                            b1.Property<string>("Namespace");
+
+                            b1.Property<string>("Version")
+                                .IsRequired();
+
+                            b1.HasKey("ClusterId", "__synthesizedOrdinal");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("Addons");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+                        });
+
+                    b.OwnsMany("ApiGroup", "ApiGroups", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<int>("__synthesizedOrdinal")
+                                .ValueGeneratedOnAdd();
+
+                            b1.PrimitiveCollection<List<string>>("Crds")
+                                .IsRequired();
+
+                            b1.Property<string>("Group")
+                                .IsRequired();
+
+                            b1.Property<string>("PreferredVersion")
+                                .IsRequired();
+
+                            b1.Property<string>("Source")
+                                .IsRequired();
+
+                            b1.PrimitiveCollection<List<string>>("Versions")
+                                .IsRequired();
+
+                            b1.HasKey("ClusterId", "__synthesizedOrdinal");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("ApiGroups");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+                        });
+
+                    b.OwnsOne("NodeResources", "TotalAllocatable", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("ClusterId");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("TotalAllocatable");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+                        });
+
+                    b.OwnsOne("NodeResources", "TotalCapacity", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("ClusterId");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("TotalCapacity");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+                        });
+
+                    b.OwnsOne("UpgradeReadiness", "UpgradeReadiness", b1 =>
+                        {
+                            b1.Property<int>("ClusterId");
+
+                            b1.Property<string>("CurrentVersion")
+                                .IsRequired();
+
+                            b1.Property<bool>("MetricsAvailable");
+
+                            b1.Property<string>("TargetVersion")
+                                .IsRequired();
+
+                            b1.HasKey("ClusterId");
+
+                            b1.ToTable("Clusters");
+
+                            b1.ToJson("UpgradeReadiness");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ClusterId");
+
+                            b1.OwnsMany("DeprecatedApiUsage", "Blockers", b2 =>
+                                {
+                                    b2.Property<int>("UpgradeReadinessClusterId");
+
+                                    b2.Property<int>("__synthesizedOrdinal")
+                                        .ValueGeneratedOnAdd();
+
+                                    b2.Property<string>("DeprecatedIn");
+
+                                    b2.Property<string>("Group")
+                                        .IsRequired();
+
+                                    b2.Property<string>("RemovedIn")
+                                        .IsRequired();
+
+                                    b2.Property<string>("Replacement");
+
+                                    b2.Property<bool>("Requested");
+
+                                    b2.Property<string>("Resource")
+                                        .IsRequired();
+
+                                    b2.Property<bool>("Served");
+
+                                    b2.Property<string>("Version")
+                                        .IsRequired();
+
+                                    b2.HasKey("UpgradeReadinessClusterId", "__synthesizedOrdinal");
+
+                                    b2.ToTable("Clusters");
+
+                                    b2.WithOwner()
+                                        .HasForeignKey("UpgradeReadinessClusterId");
+                                });
+
+                            b1.OwnsMany("DeprecatedApiUsage", "DeprecatedApis", b2 =>
+                                {
+                                    b2.Property<int>("UpgradeReadinessClusterId");
+
+                                    b2.Property<int>("__synthesizedOrdinal")
+                                        .ValueGeneratedOnAdd();
+
+                                    b2.Property<string>("DeprecatedIn");
+
+                                    b2.Property<string>("Group")
+                                        .IsRequired();
+
+                                    b2.Property<string>("RemovedIn")
+                                        .IsRequired();
+
+                                    b2.Property<string>("Replacement");
+
+                                    b2.Property<bool>("Requested");
+
+                                    b2.Property<string>("Resource")
+                                        .IsRequired();
+
+                                    b2.Property<bool>("Served");
+
+                                    b2.Property<string>("Version")
+                                        .IsRequired();
+
+                                    b2.HasKey("UpgradeReadinessClusterId", "__synthesizedOrdinal");
+
+                                    b2.ToTable("Clusters");
+
+                                    b2.WithOwner()
+                                        .HasForeignKey("UpgradeReadinessClusterId");
+                                });
+
+                            b1.Navigation("Blockers");
+
+                            b1.Navigation("DeprecatedApis");
+                        });
+
+                    b.Navigation("Addons");
+
+                    b.Navigation("ApiGroups");
+
+                    b.Navigation("TotalAllocatable")
+                        .IsRequired();
+
+                    b.Navigation("TotalCapacity")
+                        .IsRequired();
+
+                    b.Navigation("UpgradeReadiness");
+                });
+
             modelBuilder.Entity("Ingress", b =>
                 {
                     b.HasOne("Cluster", "Cluster")
@@ -157,6 +502,91 @@ namespace KubernetesTracker.Api.Migrations
                     b.Navigation("Cluster");
                 });
 
+            modelBuilder.Entity("Node", b =>
+                {
+                    b.HasOne("Cluster", "Cluster")
+                        .WithMany("Nodes")
+                        .HasForeignKey("ClusterId")
+                        .OnDelete(DeleteBehavior.Cascade)
+                        .IsRequired();
+
+                    b.OwnsOne("NodeResources", "Allocatable", b1 =>
+                        {
+                            b1.Property<int>("NodeId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("NodeId");
+
+                            b1.ToTable("Nodes");
+
+                            b1.ToJson("Allocatable");
+
+                            b1.WithOwner()
+                                .HasForeignKey("NodeId");
+                        });
+
+                    b.OwnsOne("NodeResources", "Capacity", b1 =>
+                        {
+                            b1.Property<int>("NodeId");
+
+                            b1.Property<long>("CpuMillicores");
+
+                            b1.Property<long>("MemoryBytes");
+
+                            b1.Property<long>("Pods");
+
+                            b1.HasKey("NodeId");
+
+                            b1.ToTable("Nodes");
+
+                            b1.ToJson("Capacity");
+
+                            b1.WithOwner()
+                                .HasForeignKey("NodeId");
+                        });
+
+                    b.OwnsMany("NodeTaint", "Taints", b1 =>
+                        {
+                            b1.Property<int>("NodeId");
+
+                            b1.Property<int>("__synthesizedOrdinal")
+                                .ValueGeneratedOnAdd();
+
+                            b1.Property<string>("Effect")
+                                .IsRequired();
+
+                            b1.Property<string>("Key")
+                                .IsRequired();
+
+                            b1.Property<string>("Value")
+                                .IsRequired();
+
+                            b1.HasKey("NodeId", "__synthesizedOrdinal");
+
+                            b1.ToTable("Nodes");
+
+                            b1.ToJson("Taints");
+
+                            b1.WithOwner()
+                                .HasForeignKey("NodeId");
+                        });
+
+                    b.Navigation("Allocatable")
+                        .IsRequired();
+
+                    b.Navigation("Capacity")
+                        .IsRequired();
+
+                    b.Navigation("Cluster");
+
+                    b.Navigation("Taints");
+                });
+
             modelBuilder.Entity("Service", b =>
                 {
                     b.HasOne("Cluster", "Cluster")
@@ -165,13 +595,47 @@ namespace KubernetesTracker.Api.Migrations
                         .OnDelete(DeleteBehavior.Cascade)
                         .IsRequired();
 
+                    b.OwnsMany("ServicePort", "PortDetails", b1 =>
+                        {
+                            b1.Property<int>("ServiceId");
+
+                            b1.Property<int>("__synthesizedOrdinal")
+                                .ValueGeneratedOnAdd();
+
+                            b1.Property<string>("Name")
+                                .IsRequired();
+
+                            b1.Property<int?>("NodePort");
+
+                            b1.Property<int>("Port");
+
+                            b1.Property<string>("Protocol")
+                                .IsRequired();
+
+                            b1.Property<string>("TargetPort")
+                                .IsRequired();
+
+                            b1.HasKey("ServiceId", "__synthesizedOrdinal");
+
+                            b1.ToTable("Services");
+
+                            b1.ToJson("PortDetails");
+
+                            b1.WithOwner()
+                                .HasForeignKey("ServiceId");
+                        });
+
                     b.Navigation("Cluster");
+
+                    b.Navigation("PortDetails");
                 });
 
             modelBuilder.Entity("Cluster", b =>
                 {
                     b.Navigation("Ingresses");
 
+                    b.Navigation("Nodes");
+
                     b.Navigation("Services");
                 });
 #pragma warning restore 612, 618
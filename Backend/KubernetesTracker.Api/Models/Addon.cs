@@ -0,0 +1,7 @@
+public class Addon
+{
+    public string Name { get; set; } = null!;
+    public string Version { get; set; } = null!;
+    public string? Namespace { get; set; }
+    public string DetectedBy { get; set; } = null!;
+}
\ No newline at end of file
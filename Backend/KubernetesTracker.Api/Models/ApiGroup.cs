@@ -0,0 +1,8 @@
+public class ApiGroup
+{
+    public string Group { get; set; } = null!;
+    public List<string> Versions { get; set; } = new();
+    public string PreferredVersion { get; set; } = null!;
+    public string Source { get; set; } = null!;
+    public List<string> Crds { get; set; } = new();
+}
\ No newline at end of file
@@ -2,9 +2,23 @@ public class Cluster : BaseEntity
 {
     public int Id { get; set; }
     public string ClusterName { get; set; } = null!;
+    public string? ClusterUid { get; set; }
     public string ApiserverVersion { get; set; } = null!;
     public List<string> KubeletVersions { get; set; } = new();
     public List<string> KernelVersions { get; set; } = new();
+    public string Environment { get; set; } = "";
+    public string Distribution { get; set; } = "";
+    public string Provider { get; set; } = "";
+    public List<string> ContainerRuntimes { get; set; } = new();
+    public List<string> OsImages { get; set; } = new();
+    public List<string> Architectures { get; set; } = new();
+    public int NodeCount { get; set; }
+    public NodeResources TotalCapacity { get; set; } = new();
+    public NodeResources TotalAllocatable { get; set; } = new();
+    public List<ApiGroup> ApiGroups { get; set; } = new();
+    public List<Addon> Addons { get; set; } = new();
+    public UpgradeReadiness? UpgradeReadiness { get; set; }
     public ICollection<Ingress> Ingresses { get; set; } = new List<Ingress>();
     public ICollection<Service> Services { get; set; } = new List<Service>();
+    public ICollection<Node> Nodes { get; set; } = new List<Node>();
 }
\ No newline at end of file
@@ -0,0 +1,11 @@
+public class DeprecatedApiUsage
+{
+    public string Group { get; set; } = null!;
+    public string Version { get; set; } = null!;
+    public string Resource { get; set; } = null!;
+    public string? DeprecatedIn { get; set; }
+    public string RemovedIn { get; set; } = null!;
+    public string? Replacement { get; set; }
+    public bool Served { get; set; }
+    public bool Requested { get; set; }
+}
\ No newline at end of file
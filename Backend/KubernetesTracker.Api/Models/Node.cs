@@ -0,0 +1,22 @@
+public class Node : BaseEntity
+{
+    public int Id { get; set; }
+    public int ClusterId { get; set; }
+    public string NodeName { get; set; } = null!;
+    public List<string> Roles { get; set; } = new();
+    public string OsImage { get; set; } = null!;
+    public string OperatingSystem { get; set; } = null!;
+    public string Architecture { get; set; } = null!;
+    public string ContainerRuntimeVersion { get; set; } = null!;
+    public string KubeletVersion { get; set; } = null!;
+    public string KernelVersion { get; set; } = null!;
+    public string ProviderId { get; set; } = null!;
+    public string InstanceType { get; set; } = null!;
+    public string Zone { get; set; } = null!;
+    public string Region { get; set; } = null!;
+    public NodeResources Capacity { get; set; } = new();
+    public NodeResources Allocatable { get; set; } = new();
+    public List<NodeTaint> Taints { get; set; } = new();
+    public bool Ready { get; set; }
+    public Cluster Cluster { get; set; } = null!;
+}
\ No newline at end of file
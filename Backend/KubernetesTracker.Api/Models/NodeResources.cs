@@ -0,0 +1,6 @@
+public class NodeResources
+{
+    public long CpuMillicores { get; set; }
+    public long MemoryBytes { get; set; }
+    public long Pods { get; set; }
+}
\ No newline at end of file
@@ -0,0 +1,6 @@
+public class NodeTaint
+{
+    public string Key { get; set; } = null!;
+    public string Value { get; set; } = null!;
+    public string Effect { get; set; } = null!;
+}
\ No newline at end of file
@@ -7,5 +7,13 @@ public class Service : BaseEntity
     public string? ExternalIp { get; set; }
     public List<int> Ports { get; set; } = new();
     public string ServiceType { get; set; } = null!;
+    public List<string> ClusterIps { get; set; } = new();
+    public List<string> ExternalIps { get; set; } = new();
+    public List<string> LoadBalancerIps { get; set; } = new();
+    public List<string> LoadBalancerHostnames { get; set; } = new();
+    public string? ExternalName { get; set; }
+    public List<ServicePort> PortDetails { get; set; } = new();
+    public int ReadyEndpoints { get; set; }
+    public int NotReadyEndpoints { get; set; }
     public Cluster Cluster { get; set; } = null!;
 }
\ No newline at end of file
@@ -0,0 +1,8 @@
+public class ServicePort
+{
+    public string Name { get; set; } = null!;
+    public string Protocol { get; set; } = null!;
+    public int Port { get; set; }
+    public string TargetPort { get; set; } = null!;
+    public int? NodePort { get; set; }
+}
\ No newline at end of file
@@ -0,0 +1,8 @@
+public class UpgradeReadiness
+{
+    public string CurrentVersion { get; set; } = null!;
+    public string TargetVersion { get; set; } = null!;
+    public bool MetricsAvailable { get; set; }
+    public List<DeprecatedApiUsage> DeprecatedApis { get; set; } = new();
+    public List<DeprecatedApiUsage> Blockers { get; set; } = new();
+}
\ No newline at end of file
@@ -45,6 +45,7 @@ builder.WebHost.ConfigureKestrel(serverOptions =>
 builder.Services.AddScoped<IClusterService, ClusterService>();
 builder.Services.AddScoped<IIngressService, IngressService>();
 builder.Services.AddScoped<IKubernetesService, KubernetesService>();
+builder.Services.AddScoped<INodeService, NodeService>();
 
 var app = builder.Build();
 
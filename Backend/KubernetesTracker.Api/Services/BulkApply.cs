@@ -0,0 +1,127 @@
+using System.Reflection;
+using KubernetesTracker.Api.Data;
+using Microsoft.EntityFrameworkCore;
+
+// Applies the upserts and deletes of a bulk request to the records of one
+// cluster with a single save. Items that can't be applied fail on their own;
+// a failed save fails every item it carried.
+public static class BulkApply
+{
+    public const string OperationUpsert = "upsert";
+    public const string OperationDelete = "delete";
+
+    public static async Task<BulkResponseDto> ApplyAsync<TEntity, TDto>(
+        ApplicationDbContext context,
+        BulkRequestDto<TDto> request,
+        Func<int, IQueryable<TEntity>> recordsOfCluster,
+        Func<TEntity, (string? Namespace, string Name)> recordKey,
+        Func<TDto, (string? Namespace, string Name)> dtoKey,
+        Func<Cluster, TEntity> create,
+        Action<TEntity, TDto> copy)
+        where TEntity : class
+        where TDto : class
+    {
+        var response = new BulkResponseDto();
+
+        var cluster = await context.Clusters
+            .FirstOrDefaultAsync(c => c.ClusterName == request.ClusterName);
+
+        if (cluster == null)
+        {
+            foreach (var dto in request.Upserts)
+            {
+                var (ns, name) = dtoKey(dto);
+                response.Results.Add(Failed(ns, name, OperationUpsert, $"Cluster '{request.ClusterName}' not found"));
+            }
+            foreach (var delete in request.Deletes)
+            {
+                response.Results.Add(Failed(delete.Namespace, delete.Name, OperationDelete, $"Cluster '{request.ClusterName}' not found"));
+            }
+            return response;
+        }
+
+        var records = (await recordsOfCluster(cluster.Id).ToListAsync())
+            .ToDictionary(r => Key(recordKey(r)));
+
+        foreach (var dto in request.Upserts)
+        {
+            var (ns, name) = dtoKey(dto);
+            var error = Validate(dto, name);
+            if (error != null)
+            {
+                response.Results.Add(Failed(ns, name, OperationUpsert, error));
+                continue;
+            }
+
+            var key = Key((ns, name));
+            if (!records.TryGetValue(key, out var record))
+            {
+                record = create(cluster);
+                context.Add(record);
+                records[key] = record;
+            }
+            copy(record, dto);
+            response.Results.Add(new BulkResultDto { Namespace = ns, Name = name, Operation = OperationUpsert, Success = true });
+        }
+
+        // deleting what isn't there succeeds, the record is gone either way
+        foreach (var delete in request.Deletes)
+        {
+            if (records.Remove(Key((delete.Namespace, delete.Name)), out var record))
+            {
+                context.Remove(record);
+            }
+            response.Results.Add(new BulkResultDto { Namespace = delete.Namespace, Name = delete.Name, Operation = OperationDelete, Success = true });
+        }
+
+        try
+        {
+            await context.SaveChangesAsync();
+        }
+        catch (DbUpdateException ex)
+        {
+            context.ChangeTracker.Clear();
+            foreach (var result in response.Results.Where(r => r.Success))
+            {
+                result.Success = false;
+                result.Error = ex.InnerException?.Message ?? ex.Message;
+            }
+        }
+
+        return response;
+    }
+
+    private static string Key((string? Namespace, string Name) key) =>
+        string.IsNullOrEmpty(key.Namespace) ? key.Name : $"{key.Namespace}/{key.Name}";
+
+    private static BulkResultDto Failed(string? ns, string name, string operation, string error) => new()
+    {
+        Namespace = ns,
+        Name = name,
+        Operation = operation,
+        Success = false,
+        Error = error
+    };
+
+    // the checks the API applies to a single create: a name, and every
+    // non-nullable string field present
+    private static string? Validate(object dto, string name)
+    {
+        if (string.IsNullOrEmpty(name))
+        {
+            return "name is required";
+        }
+
+        var nullability = new NullabilityInfoContext();
+        foreach (var property in dto.GetType().GetProperties())
+        {
+            if (property.PropertyType == typeof(string) &&
+                property.GetValue(dto) == null &&
+                nullability.Create(property).WriteState == NullabilityState.NotNull)
+            {
+                return $"{property.Name} is required";
+            }
+        }
+        return null;
+    }
+}
\ No newline at end of file
@@ -1,3 +1,4 @@
+using System.Diagnostics.CodeAnalysis;
 using KubernetesTracker.Api.Data;
 using Microsoft.EntityFrameworkCore;
 
@@ -43,6 +44,51 @@ public class ClusterService : IClusterService
         return cluster == null ? null : ToResponseDto(cluster);
     }
 
+    public async Task<ClusterResponseDto?> GetClusterByUidAsync(string uid)
+    {
+        var cluster = await _context.Clusters
+            .Include(c => c.Ingresses)
+            .Include(c => c.Services)
+            .AsSplitQuery()
+            .FirstOrDefaultAsync(c => c.ClusterUid == uid);
+
+        return cluster == null ? null : ToResponseDto(cluster);
+    }
+
+    public async Task<IEnumerable<AddonUsageDto>> GetClustersByAddonAsync(string name, string? version, string? olderThan)
+    {
+        Version? threshold = null;
+        if (olderThan != null && !TryParseAddonVersion(olderThan, out threshold))
+        {
+            throw new ArgumentException($"'{olderThan}' is not a version");
+        }
+
+        var clusters = await _context.Clusters
+            .Where(c => c.Addons.Any(a => a.Name == name))
+            .OrderBy(c => c.ClusterName)
+            .ToListAsync();
+
+        // versions are compared here since add-ons tag them inconsistently
+        // (v1.14.4, 1.14.4-rc.1, ...), which the database can't order
+
+        return clusters
+            .SelectMany(c => c.Addons
+                .Where(a => a.Name == name)
+                .Select(a => new AddonUsageDto
+                {
+                    ClusterId = c.Id,
+                    ClusterName = c.ClusterName,
+                    Name = a.Name,
+                    Version = a.Version,
+                    Namespace = a.Namespace,
+                    DetectedBy = a.DetectedBy
+                }))
+            .Where(a => version == null || a.Version == version)
+            .Where(a => threshold == null ||
+                (TryParseAddonVersion(a.Version, out var v) && v < threshold))
+            .ToList();
+    }
+
     public async Task<ClusterResponseDto> CreateClusterAsync(ClusterCreateDto clusterDto)
     {
         var existingCluster = await _context.Clusters
@@ -55,12 +101,33 @@ public class ClusterService : IClusterService
                 new Exception("Unique constraint violation"));
         }
 
+        if (clusterDto.ClusterUid != null &&
+            await _context.Clusters.AnyAsync(c => c.ClusterUid == clusterDto.ClusterUid))
+        {
+            throw new DbUpdateException(
+                $"A cluster with uid '{clusterDto.ClusterUid}' already exists",
+                new Exception("Unique constraint violation"));
+        }
+
         var cluster = new Cluster
         {
             ClusterName = clusterDto.ClusterName,
+            ClusterUid = clusterDto.ClusterUid,
             ApiserverVersion = clusterDto.ApiserverVersion,
             KubeletVersions = clusterDto.KubeletVersions.Distinct().ToList(),
             KernelVersions = clusterDto.KernelVersions.Distinct().ToList(),
+            Environment = clusterDto.Environment,
+            Distribution = clusterDto.Distribution,
+            Provider = clusterDto.Provider,
+            ContainerRuntimes = clusterDto.ContainerRuntimes.Distinct().ToList(),
+            OsImages = clusterDto.OsImages.Distinct().ToList(),
+            Architectures = clusterDto.Architectures.Distinct().ToList(),
+            NodeCount = clusterDto.NodeCount,
+            TotalCapacity = ToNodeResources(clusterDto.TotalCapacity),
+            TotalAllocatable = ToNodeResources(clusterDto.TotalAllocatable),
+            ApiGroups = clusterDto.ApiGroups.Select(ToApiGroup).ToList(),
+            Addons = clusterDto.Addons.Select(ToAddon).ToList(),
+            UpgradeReadiness = ToUpgradeReadiness(clusterDto.UpgradeReadiness),
             Ingresses = new List<Ingress>(),
             Services = new List<Service>()
         };
@@ -98,10 +165,38 @@ public class ClusterService : IClusterService
             }
         }
 
+        // controllers that don't send a uid keep the one stored
+        if (clusterDto.ClusterUid != null && cluster.ClusterUid != clusterDto.ClusterUid)
+        {
+            var existingCluster = await _context.Clusters
+                .FirstOrDefaultAsync(c => c.ClusterUid == clusterDto.ClusterUid);
+
+            if (existingCluster != null)
+            {
+                throw new DbUpdateException(
+                    "Violation of unique constraint",
+                    new Exception($"A cluster with uid '{clusterDto.ClusterUid}' already exists"));
+            }
+
+            cluster.ClusterUid = clusterDto.ClusterUid;
+        }
+
         cluster.ClusterName = clusterDto.ClusterName;
         cluster.ApiserverVersion = clusterDto.ApiserverVersion;
         cluster.KubeletVersions = clusterDto.KubeletVersions.Distinct().ToList();
         cluster.KernelVersions = clusterDto.KernelVersions.Distinct().ToList();
+        cluster.Environment = clusterDto.Environment;
+        cluster.Distribution = clusterDto.Distribution;
+        cluster.Provider = clusterDto.Provider;
+        cluster.ContainerRuntimes = clusterDto.ContainerRuntimes.Distinct().ToList();
+        cluster.OsImages = clusterDto.OsImages.Distinct().ToList();
+        cluster.Architectures = clusterDto.Architectures.Distinct().ToList();
+        cluster.NodeCount = clusterDto.NodeCount;
+        cluster.TotalCapacity = ToNodeResources(clusterDto.TotalCapacity);
+        cluster.TotalAllocatable = ToNodeResources(clusterDto.TotalAllocatable);
+        cluster.ApiGroups = clusterDto.ApiGroups.Select(ToApiGroup).ToList();
+        cluster.Addons = clusterDto.Addons.Select(ToAddon).ToList();
+        cluster.UpgradeReadiness = ToUpgradeReadiness(clusterDto.UpgradeReadiness);
 
         await _context.SaveChangesAsync();
         
@@ -124,9 +219,22 @@ public class ClusterService : IClusterService
     {
         Id = cluster.Id,
         ClusterName = cluster.ClusterName,
+        ClusterUid = cluster.ClusterUid,
         ApiserverVersion = cluster.ApiserverVersion,
         KubeletVersions = cluster.KubeletVersions,
         KernelVersions = cluster.KernelVersions,
+        Environment = cluster.Environment,
+        Distribution = cluster.Distribution,
+        Provider = cluster.Provider,
+        ContainerRuntimes = cluster.ContainerRuntimes,
+        OsImages = cluster.OsImages,
+        Architectures = cluster.Architectures,
+        NodeCount = cluster.NodeCount,
+        TotalCapacity = ToNodeResourcesDto(cluster.TotalCapacity),
+        TotalAllocatable = ToNodeResourcesDto(cluster.TotalAllocatable),
+        ApiGroups = cluster.ApiGroups.Select(ToApiGroupDto).ToList(),
+        Addons = cluster.Addons.Select(ToAddonDto).ToList(),
+        UpgradeReadiness = ToUpgradeReadinessDto(cluster.UpgradeReadiness),
         Ingresses = cluster.Ingresses.Select(i => new IngressResponseDto
         {
             Id = i.Id,
@@ -146,6 +254,21 @@ public class ClusterService : IClusterService
             ExternalIp = s.ExternalIp,
             Ports = s.Ports,
             ServiceType = s.ServiceType,
+            ClusterIps = s.ClusterIps,
+            ExternalIps = s.ExternalIps,
+            LoadBalancerIps = s.LoadBalancerIps,
+            LoadBalancerHostnames = s.LoadBalancerHostnames,
+            ExternalName = s.ExternalName,
+            PortDetails = s.PortDetails.Select(p => new ServicePortDto
+            {
+                Name = p.Name,
+                Protocol = p.Protocol,
+                Port = p.Port,
+                TargetPort = p.TargetPort,
+                NodePort = p.NodePort
+            }).ToList(),
+            ReadyEndpoints = s.ReadyEndpoints,
+            NotReadyEndpoints = s.NotReadyEndpoints,
             ClusterName = cluster.ClusterName,
             CreatedAt = s.CreatedAt,
             UpdatedAt = s.UpdatedAt
@@ -153,4 +276,115 @@ public class ClusterService : IClusterService
         CreatedAt = cluster.CreatedAt,
         UpdatedAt = cluster.UpdatedAt
     };
+
+    private static NodeResources ToNodeResources(NodeResourcesDto resources) => new()
+    {
+        CpuMillicores = resources.CpuMillicores,
+        MemoryBytes = resources.MemoryBytes,
+        Pods = resources.Pods
+    };
+
+    private static NodeResourcesDto ToNodeResourcesDto(NodeResources resources) => new()
+    {
+        CpuMillicores = resources.CpuMillicores,
+        MemoryBytes = resources.MemoryBytes,
+        Pods = resources.Pods
+    };
+
+    private static ApiGroup ToApiGroup(ApiGroupDto group) => new()
+    {
+        Group = group.Group,
+        Versions = group.Versions,
+        PreferredVersion = group.PreferredVersion,
+        Source = group.Source,
+        Crds = group.Crds
+    };
+
+    private static ApiGroupDto ToApiGroupDto(ApiGroup group) => new()
+    {
+        Group = group.Group,
+        Versions = group.Versions,
+        PreferredVersion = group.PreferredVersion,
+        Source = group.Source,
+        Crds = group.Crds
+    };
+
+    private static Addon ToAddon(AddonDto addon) => new()
+    {
+        Name = addon.Name,
+        Version = addon.Version,
+        Namespace = addon.Namespace,
+        DetectedBy = addon.DetectedBy
+    };
+
+    private static AddonDto ToAddonDto(Addon addon) => new()
+    {
+        Name = addon.Name,
+        Version = addon.Version,
+        Namespace = addon.Namespace,
+        DetectedBy = addon.DetectedBy
+    };
+
+    private static UpgradeReadiness? ToUpgradeReadiness(UpgradeReadinessDto? readiness) =>
+        readiness == null ? null : new()
+        {
+            CurrentVersion = readiness.CurrentVersion,
+            TargetVersion = readiness.TargetVersion,
+            MetricsAvailable = readiness.MetricsAvailable,
+            DeprecatedApis = readiness.DeprecatedApis.Select(ToDeprecatedApiUsage).ToList(),
+            Blockers = readiness.Blockers.Select(ToDeprecatedApiUsage).ToList()
+        };
+
+    private static UpgradeReadinessDto? ToUpgradeReadinessDto(UpgradeReadiness? readiness) =>
+        readiness == null ? null : new()
+        {
+            CurrentVersion = readiness.CurrentVersion,
+            TargetVersion = readiness.TargetVersion,
+            MetricsAvailable = readiness.MetricsAvailable,
+            DeprecatedApis = readiness.DeprecatedApis.Select(ToDeprecatedApiUsageDto).ToList(),
+            Blockers = readiness.Blockers.Select(ToDeprecatedApiUsageDto).ToList()
+        };
+
+    private static DeprecatedApiUsage ToDeprecatedApiUsage(DeprecatedApiUsageDto api) => new()
+    {
+        Group = api.Group,
+        Version = api.Version,
+        Resource = api.Resource,
+        DeprecatedIn = api.DeprecatedIn,
+        RemovedIn = api.RemovedIn,
+        Replacement = api.Replacement,
+        Served = api.Served,
+        Requested = api.Requested
+    };
+
+    private static DeprecatedApiUsageDto ToDeprecatedApiUsageDto(DeprecatedApiUsage api) => new()
+    {
+        Group = api.Group,
+        Version = api.Version,
+        Resource = api.Resource,
+        DeprecatedIn = api.DeprecatedIn,
+        RemovedIn = api.RemovedIn,
+        Replacement = api.Replacement,
+        Served = api.Served,
+        Requested = api.Requested
+    };
+
+    // TryParseAddonVersion reads the numeric part of an add-on version,
+    // dropping a leading v and any pre-release or build suffix
+    private static bool TryParseAddonVersion(string value, [NotNullWhen(true)] out Version? version)
+    {
+        var trimmed = value.TrimStart('v', 'V');
+        var end = trimmed.IndexOfAny(new[] { '-', '+' });
+        if (end >= 0)
+        {
+            trimmed = trimmed[..end];
+        }
+
+        if (!trimmed.Contains('.'))
+        {
+            trimmed += ".0";
+        }
+
+        return Version.TryParse(trimmed, out version);
+    }
 }
\ No newline at end of file
@@ -3,6 +3,8 @@ public interface IClusterService
     Task<IEnumerable<ClusterResponseDto>> GetAllClustersAsync();
     Task<ClusterResponseDto?> GetClusterByIdAsync(int id);
     Task<ClusterResponseDto?> GetClusterByNameAsync(string name);
+    Task<ClusterResponseDto?> GetClusterByUidAsync(string uid);
+    Task<IEnumerable<AddonUsageDto>> GetClustersByAddonAsync(string name, string? version, string? olderThan);
     Task<ClusterResponseDto> CreateClusterAsync(ClusterCreateDto clusterDto);
     Task<ClusterResponseDto> UpdateClusterAsync(int id, ClusterCreateDto clusterDto);
     Task DeleteClusterAsync(int id);
@@ -6,4 +6,5 @@ public interface IIngressService
     Task<IngressResponseDto> CreateIngressAsync(IngressCreateDto ingressDto);
     Task<IngressResponseDto> UpdateIngressAsync(int id, IngressCreateDto ingressDto);
     Task DeleteIngressAsync(int id);
+    Task<BulkResponseDto> BulkApplyIngressesAsync(BulkRequestDto<IngressCreateDto> request);
 }
\ No newline at end of file
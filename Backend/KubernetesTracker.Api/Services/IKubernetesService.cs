@@ -6,4 +6,5 @@ public interface IKubernetesService
     Task<ServiceResponseDto> CreateServiceAsync(ServiceCreateDto serviceDto);
     Task<ServiceResponseDto> UpdateServiceAsync(int id, ServiceCreateDto serviceDto);
     Task DeleteServiceAsync(int id);
+    Task<BulkResponseDto> BulkApplyServicesAsync(BulkRequestDto<ServiceCreateDto> request);
 }
\ No newline at end of file
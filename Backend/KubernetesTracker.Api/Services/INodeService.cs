@@ -0,0 +1,10 @@
+public interface INodeService
+{
+    Task<IEnumerable<NodeResponseDto>> GetAllNodesAsync();
+    Task<IEnumerable<NodeResponseDto>> GetNodesByClusterAsync(string clusterName);
+    Task<NodeResponseDto?> GetNodeAsync(int id);
+    Task<NodeResponseDto> CreateNodeAsync(NodeCreateDto nodeDto);
+    Task<NodeResponseDto> UpdateNodeAsync(int id, NodeCreateDto nodeDto);
+    Task DeleteNodeAsync(int id);
+    Task<BulkResponseDto> BulkApplyNodesAsync(BulkRequestDto<NodeCreateDto> request);
+}
\ No newline at end of file
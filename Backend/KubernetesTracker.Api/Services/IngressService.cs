@@ -124,6 +124,24 @@ public class IngressService : IIngressService
         await _context.SaveChangesAsync();
     }
 
+    public Task<BulkResponseDto> BulkApplyIngressesAsync(BulkRequestDto<IngressCreateDto> request) =>
+        BulkApply.ApplyAsync<Ingress, IngressCreateDto>(
+            _context,
+            request,
+            clusterId => _context.Ingresses.Where(i => i.ClusterId == clusterId),
+            ingress => (ingress.Namespace, ingress.IngressName),
+            dto => (dto.Namespace, dto.IngressName),
+            cluster => new Ingress { ClusterId = cluster.Id, Cluster = cluster },
+            CopyFromDto);
+
+    private static void CopyFromDto(Ingress ingress, IngressCreateDto ingressDto)
+    {
+        ingress.Namespace = ingressDto.Namespace;
+        ingress.IngressName = ingressDto.IngressName;
+        ingress.Hosts = ingressDto.Hosts ?? new List<string>();
+        ingress.Ports = ingressDto.Ports ?? new List<int>();
+    }
+
     private static IngressResponseDto ToResponseDto(Ingress ingress) => new()
     {
         Id = ingress.Id,
@@ -71,6 +71,14 @@ public class KubernetesService : IKubernetesService
             ExternalIp = serviceDto.ExternalIp,
             Ports = serviceDto.Ports,
             ServiceType = serviceDto.ServiceType,
+            ClusterIps = serviceDto.ClusterIps,
+            ExternalIps = serviceDto.ExternalIps,
+            LoadBalancerIps = serviceDto.LoadBalancerIps,
+            LoadBalancerHostnames = serviceDto.LoadBalancerHostnames,
+            ExternalName = serviceDto.ExternalName,
+            PortDetails = serviceDto.PortDetails.Select(ToServicePort).ToList(),
+            ReadyEndpoints = serviceDto.ReadyEndpoints,
+            NotReadyEndpoints = serviceDto.NotReadyEndpoints,
             Cluster = cluster
         };
 
@@ -120,6 +128,14 @@ public class KubernetesService : IKubernetesService
         service.ExternalIp = serviceDto.ExternalIp;
         service.Ports = serviceDto.Ports;
         service.ServiceType = serviceDto.ServiceType;
+        service.ClusterIps = serviceDto.ClusterIps;
+        service.ExternalIps = serviceDto.ExternalIps;
+        service.LoadBalancerIps = serviceDto.LoadBalancerIps;
+        service.LoadBalancerHostnames = serviceDto.LoadBalancerHostnames;
+        service.ExternalName = serviceDto.ExternalName;
+        service.PortDetails = serviceDto.PortDetails.Select(ToServicePort).ToList();
+        service.ReadyEndpoints = serviceDto.ReadyEndpoints;
+        service.NotReadyEndpoints = serviceDto.NotReadyEndpoints;
 
         await _context.SaveChangesAsync();
 
@@ -138,6 +154,33 @@ public class KubernetesService : IKubernetesService
         await _context.SaveChangesAsync();
     }
 
+    public Task<BulkResponseDto> BulkApplyServicesAsync(BulkRequestDto<ServiceCreateDto> request) =>
+        BulkApply.ApplyAsync<Service, ServiceCreateDto>(
+            _context,
+            request,
+            clusterId => _context.Services.Where(s => s.ClusterId == clusterId),
+            service => (service.Namespace, service.ServiceName),
+            dto => (dto.Namespace, dto.ServiceName),
+            cluster => new Service { ClusterId = cluster.Id, Cluster = cluster },
+            CopyFromDto);
+
+    private static void CopyFromDto(Service service, ServiceCreateDto serviceDto)
+    {
+        service.Namespace = serviceDto.Namespace;
+        service.ServiceName = serviceDto.ServiceName;
+        service.ExternalIp = serviceDto.ExternalIp;
+        service.Ports = serviceDto.Ports ?? new List<int>();
+        service.ServiceType = serviceDto.ServiceType;
+        service.ClusterIps = serviceDto.ClusterIps ?? new List<string>();
+        service.ExternalIps = serviceDto.ExternalIps ?? new List<string>();
+        service.LoadBalancerIps = serviceDto.LoadBalancerIps ?? new List<string>();
+        service.LoadBalancerHostnames = serviceDto.LoadBalancerHostnames ?? new List<string>();
+        service.ExternalName = serviceDto.ExternalName;
+        service.PortDetails = (serviceDto.PortDetails ?? new List<ServicePortDto>()).Select(ToServicePort).ToList();
+        service.ReadyEndpoints = serviceDto.ReadyEndpoints;
+        service.NotReadyEndpoints = serviceDto.NotReadyEndpoints;
+    }
+
     private static ServiceResponseDto ToResponseDto(Service service) => new()
     {
         Id = service.Id,
@@ -146,8 +189,34 @@ public class KubernetesService : IKubernetesService
         ExternalIp = service.ExternalIp,
         Ports = service.Ports,
         ServiceType = service.ServiceType,
+        ClusterIps = service.ClusterIps,
+        ExternalIps = service.ExternalIps,
+        LoadBalancerIps = service.LoadBalancerIps,
+        LoadBalancerHostnames = service.LoadBalancerHostnames,
+        ExternalName = service.ExternalName,
+        PortDetails = service.PortDetails.Select(ToServicePortDto).ToList(),
+        ReadyEndpoints = service.ReadyEndpoints,
+        NotReadyEndpoints = service.NotReadyEndpoints,
         ClusterName = service.Cluster.ClusterName,
         CreatedAt = service.CreatedAt,
         UpdatedAt = service.UpdatedAt
     };
+
+    private static ServicePort ToServicePort(ServicePortDto port) => new()
+    {
+        Name = port.Name,
+        Protocol = port.Protocol,
+        Port = port.Port,
+        TargetPort = port.TargetPort,
+        NodePort = port.NodePort
+    };
+
+    private static ServicePortDto ToServicePortDto(ServicePort port) => new()
+    {
+        Name = port.Name,
+        Protocol = port.Protocol,
+        Port = port.Port,
+        TargetPort = port.TargetPort,
+        NodePort = port.NodePort
+    };
 }
\ No newline at end of file
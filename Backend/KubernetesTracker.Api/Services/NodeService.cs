@@ -0,0 +1,242 @@
+using KubernetesTracker.Api.Data;
+using Microsoft.EntityFrameworkCore;
+
+public class NodeService : INodeService
+{
+    private readonly ApplicationDbContext _context;
+
+    public NodeService(ApplicationDbContext context)
+    {
+        _context = context;
+    }
+
+    public async Task<IEnumerable<NodeResponseDto>> GetAllNodesAsync()
+    {
+        var nodes = await _context.Nodes
+            .Include(n => n.Cluster)
+            .AsSplitQuery()
+            .ToListAsync();
+
+        return nodes.Select(ToResponseDto);
+    }
+
+    public async Task<IEnumerable<NodeResponseDto>> GetNodesByClusterAsync(string clusterName)
+    {
+        var nodes = await _context.Nodes
+            .Include(n => n.Cluster)
+            .AsSplitQuery()
+            .Where(n => n.Cluster.ClusterName == clusterName)
+            .ToListAsync();
+
+        return nodes.Select(ToResponseDto);
+    }
+
+    public async Task<NodeResponseDto?> GetNodeAsync(int id)
+    {
+        var node = await _context.Nodes
+            .Include(n => n.Cluster)
+            .AsSplitQuery()
+            .FirstOrDefaultAsync(n => n.Id == id);
+
+        return node == null ? null : ToResponseDto(node);
+    }
+
+    public async Task<NodeResponseDto> CreateNodeAsync(NodeCreateDto nodeDto)
+    {
+        var cluster = await _context.Clusters
+            .FirstOrDefaultAsync(c => c.ClusterName == nodeDto.ClusterName);
+
+        if (cluster == null)
+        {
+            throw new NotFoundException($"Cluster '{nodeDto.ClusterName}' not found");
+        }
+
+        var existingNode = await _context.Nodes
+            .AnyAsync(n => n.Cluster.Id == cluster.Id &&
+                        n.NodeName == nodeDto.NodeName);
+
+        if (existingNode)
+        {
+            throw new DbUpdateException(
+                $"Node '{nodeDto.NodeName}' already exists in cluster '{nodeDto.ClusterName}'",
+                new Exception("Unique constraint violation"));
+        }
+
+        var node = new Node
+        {
+            ClusterId = cluster.Id,
+            NodeName = nodeDto.NodeName,
+            Roles = nodeDto.Roles,
+            OsImage = nodeDto.OsImage,
+            OperatingSystem = nodeDto.OperatingSystem,
+            Architecture = nodeDto.Architecture,
+            ContainerRuntimeVersion = nodeDto.ContainerRuntimeVersion,
+            KubeletVersion = nodeDto.KubeletVersion,
+            KernelVersion = nodeDto.KernelVersion,
+            ProviderId = nodeDto.ProviderId,
+            InstanceType = nodeDto.InstanceType,
+            Zone = nodeDto.Zone,
+            Region = nodeDto.Region,
+            Capacity = ToNodeResources(nodeDto.Capacity),
+            Allocatable = ToNodeResources(nodeDto.Allocatable),
+            Taints = nodeDto.Taints.Select(ToNodeTaint).ToList(),
+            Ready = nodeDto.Ready,
+            Cluster = cluster
+        };
+
+        _context.Nodes.Add(node);
+        await _context.SaveChangesAsync();
+
+        return ToResponseDto(node);
+    }
+
+    public async Task<NodeResponseDto> UpdateNodeAsync(int id, NodeCreateDto nodeDto)
+    {
+        var node = await _context.Nodes
+            .Include(n => n.Cluster)
+            .FirstOrDefaultAsync(n => n.Id == id);
+
+        if (node == null)
+        {
+            throw new NotFoundException($"Node with ID {id} not found");
+        }
+
+        var cluster = await _context.Clusters
+            .FirstOrDefaultAsync(c => c.ClusterName == nodeDto.ClusterName);
+
+        if (cluster == null)
+        {
+            throw new NotFoundException($"Cluster '{nodeDto.ClusterName}' not found");
+        }
+
+        // Check if update would create a duplicate
+        var existingNode = await _context.Nodes
+            .AnyAsync(n => n.Id != id &&
+                        n.Cluster.Id == cluster.Id &&
+                        n.NodeName == nodeDto.NodeName);
+
+        if (existingNode)
+        {
+            throw new DbUpdateException(
+                $"Node '{nodeDto.NodeName}' already exists in cluster '{nodeDto.ClusterName}'",
+                new Exception("Unique constraint violation"));
+        }
+
+        node.ClusterId = cluster.Id;
+        node.Cluster = cluster;
+        node.NodeName = nodeDto.NodeName;
+        node.Roles = nodeDto.Roles;
+        node.OsImage = nodeDto.OsImage;
+        node.OperatingSystem = nodeDto.OperatingSystem;
+        node.Architecture = nodeDto.Architecture;
+        node.ContainerRuntimeVersion = nodeDto.ContainerRuntimeVersion;
+        node.KubeletVersion = nodeDto.KubeletVersion;
+        node.KernelVersion = nodeDto.KernelVersion;
+        node.ProviderId = nodeDto.ProviderId;
+        node.InstanceType = nodeDto.InstanceType;
+        node.Zone = nodeDto.Zone;
+        node.Region = nodeDto.Region;
+        node.Capacity = ToNodeResources(nodeDto.Capacity);
+        node.Allocatable = ToNodeResources(nodeDto.Allocatable);
+        node.Taints = nodeDto.Taints.Select(ToNodeTaint).ToList();
+        node.Ready = nodeDto.Ready;
+
+        await _context.SaveChangesAsync();
+
+        return ToResponseDto(node);
+    }
+
+    public async Task DeleteNodeAsync(int id)
+    {
+        var node = await _context.Nodes.FindAsync(id);
+        if (node == null)
+        {
+            throw new NotFoundException($"Node with ID {id} not found");
+        }
+
+        _context.Nodes.Remove(node);
+        await _context.SaveChangesAsync();
+    }
+
+    public Task<BulkResponseDto> BulkApplyNodesAsync(BulkRequestDto<NodeCreateDto> request) =>
+        BulkApply.ApplyAsync<Node, NodeCreateDto>(
+            _context,
+            request,
+            clusterId => _context.Nodes.Where(n => n.ClusterId == clusterId),
+            node => (null, node.NodeName),
+            dto => (null, dto.NodeName),
+            cluster => new Node { ClusterId = cluster.Id, Cluster = cluster },
+            CopyFromDto);
+
+    private static void CopyFromDto(Node node, NodeCreateDto nodeDto)
+    {
+        node.NodeName = nodeDto.NodeName;
+        node.Roles = nodeDto.Roles ?? new List<string>();
+        node.OsImage = nodeDto.OsImage;
+        node.OperatingSystem = nodeDto.OperatingSystem;
+        node.Architecture = nodeDto.Architecture;
+        node.ContainerRuntimeVersion = nodeDto.ContainerRuntimeVersion;
+        node.KubeletVersion = nodeDto.KubeletVersion;
+        node.KernelVersion = nodeDto.KernelVersion;
+        node.ProviderId = nodeDto.ProviderId;
+        node.InstanceType = nodeDto.InstanceType;
+        node.Zone = nodeDto.Zone;
+        node.Region = nodeDto.Region;
+        node.Capacity = ToNodeResources(nodeDto.Capacity ?? new NodeResourcesDto());
+        node.Allocatable = ToNodeResources(nodeDto.Allocatable ?? new NodeResourcesDto());
+        node.Taints = (nodeDto.Taints ?? new List<NodeTaintDto>()).Select(ToNodeTaint).ToList();
+        node.Ready = nodeDto.Ready;
+    }
+
+    private static NodeResponseDto ToResponseDto(Node node) => new()
+    {
+        Id = node.Id,
+        NodeName = node.NodeName,
+        Roles = node.Roles,
+        OsImage = node.OsImage,
+        OperatingSystem = node.OperatingSystem,
+        Architecture = node.Architecture,
+        ContainerRuntimeVersion = node.ContainerRuntimeVersion,
+        KubeletVersion = node.KubeletVersion,
+        KernelVersion = node.KernelVersion,
+        ProviderId = node.ProviderId,
+        InstanceType = node.InstanceType,
+        Zone = node.Zone,
+        Region = node.Region,
+        Capacity = ToNodeResourcesDto(node.Capacity),
+        Allocatable = ToNodeResourcesDto(node.Allocatable),
+        Taints = node.Taints.Select(ToNodeTaintDto).ToList(),
+        Ready = node.Ready,
+        ClusterName = node.Cluster.ClusterName,
+        CreatedAt = node.CreatedAt,
+        UpdatedAt = node.UpdatedAt
+    };
+
+    private static NodeResources ToNodeResources(NodeResourcesDto resources) => new()
+    {
+        CpuMillicores = resources.CpuMillicores,
+        MemoryBytes = resources.MemoryBytes,
+        Pods = resources.Pods
+    };
+
+    private static NodeResourcesDto ToNodeResourcesDto(NodeResources resources) => new()
+    {
+        CpuMillicores = resources.CpuMillicores,
+        MemoryBytes = resources.MemoryBytes,
+        Pods = resources.Pods
+    };
+
+    private static NodeTaint ToNodeTaint(NodeTaintDto taint) => new()
+    {
+        Key = taint.Key,
+        Value = taint.Value,
+        Effect = taint.Effect
+    };
+
+    private static NodeTaintDto ToNodeTaintDto(NodeTaint taint) => new()
+    {
+        Key = taint.Key,
+        Value = taint.Value,
+        Effect = taint.Effect
+    };
+}
\ No newline at end of file
@@ -121,4 +121,259 @@ public class ClusterServiceTests : IDisposable
         
         Assert.Equal("Cluster with ID 999 not found", exception.Message);
     }
+
+    [Fact]
+    public async Task GetClusterByUid_FindsRenamedCluster()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        var dto = new ClusterCreateDto
+        {
+            ClusterName = "test-cluster",
+            ClusterUid = "0f2c1a8e-5b7d-4c3e-9a61-2d4f8b9e7c10",
+            ApiserverVersion = "1.0.0"
+        };
+        var created = await service.CreateClusterAsync(dto);
+
+        dto.ClusterName = "renamed-cluster";
+        await service.UpdateClusterAsync(created.Id, dto);
+
+        // Act
+        var result = await service.GetClusterByUidAsync("0f2c1a8e-5b7d-4c3e-9a61-2d4f8b9e7c10");
+
+        // Assert
+        Assert.NotNull(result);
+        Assert.Equal(created.Id, result.Id);
+        Assert.Equal("renamed-cluster", result.ClusterName);
+    }
+
+    [Fact]
+    public async Task UpdateCluster_KeepsClusterUid_WhenNoneSent()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        var dto = new ClusterCreateDto
+        {
+            ClusterName = "test-cluster",
+            ClusterUid = "0f2c1a8e-5b7d-4c3e-9a61-2d4f8b9e7c10",
+            ApiserverVersion = "1.0.0"
+        };
+        var created = await service.CreateClusterAsync(dto);
+
+        dto.ClusterUid = null;
+        dto.ApiserverVersion = "1.1.0";
+
+        // Act
+        var result = await service.UpdateClusterAsync(created.Id, dto);
+
+        // Assert
+        Assert.Equal("0f2c1a8e-5b7d-4c3e-9a61-2d4f8b9e7c10", result.ClusterUid);
+        Assert.Equal("1.1.0", result.ApiserverVersion);
+    }
+
+    [Fact]
+    public async Task CreateCluster_StoresClusterDetails()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        var dto = new ClusterCreateDto
+        {
+            ClusterName = "test-cluster",
+            ApiserverVersion = "1.30.4-eks-a737599",
+            Environment = "production",
+            Distribution = "eks",
+            Provider = "aws",
+            ContainerRuntimes = new List<string> { "containerd://1.7.11", "containerd://1.7.11" },
+            OsImages = new List<string> { "Amazon Linux 2" },
+            Architectures = new List<string> { "amd64", "arm64" },
+            NodeCount = 3,
+            TotalCapacity = new NodeResourcesDto { CpuMillicores = 12000, MemoryBytes = 48L << 30, Pods = 330 },
+            TotalAllocatable = new NodeResourcesDto { CpuMillicores = 11400, MemoryBytes = 45L << 30, Pods = 330 }
+        };
+
+        // Act
+        await service.CreateClusterAsync(dto);
+        var result = await service.GetClusterByNameAsync("test-cluster");
+
+        // Assert
+        Assert.NotNull(result);
+        Assert.Equal("production", result.Environment);
+        Assert.Equal("eks", result.Distribution);
+        Assert.Equal("aws", result.Provider);
+        Assert.Equal(new List<string> { "containerd://1.7.11" }, result.ContainerRuntimes);
+        Assert.Equal(new List<string> { "Amazon Linux 2" }, result.OsImages);
+        Assert.Equal(new List<string> { "amd64", "arm64" }, result.Architectures);
+        Assert.Equal(3, result.NodeCount);
+        Assert.Equal(12000, result.TotalCapacity.CpuMillicores);
+        Assert.Equal(45L << 30, result.TotalAllocatable.MemoryBytes);
+    }
+
+    [Fact]
+    public async Task UpdateCluster_ReplacesClusterDetails()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        var dto = new ClusterCreateDto
+        {
+            ClusterName = "test-cluster",
+            ApiserverVersion = "1.0.0",
+            NodeCount = 3,
+            Architectures = new List<string> { "amd64" },
+            TotalCapacity = new NodeResourcesDto { CpuMillicores = 12000, Pods = 330 }
+        };
+        var created = await service.CreateClusterAsync(dto);
+
+        dto.NodeCount = 4;
+        dto.Architectures = new List<string> { "amd64", "arm64" };
+        dto.TotalCapacity = new NodeResourcesDto { CpuMillicores = 16000, Pods = 440 };
+
+        // Act
+        await service.UpdateClusterAsync(created.Id, dto);
+        var result = await service.GetClusterByIdAsync(created.Id);
+
+        // Assert
+        Assert.NotNull(result);
+        Assert.Equal(4, result.NodeCount);
+        Assert.Equal(new List<string> { "amd64", "arm64" }, result.Architectures);
+        Assert.Equal(16000, result.TotalCapacity.CpuMillicores);
+        Assert.Equal(440, result.TotalCapacity.Pods);
+    }
+
+    [Fact]
+    public async Task CreateCluster_StoresApiGroupsAndAddons()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        var dto = new ClusterCreateDto
+        {
+            ClusterName = "test-cluster",
+            ApiserverVersion = "1.0.0",
+            ApiGroups = new List<ApiGroupDto>
+            {
+                new()
+                {
+                    Group = "cert-manager.io",
+                    Versions = new List<string> { "v1" },
+                    PreferredVersion = "v1",
+                    Source = "crd",
+                    Crds = new List<string> { "certificates.cert-manager.io", "issuers.cert-manager.io" }
+                }
+            },
+            Addons = new List<AddonDto>
+            {
+                new() { Name = "cert-manager", Version = "v1.14.4", Namespace = "cert-manager", DetectedBy = "deployment" },
+                new() { Name = "argo-cd", Version = "2.10.1", DetectedBy = "crd" }
+            }
+        };
+
+        // Act
+        await service.CreateClusterAsync(dto);
+        var result = await service.GetClusterByNameAsync("test-cluster");
+
+        // Assert
+        Assert.NotNull(result);
+        var group = Assert.Single(result.ApiGroups);
+        Assert.Equal("crd", group.Source);
+        Assert.Equal(2, group.Crds.Count);
+        Assert.Equal(2, result.Addons.Count);
+        Assert.Equal("v1.14.4", result.Addons[0].Version);
+        Assert.Null(result.Addons[1].Namespace);
+    }
+
+    [Fact]
+    public async Task GetClustersByAddon_FiltersByVersion()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        foreach (var (name, version) in new[] { ("old", "v1.11.0"), ("new", "v1.14.4"), ("rc", "1.12.0-rc.1") })
+        {
+            await service.CreateClusterAsync(new ClusterCreateDto
+            {
+                ClusterName = name,
+                ApiserverVersion = "1.0.0",
+                Addons = new List<AddonDto>
+                {
+                    new() { Name = "cert-manager", Version = version, DetectedBy = "deployment" }
+                }
+            });
+        }
+        await service.CreateClusterAsync(new ClusterCreateDto { ClusterName = "none", ApiserverVersion = "1.0.0" });
+
+        // Act
+        var all = await service.GetClustersByAddonAsync("cert-manager", null, null);
+        var exact = await service.GetClustersByAddonAsync("cert-manager", "v1.14.4", null);
+        var older = await service.GetClustersByAddonAsync("cert-manager", null, "1.13");
+
+        // Assert
+        Assert.Equal(new[] { "new", "old", "rc" }, all.Select(a => a.ClusterName));
+        Assert.Equal("new", Assert.Single(exact).ClusterName);
+        Assert.Equal(new[] { "old", "rc" }, older.Select(a => a.ClusterName));
+    }
+
+    [Fact]
+    public async Task GetClustersByAddon_RejectsInvalidVersion()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+
+        // Act & Assert
+        await Assert.ThrowsAsync<ArgumentException>(
+            () => service.GetClustersByAddonAsync("cert-manager", null, "latest"));
+    }
+
+    [Fact]
+    public async Task UpdateCluster_StoresUpgradeReadiness()
+    {
+        // Arrange
+        var service = new ClusterService(_context);
+        var dto = new ClusterCreateDto
+        {
+            ClusterName = "test-cluster",
+            ApiserverVersion = "v1.24.17"
+        };
+        var created = await service.CreateClusterAsync(dto);
+
+        var ingresses = new DeprecatedApiUsageDto
+        {
+            Group = "networking.k8s.io",
+            Version = "v1beta1",
+            Resource = "ingresses",
+            DeprecatedIn = "1.19",
+            RemovedIn = "1.22",
+            Replacement = "networking.k8s.io/v1",
+            Requested = true
+        };
+        var flowSchemas = new DeprecatedApiUsageDto
+        {
+            Group = "flowcontrol.apiserver.k8s.io",
+            Version = "v1beta2",
+            Resource = "flowschemas",
+            RemovedIn = "1.29",
+            Served = true
+        };
+        dto.UpgradeReadiness = new UpgradeReadinessDto
+        {
+            CurrentVersion = "1.24",
+            TargetVersion = "1.25",
+            MetricsAvailable = true,
+            DeprecatedApis = new List<DeprecatedApiUsageDto> { ingresses, flowSchemas },
+            Blockers = new List<DeprecatedApiUsageDto> { ingresses }
+        };
+
+        // Act
+        await service.UpdateClusterAsync(created.Id, dto);
+        var result = await service.GetClusterByIdAsync(created.Id);
+
+        // Assert
+        Assert.Null(created.UpgradeReadiness);
+        Assert.NotNull(result);
+        Assert.NotNull(result.UpgradeReadiness);
+        Assert.Equal("1.25", result.UpgradeReadiness.TargetVersion);
+        Assert.True(result.UpgradeReadiness.MetricsAvailable);
+        Assert.Equal(2, result.UpgradeReadiness.DeprecatedApis.Count);
+        var blocker = Assert.Single(result.UpgradeReadiness.Blockers);
+        Assert.Equal("ingresses", blocker.Resource);
+        Assert.True(blocker.Requested);
+        Assert.Null(result.UpgradeReadiness.DeprecatedApis[1].Replacement);
+    }
 }
\ No newline at end of file
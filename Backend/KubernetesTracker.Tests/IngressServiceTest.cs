@@ -137,4 +137,45 @@ public class IngressServiceTests : IDisposable
         // Assert
         Assert.Equal(2, results.Count());
     }
+
+    [Fact]
+    public async Task BulkApplyIngresses_CreatesAndDeletes()
+    {
+        // Arrange
+        var service = new IngressService(_context);
+        await CreateTestCluster();
+        await service.CreateIngressAsync(new IngressCreateDto
+        {
+            ClusterName = "test-cluster",
+            Namespace = "default",
+            IngressName = "stale",
+            Hosts = new List<string> { "stale.example.com" }
+        });
+
+        var request = new BulkRequestDto<IngressCreateDto>
+        {
+            ClusterName = "test-cluster",
+            Upserts = new List<IngressCreateDto>
+            {
+                new()
+                {
+                    ClusterName = "test-cluster",
+                    Namespace = "default",
+                    IngressName = "web",
+                    Hosts = new List<string> { "web.example.com" },
+                    Ports = null!
+                }
+            },
+            Deletes = new List<BulkDeleteDto> { new() { Namespace = "default", Name = "stale" } }
+        };
+
+        // Act
+        var result = await service.BulkApplyIngressesAsync(request);
+
+        // Assert
+        Assert.All(result.Results, r => Assert.True(r.Success, r.Error));
+        var ingress = Assert.Single(await service.GetIngressesByClusterAsync("test-cluster"));
+        Assert.Equal("web", ingress.IngressName);
+        Assert.Empty(ingress.Ports);
+    }
 }
@@ -140,4 +140,211 @@ public class KubernetesServiceTests : IDisposable
         // Assert
         Assert.Equal(2, results.Count());
     }
+
+    [Fact]
+    public async Task CreateService_StoresServiceDetails()
+    {
+        // Arrange
+        var service = new KubernetesService(_context);
+        await CreateTestCluster();
+
+        var dto = new ServiceCreateDto
+        {
+            ClusterName = "test-cluster",
+            Namespace = "default",
+            ServiceName = "test-service",
+            Ports = new List<int> { 443 },
+            ServiceType = "LoadBalancer",
+            ClusterIps = new List<string> { "10.96.0.10", "fd00::10" },
+            ExternalIps = new List<string> { "192.0.2.10" },
+            LoadBalancerIps = new List<string> { "203.0.113.10" },
+            LoadBalancerHostnames = new List<string> { "lb.example.com" },
+            PortDetails = new List<ServicePortDto>
+            {
+                new() { Name = "https", Protocol = "TCP", Port = 443, TargetPort = "8443", NodePort = 30443 },
+                new() { Name = "", Protocol = "UDP", Port = 53, TargetPort = "dns" }
+            }
+        };
+
+        // Act
+        var created = await service.CreateServiceAsync(dto);
+        var result = await service.GetServiceAsync(created.Id);
+
+        // Assert
+        Assert.NotNull(result);
+        Assert.Equal(new List<string> { "10.96.0.10", "fd00::10" }, result.ClusterIps);
+        Assert.Equal(new List<string> { "192.0.2.10" }, result.ExternalIps);
+        Assert.Equal(new List<string> { "203.0.113.10" }, result.LoadBalancerIps);
+        Assert.Equal(new List<string> { "lb.example.com" }, result.LoadBalancerHostnames);
+        Assert.Null(result.ExternalName);
+        Assert.Equal(2, result.PortDetails.Count);
+        Assert.Equal(30443, result.PortDetails[0].NodePort);
+        Assert.Equal("8443", result.PortDetails[0].TargetPort);
+        Assert.Null(result.PortDetails[1].NodePort);
+        Assert.Equal("UDP", result.PortDetails[1].Protocol);
+    }
+
+    [Fact]
+    public async Task UpdateService_ReplacesServiceDetails()
+    {
+        // Arrange
+        var service = new KubernetesService(_context);
+        await CreateTestCluster();
+
+        var dto = new ServiceCreateDto
+        {
+            ClusterName = "test-cluster",
+            Namespace = "default",
+            ServiceName = "test-service",
+            Ports = new List<int> { 80 },
+            ServiceType = "ClusterIP",
+            ClusterIps = new List<string> { "10.96.0.10" },
+            PortDetails = new List<ServicePortDto>
+            {
+                new() { Name = "http", Protocol = "TCP", Port = 80, TargetPort = "8080" }
+            }
+        };
+        var created = await service.CreateServiceAsync(dto);
+
+        dto.ServiceType = "ExternalName";
+        dto.ClusterIps = new List<string>();
+        dto.ExternalName = "db.example.com";
+        dto.PortDetails = new List<ServicePortDto>();
+
+        // Act
+        var result = await service.UpdateServiceAsync(created.Id, dto);
+
+        // Assert
+        Assert.Equal("ExternalName", result.ServiceType);
+        Assert.Empty(result.ClusterIps);
+        Assert.Equal("db.example.com", result.ExternalName);
+        Assert.Empty(result.PortDetails);
+    }
+
+    [Fact]
+    public async Task UpdateService_StoresEndpointCounts()
+    {
+        // Arrange
+        var service = new KubernetesService(_context);
+        await CreateTestCluster();
+
+        var dto = new ServiceCreateDto
+        {
+            ClusterName = "test-cluster",
+            Namespace = "default",
+            ServiceName = "test-service",
+            Ports = new List<int> { 80 },
+            ServiceType = "ClusterIP",
+            ReadyEndpoints = 3
+        };
+        var created = await service.CreateServiceAsync(dto);
+
+        dto.ReadyEndpoints = 1;
+        dto.NotReadyEndpoints = 2;
+
+        // Act
+        var result = await service.UpdateServiceAsync(created.Id, dto);
+
+        // Assert
+        Assert.Equal(3, created.ReadyEndpoints);
+        Assert.Equal(1, result.ReadyEndpoints);
+        Assert.Equal(2, result.NotReadyEndpoints);
+    }
+
+    private static ServiceCreateDto CreateTestServiceDto(string serviceName) => new()
+    {
+        ClusterName = "test-cluster",
+        Namespace = "default",
+        ServiceName = serviceName,
+        Ports = new List<int> { 80 },
+        ServiceType = "ClusterIP"
+    };
+
+    [Fact]
+    public async Task BulkApplyServices_CreatesUpdatesAndDeletes()
+    {
+        // Arrange
+        var service = new KubernetesService(_context);
+        await CreateTestCluster();
+        await service.CreateServiceAsync(CreateTestServiceDto("web"));
+        await service.CreateServiceAsync(CreateTestServiceDto("stale"));
+
+        var web = CreateTestServiceDto("web");
+        web.ReadyEndpoints = 2;
+        var request = new BulkRequestDto<ServiceCreateDto>
+        {
+            ClusterName = "test-cluster",
+            Upserts = new List<ServiceCreateDto> { web, CreateTestServiceDto("new") },
+            Deletes = new List<BulkDeleteDto>
+            {
+                new() { Namespace = "default", Name = "stale" },
+                new() { Namespace = "default", Name = "never-synced" }
+            }
+        };
+
+        // Act
+        var result = await service.BulkApplyServicesAsync(request);
+
+        // Assert
+        Assert.Equal(4, result.Results.Count);
+        Assert.All(result.Results, r => Assert.True(r.Success, r.Error));
+        Assert.Equal(new[] { "upsert", "upsert", "delete", "delete" }, result.Results.Select(r => r.Operation));
+
+        var services = (await service.GetServicesByClusterAsync("test-cluster")).ToList();
+        Assert.Equal(new[] { "new", "web" }, services.Select(s => s.ServiceName).OrderBy(n => n));
+        Assert.Equal(2, services.Single(s => s.ServiceName == "web").ReadyEndpoints);
+    }
+
+    [Fact]
+    public async Task BulkApplyServices_FailsOnlyInvalidItems()
+    {
+        // Arrange
+        var service = new KubernetesService(_context);
+        await CreateTestCluster();
+
+        var invalid = CreateTestServiceDto("invalid");
+        invalid.ServiceType = null!;
+        var request = new BulkRequestDto<ServiceCreateDto>
+        {
+            ClusterName = "test-cluster",
+            Upserts = new List<ServiceCreateDto> { CreateTestServiceDto("web"), invalid, CreateTestServiceDto("") }
+        };
+
+        // Act
+        var result = await service.BulkApplyServicesAsync(request);
+
+        // Assert
+        Assert.True(result.Results[0].Success);
+        Assert.False(result.Results[1].Success);
+        Assert.Equal("ServiceType is required", result.Results[1].Error);
+        Assert.False(result.Results[2].Success);
+        Assert.Equal("name is required", result.Results[2].Error);
+
+        var services = await service.GetServicesByClusterAsync("test-cluster");
+        Assert.Equal("web", Assert.Single(services).ServiceName);
+    }
+
+    [Fact]
+    public async Task BulkApplyServices_FailsEveryItem_WhenClusterNotFound()
+    {
+        // Arrange
+        var service = new KubernetesService(_context);
+        var request = new BulkRequestDto<ServiceCreateDto>
+        {
+            ClusterName = "non-existent-cluster",
+            Upserts = new List<ServiceCreateDto> { CreateTestServiceDto("web") },
+            Deletes = new List<BulkDeleteDto> { new() { Namespace = "default", Name = "stale" } }
+        };
+
+        // Act
+        var result = await service.BulkApplyServicesAsync(request);
+
+        // Assert
+        Assert.Equal(2, result.Results.Count);
+        Assert.All(result.Results, r =>
+        {
+            Assert.False(r.Success);
+            Assert.Equal("Cluster 'non-existent-cluster' not found", r.Error);
+        });
+    }
 }
\ No newline at end of file
@@ -0,0 +1,195 @@
+using KubernetesTracker.Api.Data;
+using Microsoft.EntityFrameworkCore;
+
+namespace KubernetesTracker.Tests;
+
+public class NodeServiceTests : IDisposable
+{
+    private readonly DbContextOptions<ApplicationDbContext> _options;
+    private readonly ApplicationDbContext _context;
+
+    public NodeServiceTests()
+    {
+        _options = new DbContextOptionsBuilder<ApplicationDbContext>()
+            .UseInMemoryDatabase(databaseName: Guid.NewGuid().ToString())
+            .Options;
+
+        _context = new ApplicationDbContext(_options);
+    }
+
+    public void Dispose()
+    {
+        _context.Database.EnsureDeleted();
+        _context.Dispose();
+    }
+
+    private async Task<Cluster> CreateTestCluster()
+    {
+        var cluster = new Cluster
+        {
+            ClusterName = "test-cluster",
+            ApiserverVersion = "1.0.0",
+            KubeletVersions = new List<string> { "1.0.0" },
+            KernelVersions = new List<string> { "5.0.0" }
+        };
+
+        _context.Clusters.Add(cluster);
+        await _context.SaveChangesAsync();
+        return cluster;
+    }
+
+    private static NodeCreateDto CreateTestNodeDto(string nodeName) => new()
+    {
+        ClusterName = "test-cluster",
+        NodeName = nodeName,
+        Roles = new List<string> { "worker" },
+        OsImage = "Ubuntu 22.04.4 LTS",
+        OperatingSystem = "linux",
+        Architecture = "amd64",
+        ContainerRuntimeVersion = "containerd://1.7.12",
+        KubeletVersion = "v1.29.4",
+        KernelVersion = "5.15.0-105-generic",
+        ProviderId = "aws:///eu-west-1a/i-0123456789abcdef0",
+        InstanceType = "m5.large",
+        Zone = "eu-west-1a",
+        Region = "eu-west-1",
+        Capacity = new NodeResourcesDto { CpuMillicores = 2000, MemoryBytes = 8_000_000_000, Pods = 110 },
+        Allocatable = new NodeResourcesDto { CpuMillicores = 1930, MemoryBytes = 7_000_000_000, Pods = 110 },
+        Taints = new List<NodeTaintDto>
+        {
+            new() { Key = "dedicated", Value = "batch", Effect = "NoSchedule" }
+        },
+        Ready = true
+    };
+
+    [Fact]
+    public async Task CreateNode_Success()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+        await CreateTestCluster();
+
+        // Act
+        var result = await service.CreateNodeAsync(CreateTestNodeDto("node-1"));
+
+        // Assert
+        Assert.NotNull(result);
+        Assert.Equal("node-1", result.NodeName);
+        Assert.Equal("test-cluster", result.ClusterName);
+        Assert.Equal(new List<string> { "worker" }, result.Roles);
+        Assert.Equal(2000, result.Capacity.CpuMillicores);
+        Assert.Equal(7_000_000_000, result.Allocatable.MemoryBytes);
+        Assert.Single(result.Taints);
+        Assert.Equal("NoSchedule", result.Taints[0].Effect);
+        Assert.True(result.Ready);
+    }
+
+    [Fact]
+    public async Task CreateNode_ThrowsNotFoundException_WhenClusterNotFound()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+        var dto = CreateTestNodeDto("node-1");
+        dto.ClusterName = "non-existent-cluster";
+
+        // Act & Assert
+        await Assert.ThrowsAsync<NotFoundException>(() =>
+            service.CreateNodeAsync(dto));
+    }
+
+    [Fact]
+    public async Task CreateNode_ThrowsDbUpdateException_WhenDuplicate()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+        await CreateTestCluster();
+
+        // First creation
+        await service.CreateNodeAsync(CreateTestNodeDto("node-1"));
+
+        // Act & Assert - Second creation should fail
+        await Assert.ThrowsAsync<DbUpdateException>(() =>
+            service.CreateNodeAsync(CreateTestNodeDto("node-1")));
+    }
+
+    [Fact]
+    public async Task UpdateNode_ReplacesNodeDetails()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+        await CreateTestCluster();
+        var created = await service.CreateNodeAsync(CreateTestNodeDto("node-1"));
+
+        var dto = CreateTestNodeDto("node-1");
+        dto.KubeletVersion = "v1.30.1";
+        dto.Taints = new List<NodeTaintDto>();
+        dto.Ready = false;
+
+        // Act
+        var result = await service.UpdateNodeAsync(created.Id, dto);
+
+        // Assert
+        Assert.Equal("v1.30.1", result.KubeletVersion);
+        Assert.Empty(result.Taints);
+        Assert.False(result.Ready);
+    }
+
+    [Fact]
+    public async Task GetNodesByCluster_ReturnsCorrectNodes()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+        await CreateTestCluster();
+        await service.CreateNodeAsync(CreateTestNodeDto("node-1"));
+        await service.CreateNodeAsync(CreateTestNodeDto("node-2"));
+
+        // Act
+        var results = await service.GetNodesByClusterAsync("test-cluster");
+
+        // Assert
+        Assert.Equal(2, results.Count());
+    }
+
+    [Fact]
+    public async Task DeleteNode_ThrowsNotFoundException_WhenNodeNotFound()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+
+        // Act & Assert
+        await Assert.ThrowsAsync<NotFoundException>(() =>
+            service.DeleteNodeAsync(999));
+    }
+
+    [Fact]
+    public async Task BulkApplyNodes_UpdatesAndDeletesByName()
+    {
+        // Arrange
+        var service = new NodeService(_context);
+        await CreateTestCluster();
+        await service.CreateNodeAsync(CreateTestNodeDto("node-1"));
+        await service.CreateNodeAsync(CreateTestNodeDto("node-2"));
+
+        var node = CreateTestNodeDto("node-1");
+        node.Ready = false;
+        var request = new BulkRequestDto<NodeCreateDto>
+        {
+            ClusterName = "test-cluster",
+            Upserts = new List<NodeCreateDto> { node },
+            Deletes = new List<BulkDeleteDto> { new() { Name = "node-2" } }
+        };
+
+        // Act
+        var result = await service.BulkApplyNodesAsync(request);
+
+        // Assert
+        Assert.All(result.Results, r =>
+        {
+            Assert.True(r.Success, r.Error);
+            Assert.Null(r.Namespace);
+        });
+        var remaining = Assert.Single(await service.GetNodesByClusterAsync("test-cluster"));
+        Assert.Equal("node-1", remaining.NodeName);
+        Assert.False(remaining.Ready);
+    }
+}
\ No newline at end of file
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	crdGVR = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	}
+	apiServiceGVR = schema.GroupVersionResource{
+		Group:    "apiregistration.k8s.io",
+		Version:  "v1",
+		Resource: "apiservices",
+	}
+)
+
+type APIGroupInfo struct {
+	Group            string   `json:"group"`
+	Versions         []string `json:"versions"`
+	PreferredVersion string   `json:"preferredVersion"`
+	// Source is "builtin", "crd" or "aggregated"
+	Source string   `json:"source"`
+	CRDs   []string `json:"crds"`
+}
+
+type Addon struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Namespace string `json:"namespace,omitempty"`
+	// DetectedBy is "crd" or "deployment"
+	DetectedBy string `json:"detectedBy"`
+}
+
+type knownAddon struct {
+	name string
+	// crds are matched on the full CRD name
+	crds []string
+	// images are matched as a path suffix of a container image repository
+	images []string
+}
+
+var knownAddons = []knownAddon{
+	{name: "cert-manager", crds: []string{"certificates.cert-manager.io"}, images: []string{"cert-manager-controller"}},
+	{name: "external-dns", crds: []string{"dnsendpoints.externaldns.k8s.io"}, images: []string{"external-dns/external-dns", "bitnami/external-dns"}},
+	{name: "istio", crds: []string{"virtualservices.networking.istio.io"}, images: []string{"istio/pilot"}},
+	{name: "argo-cd", crds: []string{"applications.argoproj.io"}, images: []string{"argoproj/argocd"}},
+	{name: "argo-rollouts", crds: []string{"rollouts.argoproj.io"}, images: []string{"argoproj/argo-rollouts"}},
+	{name: "flux", crds: []string{"kustomizations.kustomize.toolkit.fluxcd.io"}, images: []string{"fluxcd/kustomize-controller"}},
+	{name: "prometheus-operator", crds: []string{"prometheuses.monitoring.coreos.com"}, images: []string{"prometheus-operator/prometheus-operator"}},
+	{name: "external-secrets", crds: []string{"externalsecrets.external-secrets.io"}, images: []string{"external-secrets/external-secrets"}},
+	{name: "kyverno", crds: []string{"clusterpolicies.kyverno.io"}, images: []string{"kyverno/kyverno"}},
+	{name: "traefik", crds: []string{"ingressroutes.traefik.io", "ingressroutes.traefik.containo.us"}, images: []string{"traefik"}},
+	{name: "metallb", crds: []string{"ipaddresspools.metallb.io"}, images: []string{"metallb/controller"}},
+	{name: "longhorn", crds: []string{"volumes.longhorn.io"}, images: []string{"longhornio/longhorn-manager"}},
+	{name: "ingress-nginx", images: []string{"ingress-nginx/controller"}},
+	{name: "metrics-server", images: []string{"metrics-server/metrics-server"}},
+}
+
+// collectAPIInventory groups every served API group by where it comes from and
+// detects well-known add-ons from their CRDs and controller deployments
+func (w *ResourceWatcher) collectAPIInventory() ([]APIGroupInfo, []Addon, error) {
+	groupList, err := w.clientset.Discovery().ServerGroups()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get server groups: %v", err)
+	}
+
+	crdList, err := w.dynamicClient.Resource(crdGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list customresourcedefinitions: %v", err)
+	}
+
+	apiServiceList, err := w.dynamicClient.Resource(apiServiceGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list apiservices: %v", err)
+	}
+
+	crdsByGroup := make(map[string][]string)
+	crdsByName := make(map[string]*unstructured.Unstructured)
+	for i := range crdList.Items {
+		crd := &crdList.Items[i]
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		crdsByGroup[group] = append(crdsByGroup[group], crd.GetName())
+		crdsByName[crd.GetName()] = crd
+	}
+
+	// APIServices without a service are served by the kube-apiserver itself
+	// (builtins and CRDs); those with one are aggregated API servers
+	aggregatedGroups := make(map[string]struct{})
+	for _, apiService := range apiServiceList.Items {
+		service, found, _ := unstructured.NestedMap(apiService.Object, "spec", "service")
+		if !found || service == nil {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(apiService.Object, "spec", "group")
+		aggregatedGroups[group] = struct{}{}
+	}
+
+	groups := make([]APIGroupInfo, 0, len(groupList.Groups))
+	for _, group := range groupList.Groups {
+		versions := make([]string, 0, len(group.Versions))
+		for _, version := range group.Versions {
+			versions = append(versions, version.Version)
+		}
+
+		source := "builtin"
+		crds := crdsByGroup[group.Name]
+		if _, ok := aggregatedGroups[group.Name]; ok {
+			source = "aggregated"
+		} else if len(crds) > 0 {
+			source = "crd"
+		}
+		if crds == nil {
+			crds = []string{}
+		}
+		sort.Strings(crds)
+
+		groups = append(groups, APIGroupInfo{
+			Group:            group.Name,
+			Versions:         versions,
+			PreferredVersion: group.PreferredVersion.Version,
+			Source:           source,
+			CRDs:             crds,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Group < groups[j].Group
+	})
+
+	addons, err := w.detectAddons(crdsByName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groups, addons, nil
+}
+
+func (w *ResourceWatcher) detectAddons(crdsByName map[string]*unstructured.Unstructured) ([]Addon, error) {
+	deployments, err := w.clientset.AppsV1().Deployments(corev1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
+	}
+
+	addons := []Addon{}
+	for _, known := range knownAddons {
+		var addon *Addon
+
+		// a deployment image tag is the most reliable version source since CRDs
+		// often outlive the controller that installed them
+		for _, deployment := range deployments.Items {
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				repository, tag := splitImage(container.Image)
+				if !matchesImage(repository, known.images) {
+					continue
+				}
+				addon = &Addon{
+					Name:       known.name,
+					Version:    tag,
+					Namespace:  deployment.Namespace,
+					DetectedBy: "deployment",
+				}
+				break
+			}
+			if addon != nil {
+				break
+			}
+		}
+
+		if addon == nil {
+			for _, name := range known.crds {
+				crd, ok := crdsByName[name]
+				if !ok {
+					continue
+				}
+				addon = &Addon{
+					Name:       known.name,
+					Version:    crdVersionLabel(crd.GetLabels()),
+					DetectedBy: "crd",
+				}
+				break
+			}
+		}
+
+		if addon != nil {
+			addons = append(addons, *addon)
+		}
+	}
+
+	return addons, nil
+}
+
+func matchesImage(repository string, images []string) bool {
+	for _, image := range images {
+		if repository == image || strings.HasSuffix(repository, "/"+image) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitImage splits an image reference into repository and tag, ignoring any
+// registry port and digest
+func splitImage(image string) (string, string) {
+	if at := strings.Index(image, "@"); at >= 0 {
+		image = image[:at]
+	}
+	slash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > slash {
+		return image[:colon], image[colon+1:]
+	}
+	return image, ""
+}
+
+func crdVersionLabel(labels map[string]string) string {
+	if version := labels["app.kubernetes.io/version"]; version != "" {
+		return version
+	}
+	// helm charts label CRDs with "<chart>-<version>"
+	if chart := labels["helm.sh/chart"]; chart != "" {
+		if dash := strings.LastIndex(chart, "-"); dash >= 0 {
+			return chart[dash+1:]
+		}
+	}
+	return ""
+}
+
+func (w *ResourceWatcher) newCRDInformer() cache.Controller {
+	crdListWatcher := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return w.dynamicClient.Resource(crdGVR).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return w.dynamicClient.Resource(crdGVR).Watch(context.TODO(), options)
+		},
+	}
+
+	_, crdController := cache.NewInformer(
+		crdListWatcher,
+		&unstructured.Unstructured{},
+		0,
+		cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(obj interface{}, isInInitialList bool) {
+				if !isInInitialList {
+					w.requestClusterInfoRefresh("crd added")
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldCRD, oldOk := oldObj.(*unstructured.Unstructured)
+				newCRD, newOk := newObj.(*unstructured.Unstructured)
+				if !oldOk || !newOk {
+					slog.Error("Unexpected type for crd object")
+					return
+				}
+				// status-only updates don't change the inventory
+				if oldCRD.GetGeneration() != newCRD.GetGeneration() ||
+					!labelsEqual(oldCRD.GetLabels(), newCRD.GetLabels()) {
+					w.requestClusterInfoRefresh("crd updated")
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				w.requestClusterInfoRefresh("crd deleted")
+			},
+		},
+	)
+
+	return crdController
+}
+
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image          string
+		wantRepository string
+		wantTag        string
+	}{
+		{image: "quay.io/jetstack/cert-manager-controller:v1.14.4", wantRepository: "quay.io/jetstack/cert-manager-controller", wantTag: "v1.14.4"},
+		{image: "registry.local:5000/jetstack/cert-manager-controller:v1.14.4", wantRepository: "registry.local:5000/jetstack/cert-manager-controller", wantTag: "v1.14.4"},
+		{image: "registry.local:5000/traefik", wantRepository: "registry.local:5000/traefik"},
+		{image: "registry.k8s.io/ingress-nginx/controller:v1.10.0@sha256:42b3f0e5d0846876b1791cd3afeb5f1cbbe4259d6f35651dcc1b5c980925379c", wantRepository: "registry.k8s.io/ingress-nginx/controller", wantTag: "v1.10.0"},
+		{image: "registry.local:5000/traefik@sha256:42b3f0e5d0846876b1791cd3afeb5f1cbbe4259d6f35651dcc1b5c980925379c", wantRepository: "registry.local:5000/traefik"},
+		{image: "traefik:v3.0", wantRepository: "traefik", wantTag: "v3.0"},
+		{image: "traefik", wantRepository: "traefik"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			repository, tag := splitImage(tt.image)
+			if repository != tt.wantRepository || tag != tt.wantTag {
+				t.Errorf("splitImage(%q) = %q, %q, want %q, %q", tt.image, repository, tag, tt.wantRepository, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestMatchesImage(t *testing.T) {
+	tests := []struct {
+		repository string
+		images     []string
+		want       bool
+	}{
+		{repository: "traefik", images: []string{"traefik"}, want: true},
+		{repository: "docker.io/library/traefik", images: []string{"traefik"}, want: true},
+		{repository: "registry.k8s.io/ingress-nginx/controller", images: []string{"ingress-nginx/controller"}, want: true},
+		{repository: "docker.io/bitnami/external-dns", images: []string{"external-dns/external-dns", "bitnami/external-dns"}, want: true},
+		// a suffix only matches on a path boundary
+		{repository: "ghcr.io/example/mytraefik", images: []string{"traefik"}, want: false},
+		{repository: "registry.k8s.io/other/controller", images: []string{"ingress-nginx/controller"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			if got := matchesImage(tt.repository, tt.images); got != tt.want {
+				t.Errorf("matchesImage(%q, %v) = %v, want %v", tt.repository, tt.images, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCRDVersionLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{name: "app version", labels: map[string]string{"app.kubernetes.io/version": "v1.14.4"}, want: "v1.14.4"},
+		{name: "helm chart", labels: map[string]string{"helm.sh/chart": "cert-manager-v1.13.2"}, want: "v1.13.2"},
+		{name: "app version before helm chart", labels: map[string]string{"app.kubernetes.io/version": "v1.14.4", "helm.sh/chart": "cert-manager-v1.13.2"}, want: "v1.14.4"},
+		{name: "helm chart without a version", labels: map[string]string{"helm.sh/chart": "cert"}, want: ""},
+		{name: "no labels", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crdVersionLabel(tt.labels); got != tt.want {
+				t.Errorf("crdVersionLabel(%v) = %q, want %q", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func testDeployment(namespace, name string, images ...string) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for _, image := range images {
+		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: name, Image: image})
+	}
+	return deployment
+}
+
+func testCRD(name string, labels map[string]string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(name)
+	crd.SetLabels(labels)
+	crd.SetGeneration(1)
+	return crd
+}
+
+func TestDetectAddons(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployments []runtime.Object
+		crds        []*unstructured.Unstructured
+		want        []Addon
+	}{
+		{
+			name: "deployment image over CRD labels",
+			deployments: []runtime.Object{
+				testDeployment("cert-manager", "cert-manager", "quay.io/jetstack/cert-manager-controller:v1.14.4"),
+			},
+			crds: []*unstructured.Unstructured{
+				testCRD("certificates.cert-manager.io", map[string]string{"app.kubernetes.io/version": "v1.12.0"}),
+			},
+			want: []Addon{{Name: "cert-manager", Version: "v1.14.4", Namespace: "cert-manager", DetectedBy: "deployment"}},
+		},
+		{
+			name: "CRD labels without a deployment",
+			crds: []*unstructured.Unstructured{
+				testCRD("certificates.cert-manager.io", map[string]string{"app.kubernetes.io/version": "v1.12.0"}),
+				testCRD("applications.argoproj.io", map[string]string{"helm.sh/chart": "argo-cd-5.51.6"}),
+				testCRD("kustomizations.kustomize.toolkit.fluxcd.io", nil),
+			},
+			want: []Addon{
+				{Name: "cert-manager", Version: "v1.12.0", DetectedBy: "crd"},
+				{Name: "argo-cd", Version: "5.51.6", DetectedBy: "crd"},
+				{Name: "flux", Version: "", DetectedBy: "crd"},
+			},
+		},
+		{
+			name: "image from a private registry with a digest",
+			deployments: []runtime.Object{
+				testDeployment("ingress-nginx", "ingress-nginx-controller", "registry.local:5000/ingress-nginx/controller:v1.10.0@sha256:42b3f0e5d0846876b1791cd3afeb5f1cbbe4259d6f35651dcc1b5c980925379c"),
+			},
+			want: []Addon{{Name: "ingress-nginx", Version: "v1.10.0", Namespace: "ingress-nginx", DetectedBy: "deployment"}},
+		},
+		{
+			name: "sidecar image of another add-on",
+			deployments: []runtime.Object{
+				testDeployment("apps", "web", "example.com/web:1.0", "traefik:v3.0"),
+			},
+			want: []Addon{{Name: "traefik", Version: "v3.0", Namespace: "apps", DetectedBy: "deployment"}},
+		},
+		{
+			name: "nothing known",
+			deployments: []runtime.Object{
+				testDeployment("apps", "web", "example.com/web:1.0"),
+			},
+			crds: []*unstructured.Unstructured{testCRD("widgets.example.com", nil)},
+			want: []Addon{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _, _ := newTestWatcher(t, nil, testConfig(0), tt.deployments...)
+			crdsByName := make(map[string]*unstructured.Unstructured)
+			for _, crd := range tt.crds {
+				crdsByName[crd.GetName()] = crd
+			}
+
+			addons, err := w.detectAddons(crdsByName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(addons, tt.want) {
+				t.Errorf("detectAddons() = %+v, want %+v", addons, tt.want)
+			}
+		})
+	}
+}
+
+func TestCRDInformerRefresh(t *testing.T) {
+	w, _, _ := newTestWatcher(t, nil, testConfig(0))
+	dynamicClient := w.dynamicClient.(*dynamicfake.FakeDynamicClient)
+	watching := make(chan struct{}, 1)
+	dynamicClient.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher, err := dynamicClient.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		watching <- struct{}{}
+		return true, watcher, nil
+	})
+
+	crds := dynamicClient.Resource(crdGVR)
+	crd, err := crds.Create(context.Background(), testCRD("certificates.cert-manager.io", map[string]string{"app.kubernetes.io/version": "v1.14.4"}), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	informer := w.newCRDInformer()
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("crd informer didn't sync")
+	}
+	<-watching
+
+	// expectRefresh checks whether a refresh was requested, waiting long
+	// enough for the informer to deliver the event when none is expected
+	expectRefresh := func(step string, want bool) {
+		t.Helper()
+		select {
+		case reason := <-w.clusterInfoTrigger:
+			if !want {
+				t.Errorf("%s: refresh requested (%s), want none", step, reason)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no refresh requested", step)
+			}
+		}
+	}
+	update := func(step string, mutate func(*unstructured.Unstructured), want bool) {
+		t.Helper()
+		crd = crd.DeepCopy()
+		mutate(crd)
+		if crd, err = crds.Update(context.Background(), crd, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		expectRefresh(step, want)
+	}
+
+	expectRefresh("initial list", false)
+	update("status update", func(crd *unstructured.Unstructured) {
+		unstructured.SetNestedField(crd.Object, "True", "status", "conditions", "established")
+	}, false)
+	update("label change", func(crd *unstructured.Unstructured) {
+		crd.SetLabels(map[string]string{"app.kubernetes.io/version": "v1.15.0"})
+	}, true)
+	update("new version", func(crd *unstructured.Unstructured) {
+		crd.SetGeneration(crd.GetGeneration() + 1)
+	}, true)
+
+	if _, err := crds.Create(context.Background(), testCRD("applications.argoproj.io", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectRefresh("crd added", true)
+	if err := crds.Delete(context.Background(), "applications.argoproj.io", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectRefresh("crd deleted", true)
+}
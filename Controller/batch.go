@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	kindIngress = "ingress"
+	kindService = "service"
+	kindNode    = "node"
+
+	operationUpsert = "upsert"
+	operationDelete = "delete"
+
+	// queue operation for an item left in the outbox by a previous run
+	operationReplay = "replay"
+)
+
+// Change is a single resolved write for a sink. Payload is an IngressPayload,
+// ServicePayload or NodePayload for upserts and nil for deletes.
+type Change struct {
+	Kind      string
+	Operation string
+	Namespace string
+	Name      string
+	Payload   interface{}
+	// UID and ResourceVersion identify the object version the change was
+	// read from, or for a delete the last one the informer saw. They are
+	// empty for changes that don't come from a watch, like a once run.
+	UID             string
+	ResourceVersion string
+}
+
+func (c Change) Key() string {
+	if c.Namespace == "" {
+		return c.Name
+	}
+	return c.Namespace + "/" + c.Name
+}
+
+// BatchSink is implemented by sinks that can apply many changes of one kind in
+// a single round trip. The returned slice holds one error per change (nil on
+// success); a non-nil error means the whole batch failed.
+type BatchSink interface {
+	Sink
+	ApplyBatch(ctx context.Context, kind string, changes []Change) ([]error, error)
+}
+
+// applyBatch sends changes through the sink's batch support when it has it and
+// one at a time otherwise
+func applyBatch(ctx context.Context, sink Sink, kind string, changes []Change) ([]error, error) {
+	if batchSink, ok := sink.(BatchSink); ok {
+		return batchSink.ApplyBatch(ctx, kind, changes)
+	}
+	return applyChanges(ctx, sink, changes), nil
+}
+
+func applyChanges(ctx context.Context, sink Sink, changes []Change) []error {
+	errs := make([]error, len(changes))
+	for i, change := range changes {
+		errs[i] = applyChange(ctx, sink, change)
+	}
+	return errs
+}
+
+func applyChange(ctx context.Context, sink Sink, change Change) error {
+	switch change.Kind {
+	case kindIngress:
+		if change.Operation == operationDelete {
+			return sink.DeleteIngress(ctx, change.Namespace, change.Name)
+		}
+		return sink.UpsertIngress(ctx, change.Payload.(IngressPayload))
+	case kindService:
+		if change.Operation == operationDelete {
+			return sink.DeleteService(ctx, change.Namespace, change.Name)
+		}
+		return sink.UpsertService(ctx, change.Payload.(ServicePayload))
+	case kindNode:
+		if change.Operation == operationDelete {
+			return sink.DeleteNode(ctx, change.Name)
+		}
+		return sink.UpsertNode(ctx, change.Payload.(NodePayload))
+	}
+	return fmt.Errorf("unknown kind %q", change.Kind)
+}
+
+// store returns the informer cache of a kind
+func (w *ResourceWatcher) store(kind string) cache.Store {
+	switch kind {
+	case kindIngress:
+		return w.ingressStore
+	case kindService:
+		return w.serviceStore
+	}
+	return w.nodeStore
+}
+
+// resolveChange turns a queued item into the change to send, reading objects
+// from the informer cache rather than the API server. It reports false when an
+// update is for an object that is already gone; its delete is queued as well.
+// Replayed outbox items have no such delete queued, so they turn into one.
+func (w *ResourceWatcher) resolveChange(ctx context.Context, kind string, item workQueueItem) (Change, bool, error) {
+	change := Change{
+		Kind:            kind,
+		Operation:       operationUpsert,
+		Namespace:       item.namespace,
+		Name:            item.name,
+		UID:             item.uid,
+		ResourceVersion: item.resourceVersion,
+	}
+	if item.operation == "delete" {
+		change.Operation = operationDelete
+		return change, true, nil
+	}
+
+	_, span := tracer.Start(ctx, "cache get "+kind, trace.WithAttributes(itemAttributes(kind, item)...))
+	obj, exists, err := w.store(kind).GetByKey(item.key)
+	span.SetAttributes(attribute.Bool("found", exists))
+	endSpan(span, err)
+	if err != nil {
+		return change, false, err
+	}
+	if !exists {
+		if item.operation == operationReplay {
+			change.Operation = operationDelete
+			return change, true, nil
+		}
+		return change, false, nil
+	}
+
+	if object, err := meta.Accessor(obj); err == nil {
+		change.UID = string(object.GetUID())
+		change.ResourceVersion = object.GetResourceVersion()
+	}
+
+	switch o := obj.(type) {
+	case *networkingv1.Ingress:
+		change.Payload = w.createIngressPayload(o)
+	case *corev1.Service:
+		change.Payload = w.createServicePayload(o)
+	case *corev1.Node:
+		change.Payload = w.createNodePayload(o)
+	default:
+		return change, false, fmt.Errorf("unexpected type %T in %s cache", obj, kind)
+	}
+	return change, true, nil
+}
+
+func (w *ResourceWatcher) runBatchWorker(ctx context.Context, queue workqueue.RateLimitingInterface, kind string) {
+	for w.breaker.Wait(ctx) && w.processNextBatch(ctx, queue, kind) {
+	}
+}
+
+// collectBatch blocks for the first item and then keeps taking items until the
+// batch window closes or the batch is full. It must be the queue's only
+// consumer, otherwise Get could block with a partial batch checked out.
+func (w *ResourceWatcher) collectBatch(ctx context.Context, queue workqueue.RateLimitingInterface) ([]interface{}, bool) {
+	first, shutdown := queue.Get()
+	if shutdown {
+		return nil, false
+	}
+
+	items := []interface{}{first}
+	deadline := time.NewTimer(w.config.BatchWindow)
+	defer deadline.Stop()
+
+	for len(items) < w.config.BatchMaxSize {
+		if queue.Len() == 0 {
+			select {
+			case <-ctx.Done():
+				return items, true
+			case <-deadline.C:
+				return items, true
+			case <-time.After(50 * time.Millisecond):
+				continue
+			}
+		}
+
+		obj, shutdown := queue.Get()
+		if shutdown {
+			break
+		}
+		items = append(items, obj)
+	}
+
+	return items, true
+}
+
+func (w *ResourceWatcher) processNextBatch(ctx context.Context, queue workqueue.RateLimitingInterface, kind string) bool {
+	objs, ok := w.collectBatch(ctx, queue)
+	if !ok {
+		return false
+	}
+	defer func() {
+		for _, obj := range objs {
+			queue.Done(obj)
+		}
+	}()
+
+	// a key can be queued as both an update and a delete; only its most
+	// recent item is sent, earlier ones are superseded
+	latest := make(map[string]int)
+	for i, obj := range objs {
+		item, ok := obj.(workQueueItem)
+		if !ok {
+			continue
+		}
+		latest[item.key] = i
+	}
+
+	var changes []Change
+	var changeObjs []interface{}
+	var changeCtxs []context.Context
+	var seqs []uint64
+	for i, obj := range objs {
+		item, ok := obj.(workQueueItem)
+		if !ok {
+			queue.Forget(obj)
+			slog.Error("Expected workQueueItem in queue", "item", fmt.Sprintf("%#v", obj))
+			continue
+		}
+		if latest[item.key] != i {
+			queue.Forget(obj)
+			continue
+		}
+
+		itemCtx := w.dequeued(ctx, kind, item)
+		change, found, err := w.resolveChange(itemCtx, kind, item)
+		if err != nil {
+			w.requeueOrDrop(itemCtx, queue, kind, obj, item, err)
+			continue
+		}
+		if !found {
+			queue.Forget(obj)
+			continue
+		}
+
+		changes = append(changes, change)
+		changeObjs = append(changeObjs, obj)
+		changeCtxs = append(changeCtxs, itemCtx)
+	}
+
+	if len(changes) == 0 {
+		return true
+	}
+
+	// record the changes before sending so they survive a restart; they are
+	// removed again once the sink has them
+	if w.outbox != nil {
+		var err error
+		seqs, err = w.outbox.PutBatch(changes)
+		if err != nil {
+			for i, obj := range changeObjs {
+				w.requeueOrDrop(changeCtxs[i], queue, kind, obj, obj.(workQueueItem), err)
+			}
+			return true
+		}
+	}
+
+	// a batch carries the changes of many traces; it is part of the trace
+	// of a lone change and linked to the traces of several
+	batchCtx := ctx
+	var links []trace.Link
+	if len(changeCtxs) == 1 {
+		batchCtx = changeCtxs[0]
+	} else {
+		for _, changeCtx := range changeCtxs {
+			if spanContext := trace.SpanContextFromContext(changeCtx); spanContext.IsValid() {
+				links = append(links, trace.Link{SpanContext: spanContext})
+			}
+		}
+	}
+	batchCtx, span := tracer.Start(batchCtx, kind+" batch sync",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.String("kind", kind), attribute.Int("count", len(changes))))
+
+	slog.Debug("Sending batch", "kind", kind, "count", len(changes))
+	errs, err := applyBatch(batchCtx, w.sink, kind, changes)
+	if err != nil {
+		slog.Error("Batch failed", "kind", kind, "count", len(changes), "error", err)
+	}
+
+	// changes delivered or given up on leave the outbox together
+	var done []Change
+	var doneSeqs []uint64
+	failed := 0
+	for i, obj := range changeObjs {
+		itemErr := err
+		if itemErr == nil && i < len(errs) {
+			itemErr = errs[i]
+		}
+		if itemErr == nil {
+			queue.Forget(obj)
+			w.recordSyncSuccess(kind, obj.(workQueueItem))
+		} else {
+			failed++
+			if !w.requeueOrDrop(changeCtxs[i], queue, kind, obj, obj.(workQueueItem), itemErr) {
+				continue
+			}
+		}
+		if w.outbox != nil {
+			done = append(done, changes[i])
+			doneSeqs = append(doneSeqs, seqs[i])
+		}
+	}
+	if len(done) > 0 {
+		if err := w.outbox.RemoveBatch(done, doneSeqs); err != nil {
+			slog.Error("Failed to remove changes from the outbox", "kind", kind, "count", len(done), "error", err)
+		}
+	}
+
+	if failed > 0 {
+		slog.Warn("Batch finished with failures", "kind", kind, "count", len(changes), "failed", failed)
+		span.SetAttributes(attribute.Int("failed", failed))
+	}
+	endSpan(span, err)
+	return true
+}
+
+// requeueOrDrop retries a failed item with backoff and reports whether it gave
+// up on it instead. Without an outbox it gives up after five attempts; with
+// one it keeps retrying, the change stays on disk until it is delivered.
+// Changes the backend rejects outright are given up on at once, retrying
+// won't make them acceptable. Failures while the backend circuit is open
+// don't count as attempts, the item just waits in the queue for the workers
+// to resume. Retries stay in the trace of ctx.
+func (w *ResourceWatcher) requeueOrDrop(ctx context.Context, queue workqueue.RateLimitingInterface, kind string, obj interface{}, item workQueueItem, err error) bool {
+	attempt := queue.NumRequeues(obj) + 1
+	if permanentError(err) {
+		slog.Error("Sync rejected by the backend, dropping out of the queue", syncFailureAttrs(kind, item, attempt, err)...)
+		w.recordSyncFailure(kind, item, attempt, true, err)
+		queue.Forget(obj)
+		runtime.HandleError(err)
+		return true
+	}
+
+	if w.breaker.State() != circuitClosed {
+		w.rememberTrace(ctx, kind, item)
+		queue.Add(obj)
+		return false
+	}
+
+	if w.outbox != nil || attempt <= 5 {
+		w.rememberTrace(ctx, kind, item)
+		slog.Warn("Sync failed, retrying", syncFailureAttrs(kind, item, attempt, err)...)
+		w.recordSyncFailure(kind, item, attempt, false, err)
+		queue.AddRateLimited(obj)
+		return false
+	}
+
+	slog.Error("Sync failed, dropping out of the queue", syncFailureAttrs(kind, item, attempt, err)...)
+	w.recordSyncFailure(kind, item, attempt, true, err)
+	queue.Forget(obj)
+	runtime.HandleError(err)
+	return true
+}
+
+// syncFailureAttrs are the log fields of a failed sync, with the status the
+// backend answered with when it did
+func syncFailureAttrs(kind string, item workQueueItem, attempt int, err error) []interface{} {
+	attrs := []interface{}{"kind", kind, "namespace", item.namespace, "name", item.name, "operation", item.operation, "attempt", attempt}
+	if status := errorStatus(err); status != 0 {
+		attrs = append(attrs, "status", status)
+	}
+	return append(attrs, "error", err)
+}
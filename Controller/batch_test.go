@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRequeueOrDropLogsStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus interface{}
+	}{
+		{name: "backend answered", err: fmt.Errorf("sync: %w", &statusError{status: http.StatusServiceUnavailable, msg: "unavailable"}), wantStatus: float64(http.StatusServiceUnavailable)},
+		{name: "backend unreachable", err: fmt.Errorf("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			w, _, _ := newTestWatcher(t, nil, testConfig(0))
+			item := workQueueItem{key: "default/web", namespace: "default", name: "web", operation: "update"}
+
+			w.requeueOrDrop(context.Background(), w.serviceQueue, kindService, item, item, tt.err)
+
+			var record map[string]interface{}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("log record %q: %v", logs.String(), err)
+			}
+			if got := record["status"]; got != tt.wantStatus {
+				t.Errorf("logged status = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProcessNextBatchRequeuesFailedItems(t *testing.T) {
+	backend, server := newFakeBackend(t, "ingress", "service", "node")
+	backend.bulk = true
+	backend.rejected["bad"] = true
+	w, _, _ := newTestWatcher(t, NewRESTSink(server.URL, "test", http.DefaultClient), testConfig(0))
+
+	w.serviceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	items := make(map[string]workQueueItem)
+	for _, name := range []string{"web", "bad", "db"} {
+		w.serviceStore.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}})
+		items[name] = workQueueItem{key: "default/" + name, namespace: "default", name: name, operation: "update"}
+		w.serviceQueue.Add(items[name])
+	}
+
+	if !w.processNextBatch(context.Background(), w.serviceQueue, kindService) {
+		t.Fatal("processNextBatch() = false")
+	}
+
+	for name, item := range items {
+		want := 0
+		if name == "bad" {
+			want = 1
+		}
+		if got := w.serviceQueue.NumRequeues(item); got != want {
+			t.Errorf("%s requeued %d times, want %d", name, got, want)
+		}
+	}
+	if got, want := backend.names("service", "serviceName"), []string{"db", "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backend services = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+var errCircuitOpen = errors.New("backend circuit breaker is open")
+
+var (
+	circuitStateGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tracker_backend_circuit_state",
+		Help: "State of the backend circuit breaker: 0 closed, 1 open, 2 half-open.",
+	})
+	circuitTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tracker_backend_circuit_transitions_total",
+		Help: "Number of times the backend circuit breaker changed state, by new state.",
+	}, []string{"state"})
+	circuitRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tracker_backend_circuit_rejected_requests_total",
+		Help: "Backend requests refused without being sent because the circuit was open.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(circuitStateGauge, circuitTransitions, circuitRejected)
+}
+
+// CircuitBreaker is an http.RoundTripper that stops sending requests to the
+// backend after failureThreshold consecutive failures (transport errors and 5xx
+// responses). Once openTimeout has passed a single probe request is let
+// through; its outcome closes the circuit again or restarts the timeout.
+type CircuitBreaker struct {
+	transport        http.RoundTripper
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mu        sync.Mutex
+	state     circuitState
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewCircuitBreaker(transport http.RoundTripper, failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		transport:        transport,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+}
+
+func (b *CircuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !b.allow() {
+		circuitRejected.Inc()
+		return nil, errCircuitOpen
+	}
+
+	resp, err := b.transport.RoundTrip(req)
+	b.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
+
+// State reports the current state, moving an open circuit whose timeout has
+// passed to half-open
+func (b *CircuitBreaker) State() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkTimeout()
+	return b.state
+}
+
+// Ready reports whether requests can currently go out. It is false while the
+// circuit is open and while a half-open probe is in flight.
+func (b *CircuitBreaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkTimeout()
+	return b.state == circuitClosed || (b.state == circuitHalfOpen && !b.probing)
+}
+
+// Wait blocks until requests can go out or ctx is done. Workers call it before
+// taking items off their queue so that nothing is dequeued, and no retry is
+// used up, while the backend is known to be down.
+func (b *CircuitBreaker) Wait(ctx context.Context) bool {
+	for !b.Ready() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Second):
+		}
+	}
+	return true
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkTimeout()
+
+	switch b.state {
+	case circuitOpen:
+		return false
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.probing = false
+		if success {
+			b.setState(circuitClosed)
+		} else {
+			b.failures++
+			b.open()
+		}
+		return
+	}
+
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == circuitClosed && b.failures >= b.failureThreshold {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) checkTimeout() {
+	if b.state == circuitOpen && !time.Now().Before(b.openUntil) {
+		b.setState(circuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openUntil = time.Now().Add(b.openTimeout)
+	b.setState(circuitOpen)
+}
+
+func (b *CircuitBreaker) setState(state circuitState) {
+	if state == circuitClosed {
+		b.failures = 0
+	}
+	if b.state == state {
+		return
+	}
+
+	switch state {
+	case circuitOpen:
+		slog.Warn("Backend circuit breaker opened", "failures", b.failures, "openTimeout", b.openTimeout)
+	case circuitHalfOpen:
+		slog.Info("Backend circuit breaker half-open, probing the backend")
+	case circuitClosed:
+		slog.Info("Backend circuit breaker closed, backend is healthy again")
+	}
+
+	b.state = state
+	circuitStateGauge.Set(float64(state))
+	circuitTransitions.WithLabelValues(state.String()).Inc()
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	outcomeOK     = "ok"
+	outcome5xx    = "5xx"
+	outcomeError  = "error"
+	outcomeExpire = "expire"
+)
+
+// breakerBackend answers requests with the outcome it is set to and counts
+// the ones that reach it
+type breakerBackend struct {
+	outcome string
+	calls   int
+}
+
+func (b *breakerBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.calls++
+	switch b.outcome {
+	case outcomeError:
+		return nil, errors.New("connection refused")
+	case outcome5xx:
+		return dryRunResponse(req, http.StatusServiceUnavailable, nil), nil
+	}
+	return dryRunResponse(req, http.StatusOK, nil), nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	type step struct {
+		// outcome is what the backend answers, or outcomeExpire to let the
+		// open timeout pass instead of sending a request
+		outcome string
+		// rejected means the breaker fails the request without sending it
+		rejected  bool
+		wantState circuitState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens at the threshold",
+			steps: []step{
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcomeError, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitOpen},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcomeOK, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitClosed},
+			},
+		},
+		{
+			name: "fails fast while open",
+			steps: []step{
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitOpen},
+				{outcome: outcomeOK, rejected: true, wantState: circuitOpen},
+				{outcome: outcomeOK, rejected: true, wantState: circuitOpen},
+			},
+		},
+		{
+			name: "reopens when the probe fails",
+			steps: []step{
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitOpen},
+				{outcome: outcomeExpire, wantState: circuitHalfOpen},
+				{outcome: outcomeError, wantState: circuitOpen},
+				{outcome: outcomeOK, rejected: true, wantState: circuitOpen},
+			},
+		},
+		{
+			name: "closes when the probe succeeds",
+			steps: []step{
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitClosed},
+				{outcome: outcome5xx, wantState: circuitOpen},
+				{outcome: outcomeExpire, wantState: circuitHalfOpen},
+				{outcome: outcomeOK, wantState: circuitClosed},
+				// a closed circuit starts counting failures from zero
+				{outcome: outcome5xx, wantState: circuitClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &breakerBackend{}
+			breaker := NewCircuitBreaker(backend, 3, time.Hour)
+
+			for i, step := range tt.steps {
+				if step.outcome == outcomeExpire {
+					breaker.mu.Lock()
+					breaker.openUntil = time.Now()
+					breaker.mu.Unlock()
+				} else {
+					backend.outcome = step.outcome
+					calls := backend.calls
+					req, _ := http.NewRequest(http.MethodGet, "http://backend/api/service", nil)
+					_, err := breaker.RoundTrip(req)
+
+					if rejected := errors.Is(err, errCircuitOpen); rejected != step.rejected {
+						t.Errorf("step %d: rejected = %v, want %v", i, rejected, step.rejected)
+					}
+					if sent := backend.calls > calls; sent == step.rejected {
+						t.Errorf("step %d: request sent = %v, want %v", i, sent, !step.rejected)
+					}
+				}
+
+				if state := breaker.State(); state != step.wantState {
+					t.Errorf("step %d: state = %v, want %v", i, state, step.wantState)
+				}
+			}
+		})
+	}
+}
+
+// blockingBackend holds requests until release is closed
+type blockingBackend struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return dryRunResponse(req, http.StatusOK, nil), nil
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	backend := &blockingBackend{started: make(chan struct{}, 2), release: make(chan struct{})}
+	breaker := NewCircuitBreaker(backend, 1, time.Hour)
+	breaker.record(false)
+	breaker.mu.Lock()
+	breaker.openUntil = time.Now()
+	breaker.mu.Unlock()
+
+	if !breaker.Ready() {
+		t.Fatal("half-open breaker isn't ready before its probe")
+	}
+
+	probeDone := make(chan error)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, "http://backend/api/service", nil)
+		_, err := breaker.RoundTrip(req)
+		probeDone <- err
+	}()
+	<-backend.started
+
+	if breaker.Ready() {
+		t.Error("breaker is ready while its probe is in flight")
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://backend/api/service", nil)
+	if _, err := breaker.RoundTrip(req); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("second request during the probe: err = %v, want %v", err, errCircuitOpen)
+	}
+
+	close(backend.release)
+	if err := <-probeDone; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if len(backend.started) != 0 {
+		t.Error("a second request reached the backend during the probe")
+	}
+	if state := breaker.State(); state != circuitClosed {
+		t.Errorf("state after the probe = %v, want %v", state, circuitClosed)
+	}
+}
+
+func TestCircuitBreakerWait(t *testing.T) {
+	breaker := NewCircuitBreaker(&breakerBackend{}, 1, time.Hour)
+	if !breaker.Wait(context.Background()) {
+		t.Fatal("Wait() = false on a closed circuit")
+	}
+
+	breaker.record(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	if breaker.Wait(ctx) {
+		t.Error("Wait() = true on an open circuit")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() took %v to notice the cancel", elapsed)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	w, _, _ := newTestWatcher(t, nil, testConfig(0))
+	w.breaker = NewCircuitBreaker(&breakerBackend{}, 1, time.Hour)
+	server := httptest.NewServer(w.healthHandler())
+	t.Cleanup(server.Close)
+
+	readyz := func() int {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := readyz(); status != http.StatusOK {
+		t.Errorf("closed circuit: /readyz = %d, want %d", status, http.StatusOK)
+	}
+	w.breaker.record(false)
+	if status := readyz(); status != http.StatusServiceUnavailable {
+		t.Errorf("open circuit: /readyz = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	distributionEKS     = "eks"
+	distributionGKE     = "gke"
+	distributionAKS     = "aks"
+	distributionK3s     = "k3s"
+	distributionRKE2    = "rke2"
+	distributionKubeadm = "kubeadm"
+	distributionUnknown = "unknown"
+
+	providerAWS     = "aws"
+	providerGCP     = "gcp"
+	providerAzure   = "azure"
+	providerUnknown = "unknown"
+)
+
+// getClusterUID returns the uid of the kube-system namespace, which is created
+// with the cluster and never changes, unlike the configured cluster name
+func (w *ResourceWatcher) getClusterUID() (string, error) {
+	w.clusterUIDMu.Lock()
+	defer w.clusterUIDMu.Unlock()
+	if w.clusterUID != "" {
+		return w.clusterUID, nil
+	}
+
+	ns, err := w.clientset.CoreV1().Namespaces().Get(context.TODO(), metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	w.clusterUID = string(ns.UID)
+	return w.clusterUID, nil
+}
+
+// detectPlatform guesses the distribution and infrastructure provider from the
+// API server version suffix, node providerIDs and well-known node labels
+func detectPlatform(serverVersion string, nodes []corev1.Node) (string, string) {
+	distribution := distributionUnknown
+	provider := providerUnknown
+
+	switch {
+	case strings.Contains(serverVersion, "-eks-"):
+		distribution = distributionEKS
+	case strings.Contains(serverVersion, "-gke."):
+		distribution = distributionGKE
+	case strings.Contains(serverVersion, "+k3s"):
+		distribution = distributionK3s
+	case strings.Contains(serverVersion, "+rke2"):
+		distribution = distributionRKE2
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+
+		switch {
+		case strings.HasPrefix(node.Spec.ProviderID, "aws://"):
+			provider = providerAWS
+		case strings.HasPrefix(node.Spec.ProviderID, "gce://"):
+			provider = providerGCP
+		case strings.HasPrefix(node.Spec.ProviderID, "azure://"):
+			provider = providerAzure
+		}
+
+		if distribution != distributionUnknown {
+			continue
+		}
+
+		_, kubeadm := node.Annotations["kubeadm.alpha.kubernetes.io/cri-socket"]
+		switch {
+		case hasLabelPrefix(node.Labels, "eks.amazonaws.com/"):
+			distribution = distributionEKS
+		case hasLabelPrefix(node.Labels, "cloud.google.com/gke-"):
+			distribution = distributionGKE
+		case hasLabelPrefix(node.Labels, "kubernetes.azure.com/"):
+			distribution = distributionAKS
+		case strings.HasPrefix(node.Spec.ProviderID, "k3s://"),
+			strings.Contains(node.Status.NodeInfo.KubeletVersion, "+k3s"):
+			distribution = distributionK3s
+		case strings.Contains(node.Status.NodeInfo.KubeletVersion, "+rke2"):
+			distribution = distributionRKE2
+		case kubeadm:
+			distribution = distributionKubeadm
+		}
+	}
+
+	switch distribution {
+	case distributionEKS:
+		provider = providerAWS
+	case distributionGKE:
+		provider = providerGCP
+	case distributionAKS:
+		provider = providerAzure
+	}
+
+	return distribution, provider
+}
+
+func hasLabelPrefix(labels map[string]string, prefix string) bool {
+	for label := range labels {
+		if strings.HasPrefix(label, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// requestClusterInfoRefresh asks the cluster info loop to recollect and resend
+// cluster info. Requests arriving while one is already pending are coalesced.
+func (w *ResourceWatcher) requestClusterInfoRefresh(reason string) {
+	select {
+	case w.clusterInfoTrigger <- reason:
+	default:
+	}
+}
+
+// runClusterInfoLoop resends cluster info when something it is derived from
+// changes. Triggers are debounced so a rolling node upgrade produces one update
+// per debounce window instead of one per node; the ticker is only a safety net.
+func (w *ResourceWatcher) runClusterInfoLoop(ctx context.Context) {
+	ticker := time.NewTicker(w.config.ClusterInfoInterval)
+	defer ticker.Stop()
+
+	versionPoll := time.NewTicker(w.config.ClusterVersionPollInterval)
+	defer versionPoll.Stop()
+
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Context cancelled", "error", ctx.Err())
+			return
+		case reason := <-w.clusterInfoTrigger:
+			if debounce == nil {
+				slog.Info("Cluster info refresh requested", "reason", reason, "debounce", w.config.ClusterInfoDebounce)
+				debounce = time.After(w.config.ClusterInfoDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			if err := w.collectAndSendClusterInfo(); err != nil {
+				slog.Error("Triggered cluster info collection failed", "error", err)
+			}
+		case <-versionPoll.C:
+			// node events don't cover managed control planes, so poll the
+			// cheap /version endpoint for API server upgrades
+			serverVersion, err := w.clientset.Discovery().ServerVersion()
+			if err != nil {
+				slog.Warn("Failed to poll server version", "error", err)
+				continue
+			}
+			// lastAPIServerVersion is only set after a successful send, so this
+			// also retries a failed collection
+			if serverVersion.GitVersion != w.lastAPIServerVersion {
+				w.requestClusterInfoRefresh("api server version changed")
+			}
+		case <-ticker.C:
+			if err := w.collectAndSendClusterInfo(); err != nil {
+				slog.Error("Periodic cluster info collection failed", "error", err)
+			}
+		}
+	}
+}
+
+// nodeVersionsChanged reports whether an update touches any of the node fields
+// that feed into ClusterInfo
+func nodeVersionsChanged(oldNode, newNode *corev1.Node) bool {
+	oldInfo := oldNode.Status.NodeInfo
+	newInfo := newNode.Status.NodeInfo
+	return oldInfo.KubeletVersion != newInfo.KubeletVersion ||
+		oldInfo.KernelVersion != newInfo.KernelVersion ||
+		oldInfo.ContainerRuntimeVersion != newInfo.ContainerRuntimeVersion ||
+		oldInfo.OSImage != newInfo.OSImage ||
+		oldInfo.Architecture != newInfo.Architecture
+}
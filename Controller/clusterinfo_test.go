@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// clusterInfoSink counts the cluster info it is sent
+type clusterInfoSink struct {
+	*MemorySink
+	sends atomic.Int32
+}
+
+func (s *clusterInfoSink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	s.sends.Add(1)
+	return s.MemorySink.SendClusterInfo(ctx, info)
+}
+
+// serveVersion makes the fake discovery answer /version with the version
+// last passed to the returned setter
+func serveVersion(clientset *fake.Clientset, gitVersion string) func(string) {
+	var mu sync.Mutex
+	discovery := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	// reactors run in the caller of ServerVersion before it reads the
+	// faked version, so setting it there doesn't race with the read
+	clientset.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		discovery.FakedServerVersion = &version.Info{GitVersion: gitVersion}
+		return false, nil, nil
+	})
+	return func(v string) {
+		mu.Lock()
+		defer mu.Unlock()
+		gitVersion = v
+	}
+}
+
+func testNode(name, kubeletVersion string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "1"},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion:          kubeletVersion,
+				KernelVersion:           "6.1.0",
+				ContainerRuntimeVersion: "containerd://1.7.11",
+				OSImage:                 "Ubuntu 22.04.4 LTS",
+				Architecture:            "amd64",
+			},
+		},
+	}
+}
+
+func TestClusterInfoLoopDebouncesNodeUpdates(t *testing.T) {
+	nodes := []runtime.Object{testNode("node-a", "v1.28.5"), testNode("node-b", "v1.28.5"), testNode("node-c", "v1.28.5")}
+	sink := &clusterInfoSink{MemorySink: NewMemorySink()}
+	config := testConfig(0)
+	config.ClusterInfoDebounce = 200 * time.Millisecond
+	w, clientset, _ := newTestWatcher(t, sink, config, nodes...)
+	serveVersion(clientset, "v1.29.1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go w.runClusterInfoLoop(ctx)
+
+	// a heartbeat doesn't touch the inventoried fields
+	heartbeat := testNode("node-a", "v1.28.5")
+	heartbeat.ResourceVersion = "2"
+	w.handleNodeUpdate(testNode("node-a", "v1.28.5"), heartbeat)
+	time.Sleep(config.ClusterInfoDebounce + 100*time.Millisecond)
+	if got := sink.sends.Load(); got != 0 {
+		t.Fatalf("%d cluster info sends after a heartbeat, want none", got)
+	}
+
+	// a rolling upgrade updates the nodes one after another
+	for _, name := range []string{"node-a", "node-b", "node-c"} {
+		upgraded := testNode(name, "v1.29.1")
+		upgraded.ResourceVersion = "3"
+		w.handleNodeUpdate(testNode(name, "v1.28.5"), upgraded)
+	}
+
+	eventually(t, "cluster info send", func() bool { return sink.sends.Load() > 0 })
+	time.Sleep(config.ClusterInfoDebounce + 100*time.Millisecond)
+	if got := sink.sends.Load(); got != 1 {
+		t.Errorf("%d cluster info sends after the upgrade, want 1", got)
+	}
+}
+
+func TestClusterInfoLoopAPIServerUpgrade(t *testing.T) {
+	sink := &clusterInfoSink{MemorySink: NewMemorySink()}
+	config := testConfig(0)
+	config.ClusterInfoDebounce = 10 * time.Millisecond
+	config.ClusterVersionPollInterval = 20 * time.Millisecond
+	w, clientset, _ := newTestWatcher(t, sink, config, testNode("node-a", "v1.28.5"))
+	setVersion := serveVersion(clientset, "v1.28.5")
+
+	// the startup send records the API server version
+	if err := w.collectAndSendClusterInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go w.runClusterInfoLoop(ctx)
+
+	time.Sleep(10 * config.ClusterVersionPollInterval)
+	if got := sink.sends.Load(); got != 1 {
+		t.Fatalf("%d cluster info sends with an unchanged version, want 1", got)
+	}
+
+	setVersion("v1.29.1")
+	eventually(t, "cluster info send", func() bool { return sink.sends.Load() == 2 })
+	eventually(t, "new API server version", func() bool {
+		info := sink.ClusterInfo()
+		return info != nil && info.APIServerVersion == "v1.29.1"
+	})
+
+	// once sent, the new version is no longer a change
+	time.Sleep(10 * config.ClusterVersionPollInterval)
+	if got := sink.sends.Load(); got != 2 {
+		t.Errorf("%d cluster info sends after the upgrade, want 2", got)
+	}
+}
+
+func TestDetectPlatform(t *testing.T) {
+	node := func(providerID, kubeletVersion string, labels, annotations map[string]string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: labels, Annotations: annotations},
+			Spec:       corev1.NodeSpec{ProviderID: providerID},
+			Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KubeletVersion: kubeletVersion}},
+		}
+	}
+	kubeadmSocket := map[string]string{"kubeadm.alpha.kubernetes.io/cri-socket": "unix:///run/containerd/containerd.sock"}
+
+	tests := []struct {
+		name             string
+		serverVersion    string
+		nodes            []corev1.Node
+		wantDistribution string
+		wantProvider     string
+	}{
+		{
+			name:             "eks",
+			serverVersion:    "v1.29.1-eks-b9c9ed7",
+			nodes:            []corev1.Node{node("aws:///us-east-1a/i-0abc", "v1.29.0-eks-5e0fdde", nil, nil)},
+			wantDistribution: distributionEKS,
+			wantProvider:     providerAWS,
+		},
+		{
+			name:             "gke",
+			serverVersion:    "v1.28.5-gke.1217000",
+			nodes:            []corev1.Node{node("gce://project/europe-west1-b/gke-node", "v1.28.5-gke.1217000", nil, nil)},
+			wantDistribution: distributionGKE,
+			wantProvider:     providerGCP,
+		},
+		{
+			name:             "aks from node labels",
+			serverVersion:    "v1.28.3",
+			nodes:            []corev1.Node{node("azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks/virtualMachines/0", "v1.28.3", map[string]string{"kubernetes.azure.com/cluster": "rg"}, nil)},
+			wantDistribution: distributionAKS,
+			wantProvider:     providerAzure,
+		},
+		{
+			name:             "eks from node labels",
+			serverVersion:    "v1.29.1",
+			nodes:            []corev1.Node{node("", "v1.29.0", map[string]string{"eks.amazonaws.com/nodegroup": "default"}, nil)},
+			wantDistribution: distributionEKS,
+			wantProvider:     providerAWS,
+		},
+		{
+			name:             "k3s",
+			serverVersion:    "v1.28.6+k3s2",
+			nodes:            []corev1.Node{node("k3s://node", "v1.28.6+k3s2", nil, nil)},
+			wantDistribution: distributionK3s,
+			wantProvider:     providerUnknown,
+		},
+		{
+			name:             "k3s from the kubelet version",
+			serverVersion:    "v1.28.6",
+			nodes:            []corev1.Node{node("", "v1.28.6+k3s2", nil, nil)},
+			wantDistribution: distributionK3s,
+			wantProvider:     providerUnknown,
+		},
+		{
+			name:             "rke2 on aws",
+			serverVersion:    "v1.27.10+rke2r1",
+			nodes:            []corev1.Node{node("aws:///eu-west-1a/i-0def", "v1.27.10+rke2r1", nil, nil)},
+			wantDistribution: distributionRKE2,
+			wantProvider:     providerAWS,
+		},
+		{
+			name:             "rke2 from the kubelet version",
+			serverVersion:    "v1.27.10",
+			nodes:            []corev1.Node{node("", "v1.27.10+rke2r1", nil, nil)},
+			wantDistribution: distributionRKE2,
+			wantProvider:     providerUnknown,
+		},
+		{
+			name:             "kubeadm",
+			serverVersion:    "v1.29.2",
+			nodes:            []corev1.Node{node("", "v1.29.2", nil, kubeadmSocket)},
+			wantDistribution: distributionKubeadm,
+			wantProvider:     providerUnknown,
+		},
+		{
+			name:             "kubeadm on gcp",
+			serverVersion:    "v1.29.2",
+			nodes:            []corev1.Node{node("", "v1.29.2", nil, nil), node("gce://project/us-central1-a/vm", "v1.29.2", nil, kubeadmSocket)},
+			wantDistribution: distributionKubeadm,
+			wantProvider:     providerGCP,
+		},
+		{
+			name:             "unknown",
+			serverVersion:    "v1.29.2",
+			nodes:            []corev1.Node{node("", "v1.29.2", map[string]string{"kubernetes.io/os": "linux"}, nil)},
+			wantDistribution: distributionUnknown,
+			wantProvider:     providerUnknown,
+		},
+		{
+			name:             "no nodes",
+			serverVersion:    "v1.29.2",
+			wantDistribution: distributionUnknown,
+			wantProvider:     providerUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distribution, provider := detectPlatform(tt.serverVersion, tt.nodes)
+			if distribution != tt.wantDistribution || provider != tt.wantProvider {
+				t.Errorf("detectPlatform() = %s, %s, want %s, %s", distribution, provider, tt.wantDistribution, tt.wantProvider)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const deprecatedAPIsMetric = "apiserver_requested_deprecated_apis"
+
+type deprecatedAPI struct {
+	group        string
+	version      string
+	resource     string
+	deprecatedIn string
+	removedIn    string
+	replacement  string
+}
+
+// deprecatedAPIs lists beta APIs removed upstream, from the Kubernetes
+// deprecated API migration guide. Append new removals here as they are
+// announced.
+var deprecatedAPIs = []deprecatedAPI{
+	{"extensions", "v1beta1", "ingresses", "1.14", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io", "v1beta1", "ingresses", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io", "v1beta1", "ingressclasses", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"apiextensions.k8s.io", "v1beta1", "customresourcedefinitions", "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io", "v1beta1", "apiservices", "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io", "v1beta1", "mutatingwebhookconfigurations", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io", "v1beta1", "validatingwebhookconfigurations", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"authentication.k8s.io", "v1beta1", "tokenreviews", "1.19", "1.22", "authentication.k8s.io/v1"},
+	{"authorization.k8s.io", "v1beta1", "subjectaccessreviews", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"certificates.k8s.io", "v1beta1", "certificatesigningrequests", "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io", "v1beta1", "leases", "1.19", "1.22", "coordination.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "clusterroles", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "clusterrolebindings", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "roles", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "rolebindings", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io", "v1beta1", "priorityclasses", "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "csidrivers", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "csinodes", "1.17", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "storageclasses", "1.6", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "volumeattachments", "1.13", "1.22", "storage.k8s.io/v1"},
+	{"batch", "v1beta1", "cronjobs", "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io", "v1beta1", "endpointslices", "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io", "v1beta1", "events", "1.19", "1.25", "events.k8s.io/v1"},
+	{"autoscaling", "v2beta1", "horizontalpodautoscalers", "1.22", "1.25", "autoscaling/v2"},
+	{"policy", "v1beta1", "poddisruptionbudgets", "1.21", "1.25", "policy/v1"},
+	{"policy", "v1beta1", "podsecuritypolicies", "1.21", "1.25", ""},
+	{"node.k8s.io", "v1beta1", "runtimeclasses", "1.20", "1.25", "node.k8s.io/v1"},
+	{"autoscaling", "v2beta2", "horizontalpodautoscalers", "1.23", "1.26", "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta1", "flowschemas", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta1", "prioritylevelconfigurations", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "csistoragecapacities", "1.24", "1.27", "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta2", "flowschemas", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta2", "prioritylevelconfigurations", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta3", "flowschemas", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta3", "prioritylevelconfigurations", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+type DeprecatedAPIUsage struct {
+	Group        string `json:"group"`
+	Version      string `json:"version"`
+	Resource     string `json:"resource"`
+	DeprecatedIn string `json:"deprecatedIn,omitempty"`
+	RemovedIn    string `json:"removedIn"`
+	Replacement  string `json:"replacement,omitempty"`
+	// Served means discovery still lists the resource at this version
+	Served bool `json:"served"`
+	// Requested means a client used it since the API server started
+	Requested bool `json:"requested"`
+}
+
+type UpgradeReadiness struct {
+	CurrentVersion string `json:"currentVersion"`
+	TargetVersion  string `json:"targetVersion"`
+	// MetricsAvailable is false when /metrics couldn't be read, in which case
+	// every served API removed in the target version counts as a blocker
+	MetricsAvailable bool                 `json:"metricsAvailable"`
+	DeprecatedAPIs   []DeprecatedAPIUsage `json:"deprecatedApis"`
+	Blockers         []DeprecatedAPIUsage `json:"blockers"`
+}
+
+// collectUpgradeReadiness reports deprecated APIs the cluster still serves or
+// clients still call, and which of them would break on the next minor version
+func (w *ResourceWatcher) collectUpgradeReadiness(serverVersion string) (*UpgradeReadiness, error) {
+	major, minor, err := parseMinorVersion(serverVersion)
+	if err != nil {
+		return nil, err
+	}
+	target := fmt.Sprintf("%d.%d", major, minor+1)
+
+	usage := make(map[string]*DeprecatedAPIUsage)
+	usageFor := func(group, version, resource string) *DeprecatedAPIUsage {
+		key := fmt.Sprintf("%s/%s/%s", group, version, resource)
+		if u, ok := usage[key]; ok {
+			return u
+		}
+		u := &DeprecatedAPIUsage{Group: group, Version: version, Resource: resource}
+		for _, api := range deprecatedAPIs {
+			if api.group == group && api.version == version && api.resource == resource {
+				u.DeprecatedIn = api.deprecatedIn
+				u.RemovedIn = api.removedIn
+				u.Replacement = api.replacement
+			}
+		}
+		usage[key] = u
+		return u
+	}
+
+	servedResources := make(map[string]map[string]bool)
+	for _, api := range deprecatedAPIs {
+		groupVersion := api.version
+		if api.group != "" {
+			groupVersion = api.group + "/" + api.version
+		}
+
+		resources, ok := servedResources[groupVersion]
+		if !ok {
+			resources = make(map[string]bool)
+			// an error means the version isn't served, which is the expected
+			// case for long removed APIs
+			if list, err := w.clientset.Discovery().ServerResourcesForGroupVersion(groupVersion); err == nil {
+				for _, resource := range list.APIResources {
+					resources[resource.Name] = true
+				}
+			}
+			servedResources[groupVersion] = resources
+		}
+
+		if resources[api.resource] {
+			usageFor(api.group, api.version, api.resource).Served = true
+		}
+	}
+
+	requested, err := w.requestedDeprecatedAPIs()
+	metricsAvailable := err == nil
+	if err != nil {
+		slog.Warn("Failed to read metric", "metric", deprecatedAPIsMetric, "error", err)
+	}
+	for _, labels := range requested {
+		u := usageFor(labels["group"], labels["version"], labels["resource"])
+		u.Requested = true
+		if u.RemovedIn == "" {
+			u.RemovedIn = labels["removed_release"]
+		}
+	}
+
+	readiness := &UpgradeReadiness{
+		CurrentVersion:   fmt.Sprintf("%d.%d", major, minor),
+		TargetVersion:    target,
+		MetricsAvailable: metricsAvailable,
+		DeprecatedAPIs:   []DeprecatedAPIUsage{},
+		Blockers:         []DeprecatedAPIUsage{},
+	}
+
+	for _, u := range usage {
+		readiness.DeprecatedAPIs = append(readiness.DeprecatedAPIs, *u)
+
+		removedMajor, removedMinor, err := parseMinorVersion(u.RemovedIn)
+		if err != nil {
+			continue
+		}
+		removedByTarget := removedMajor < major || (removedMajor == major && removedMinor <= minor+1)
+		if removedByTarget && (u.Requested || (!metricsAvailable && u.Served)) {
+			readiness.Blockers = append(readiness.Blockers, *u)
+		}
+	}
+
+	sortDeprecatedAPIs(readiness.DeprecatedAPIs)
+	sortDeprecatedAPIs(readiness.Blockers)
+
+	return readiness, nil
+}
+
+// requestedDeprecatedAPIs scrapes the API server's own metrics. The metric only
+// covers requests served by the instance that answers the scrape since it last
+// restarted, so on HA control planes it is a sample rather than a full record.
+func (w *ResourceWatcher) requestedDeprecatedAPIs() ([]map[string]string, error) {
+	client := w.clientset.Discovery().RESTClient()
+	if client == nil {
+		return nil, fmt.Errorf("discovery client has no REST client")
+	}
+	body, err := client.Get().AbsPath("/metrics").DoRaw(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	requested := []map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, deprecatedAPIsMetric+"{") {
+			continue
+		}
+
+		end := strings.LastIndex(line, "}")
+		if end < 0 {
+			continue
+		}
+		// the gauge is 1 while the API is in use and 0 once it has been removed
+		if value := strings.TrimSpace(line[end+1:]); value == "0" {
+			continue
+		}
+		requested = append(requested, parseMetricLabels(line[len(deprecatedAPIsMetric)+1:end]))
+	}
+
+	return requested, scanner.Err()
+}
+
+// parseMetricLabels parses the `name="value",...` label set of a Prometheus
+// text format sample
+func parseMetricLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.Index(s, "=\"")
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(s[:eq], ","))
+		s = s[eq+2:]
+
+		var value strings.Builder
+		i := 0
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			value.WriteByte(s[i])
+		}
+		labels[name] = value.String()
+
+		if i >= len(s) {
+			break
+		}
+		s = s[i+1:]
+	}
+	return labels
+}
+
+// parseMinorVersion extracts major and minor from versions such as
+// "v1.29.3-eks-abcdef" or "1.25"
+func parseMinorVersion(version string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid version %q", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version %q: %v", version, err)
+	}
+
+	// managed distributions append suffixes like "27+" to the minor
+	minorDigits := strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	minor, err := strconv.Atoi(minorDigits)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version %q: %v", version, err)
+	}
+
+	return major, minor, nil
+}
+
+func sortDeprecatedAPIs(apis []DeprecatedAPIUsage) {
+	sort.Slice(apis, func(i, j int) bool {
+		if apis[i].Group != apis[j].Group {
+			return apis[i].Group < apis[j].Group
+		}
+		if apis[i].Version != apis[j].Version {
+			return apis[i].Version < apis[j].Version
+		}
+		return apis[i].Resource < apis[j].Resource
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newDiscoveryServer serves the discovery documents of the served group
+// versions and resources, and /metrics with metricsStatus and metrics
+func newDiscoveryServer(t *testing.T, served map[string][]string, metricsStatus int, metrics string) kubernetes.Interface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			w.WriteHeader(metricsStatus)
+			w.Write([]byte(metrics))
+			return
+		}
+
+		groupVersion := strings.TrimPrefix(r.URL.Path, "/apis/")
+		resources, ok := served[groupVersion]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		list := metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: groupVersion,
+		}
+		for _, resource := range resources {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: resource, Verbs: metav1.Verbs{"get", "list"}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientset
+}
+
+func TestCollectUpgradeReadiness(t *testing.T) {
+	// batch/v1beta1 goes in 1.25 and flowcontrol v1beta1 in 1.26, so on 1.24
+	// only the first is removed by the next minor
+	served := map[string][]string{
+		"batch/v1beta1":                        {"cronjobs"},
+		"flowcontrol.apiserver.k8s.io/v1beta1": {"flowschemas"},
+	}
+	metrics := strings.Join([]string{
+		`# HELP apiserver_requested_deprecated_apis [STABLE] Gauge of deprecated APIs that have been requested, broken out by API group, version, resource, subresource, and removed_release.`,
+		`# TYPE apiserver_requested_deprecated_apis gauge`,
+		`apiserver_requested_deprecated_apis{group="policy",removed_release="1.25",resource="poddisruptionbudgets",subresource="",version="v1beta1"} 1`,
+		`apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.26",resource="flowschemas",subresource="",version="v1beta1"} 1`,
+		`apiserver_requested_deprecated_apis{group="example.com",removed_release="1.25",resource="widgets",subresource="",version="v1alpha1"} 1`,
+		`apiserver_requested_deprecated_apis{group="batch",removed_release="1.25",resource="cronjobs",subresource="",version="v1beta1"} 0`,
+		`apiserver_request_total{code="200",resource="pods",verb="LIST",version="v1"} 42`,
+	}, "\n") + "\n"
+
+	tests := []struct {
+		name          string
+		metricsStatus int
+		wantMetrics   bool
+		wantAPIs      []string
+		wantBlockers  []string
+	}{
+		{
+			name:          "requests from the metric",
+			metricsStatus: http.StatusOK,
+			wantMetrics:   true,
+			wantAPIs: []string{
+				"batch/v1beta1/cronjobs served",
+				"example.com/v1alpha1/widgets requested",
+				"flowcontrol.apiserver.k8s.io/v1beta1/flowschemas served requested",
+				"policy/v1beta1/poddisruptionbudgets requested",
+			},
+			// the served cronjobs aren't requested, flowschemas stay until 1.26
+			wantBlockers: []string{
+				"example.com/v1alpha1/widgets requested",
+				"policy/v1beta1/poddisruptionbudgets requested",
+			},
+		},
+		{
+			name:          "metrics forbidden",
+			metricsStatus: http.StatusForbidden,
+			wantAPIs: []string{
+				"batch/v1beta1/cronjobs served",
+				"flowcontrol.apiserver.k8s.io/v1beta1/flowschemas served",
+			},
+			wantBlockers: []string{"batch/v1beta1/cronjobs served"},
+		},
+		{
+			name:          "metrics unavailable",
+			metricsStatus: http.StatusServiceUnavailable,
+			wantAPIs: []string{
+				"batch/v1beta1/cronjobs served",
+				"flowcontrol.apiserver.k8s.io/v1beta1/flowschemas served",
+			},
+			wantBlockers: []string{"batch/v1beta1/cronjobs served"},
+		},
+	}
+
+	describe := func(apis []DeprecatedAPIUsage) []string {
+		described := []string{}
+		for _, api := range apis {
+			d := api.Group + "/" + api.Version + "/" + api.Resource
+			if api.Served {
+				d += " served"
+			}
+			if api.Requested {
+				d += " requested"
+			}
+			described = append(described, d)
+		}
+		return described
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _, _ := newTestWatcher(t, nil, testConfig(0))
+			w.clientset = newDiscoveryServer(t, served, tt.metricsStatus, metrics)
+
+			readiness, err := w.collectUpgradeReadiness("v1.24.17-eks-5e0fdde")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if readiness.CurrentVersion != "1.24" || readiness.TargetVersion != "1.25" {
+				t.Errorf("versions = %s to %s, want 1.24 to 1.25", readiness.CurrentVersion, readiness.TargetVersion)
+			}
+			if readiness.MetricsAvailable != tt.wantMetrics {
+				t.Errorf("MetricsAvailable = %v, want %v", readiness.MetricsAvailable, tt.wantMetrics)
+			}
+			if got := describe(readiness.DeprecatedAPIs); !reflect.DeepEqual(got, tt.wantAPIs) {
+				t.Errorf("deprecated APIs = %v, want %v", got, tt.wantAPIs)
+			}
+			if got := describe(readiness.Blockers); !reflect.DeepEqual(got, tt.wantBlockers) {
+				t.Errorf("blockers = %v, want %v", got, tt.wantBlockers)
+			}
+		})
+	}
+}
+
+func TestCollectUpgradeReadinessRemovalDetails(t *testing.T) {
+	w, _, _ := newTestWatcher(t, nil, testConfig(0))
+	w.clientset = newDiscoveryServer(t, map[string][]string{"batch/v1beta1": {"cronjobs"}}, http.StatusForbidden, "")
+
+	readiness, err := w.collectUpgradeReadiness("v1.24.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readiness.Blockers) != 1 {
+		t.Fatalf("blockers = %+v, want the cronjobs", readiness.Blockers)
+	}
+
+	// removal details come from the deprecation table
+	want := DeprecatedAPIUsage{
+		Group:        "batch",
+		Version:      "v1beta1",
+		Resource:     "cronjobs",
+		DeprecatedIn: "1.21",
+		RemovedIn:    "1.25",
+		Replacement:  "batch/v1",
+		Served:       true,
+	}
+	if readiness.Blockers[0] != want {
+		t.Errorf("blocker = %+v, want %+v", readiness.Blockers[0], want)
+	}
+}
+
+func TestParseMetricLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels string
+		want   map[string]string
+	}{
+		{
+			name:   "deprecated API sample",
+			labels: `group="policy",removed_release="1.25",resource="poddisruptionbudgets",subresource="",version="v1beta1"`,
+			want:   map[string]string{"group": "policy", "removed_release": "1.25", "resource": "poddisruptionbudgets", "subresource": "", "version": "v1beta1"},
+		},
+		{
+			name:   "escaped quote and backslash",
+			labels: `group="a\"b",resource="c\\d"`,
+			want:   map[string]string{"group": `a"b`, "resource": `c\d`},
+		},
+		{
+			name:   "comma and equals sign in a value",
+			labels: `group="a,b=c",version="v1"`,
+			want:   map[string]string{"group": "a,b=c", "version": "v1"},
+		},
+		{
+			name:   "spaces after commas",
+			labels: `group="batch", version="v1beta1"`,
+			want:   map[string]string{"group": "batch", "version": "v1beta1"},
+		},
+		{
+			name:   "unterminated value",
+			labels: `group="batch`,
+			want:   map[string]string{"group": "batch"},
+		},
+		{
+			name:   "empty",
+			labels: ``,
+			want:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMetricLabels(tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMetricLabels(%q) = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMinorVersion(t *testing.T) {
+	tests := []struct {
+		version   string
+		wantMajor int
+		wantMinor int
+		wantErr   bool
+	}{
+		{version: "v1.29.3-eks-abcdef", wantMajor: 1, wantMinor: 29},
+		{version: "1.25", wantMajor: 1, wantMinor: 25},
+		{version: "v1.27+", wantMajor: 1, wantMinor: 27},
+		{version: "v1.28.2+k3s1", wantMajor: 1, wantMinor: 28},
+		{version: "v1", wantErr: true},
+		{version: "vx.24", wantErr: true},
+		{version: "v1.beta", wantErr: true},
+		{version: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			major, minor, err := parseMinorVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMinorVersion(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+			}
+			if !tt.wantErr && (major != tt.wantMajor || minor != tt.wantMinor) {
+				t.Errorf("parseMinorVersion(%q) = %d.%d, want %d.%d", tt.version, major, minor, tt.wantMajor, tt.wantMinor)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	driftMissing = "missing"
+	driftStale   = "stale"
+	driftChanged = "changed"
+)
+
+// DriftReport is what `watcher diff` found between the cluster and the
+// backend
+type DriftReport struct {
+	Cluster string        `json:"cluster"`
+	Drift   bool          `json:"drift"`
+	Objects []DriftObject `json:"objects"`
+}
+
+// DriftObject is an object that differs: missing from the backend, stale in
+// the backend after being deleted from the cluster, or changed, with the
+// fields that differ
+type DriftObject struct {
+	Status    string      `json:"status"`
+	Kind      string      `json:"kind"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Fields    []FieldDiff `json:"fields,omitempty"`
+}
+
+type FieldDiff struct {
+	Field   string      `json:"field"`
+	Cluster interface{} `json:"cluster"`
+	Backend interface{} `json:"backend"`
+}
+
+type diffOptions struct {
+	output string
+}
+
+func parseDiffArgs(args []string) (diffOptions, error) {
+	var opts diffOptions
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.StringVar(&opts.output, "output", "text", "report format, text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [flags]\n\nExits 1 if the backend has drifted from the cluster and 2 on errors.\n\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if opts.output != "text" && opts.output != "json" {
+		return opts, fmt.Errorf("invalid --output %q, must be text or json", opts.output)
+	}
+	if flags.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	return opts, nil
+}
+
+// Diff compares what the cluster holds right now with what the backend has
+// recorded for it
+func (w *ResourceWatcher) Diff(ctx context.Context) (*DriftReport, error) {
+	backend, ok := w.sink.(*RESTSink)
+	if !ok {
+		return nil, fmt.Errorf("diff needs the rest sink, not %s", w.sink.Name())
+	}
+
+	inventory, err := w.collectInventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := backend.GetCluster(ctx, inventory.ClusterInfo.ClusterUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster from backend: %v", err)
+	}
+
+	plan, err := planChanges(ctx, backend, inventory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current inventory from backend: %v", err)
+	}
+
+	report := &DriftReport{Cluster: w.clusterName, Objects: []DriftObject{}}
+	if cluster == nil {
+		report.Objects = append(report.Objects, DriftObject{Status: driftMissing, Kind: kindCluster, Name: w.clusterName})
+	} else if fields := diffCluster(inventory.ClusterInfo, cluster); len(fields) > 0 {
+		report.Objects = append(report.Objects, DriftObject{Status: driftChanged, Kind: kindCluster, Name: w.clusterName, Fields: fields})
+	}
+
+	for _, planned := range plan {
+		object := DriftObject{Kind: planned.Kind, Namespace: planned.Namespace, Name: planned.Name}
+		switch planned.Action {
+		case actionCreate:
+			object.Status = driftMissing
+		case actionDelete:
+			object.Status = driftStale
+		case actionUpdate:
+			// fields the backend doesn't store always differ, they aren't
+			// drift
+			object.Status = driftChanged
+			object.Fields = diffFields(planned.Payload, planned.Previous, backend.fieldsStored(planned.Kind))
+			if len(object.Fields) == 0 {
+				continue
+			}
+		}
+		report.Objects = append(report.Objects, object)
+	}
+
+	report.Drift = len(report.Objects) > 0
+	return report, nil
+}
+
+// diffCluster compares the few cluster fields the backend returns
+func diffCluster(info *ClusterInfo, cluster *ClusterResponse) []FieldDiff {
+	return diffFields(
+		map[string]interface{}{
+			"clusterName":      info.ClusterName,
+			"clusterUid":       info.ClusterUID,
+			"apiServerVersion": info.APIServerVersion,
+			"kubeletVersions":  info.KubeletVersions,
+			"kernelVersions":   info.KernelVersions,
+		},
+		map[string]interface{}{
+			"clusterName":      cluster.ClusterName,
+			"clusterUid":       cluster.ClusterUID,
+			"apiServerVersion": cluster.APIServerVersion,
+			"kubeletVersions":  cluster.KubeletVersions,
+			"kernelVersions":   cluster.KernelVersions,
+		},
+		nil,
+	)
+}
+
+// diffFields compares two payloads field by field, with the same leniency
+// for null and empty values as payloadsEqual. With stored set, only those
+// fields are compared.
+func diffFields(clusterPayload, backendPayload interface{}, stored map[string]bool) []FieldDiff {
+	clusterFields, _ := canonicalJSON(clusterPayload).(map[string]interface{})
+	backendFields, _ := canonicalJSON(backendPayload).(map[string]interface{})
+
+	names := make(map[string]bool)
+	for name := range clusterFields {
+		names[name] = true
+	}
+	for name := range backendFields {
+		names[name] = true
+	}
+
+	var fields []FieldDiff
+	for name := range names {
+		if stored != nil && !stored[name] {
+			continue
+		}
+		if !reflect.DeepEqual(clusterFields[name], backendFields[name]) {
+			fields = append(fields, FieldDiff{Field: name, Cluster: clusterFields[name], Backend: backendFields[name]})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Field < fields[j].Field
+	})
+	return fields
+}
+
+func printDriftReport(out io.Writer, report *DriftReport, output string) error {
+	if output == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	if !report.Drift {
+		fmt.Fprintf(out, "No drift between cluster %s and the backend\n", report.Cluster)
+		return nil
+	}
+
+	for _, object := range report.Objects {
+		key := object.Name
+		if object.Namespace != "" {
+			key = object.Namespace + "/" + object.Name
+		}
+		fmt.Fprintf(out, "%-8s %-8s %s\n", object.Status, object.Kind, key)
+		for _, field := range object.Fields {
+			fmt.Fprintf(out, "    %s: cluster %s, backend %s\n", field.Field, driftValue(field.Cluster), driftValue(field.Backend))
+		}
+	}
+	fmt.Fprintf(out, "%d objects differ between cluster %s and the backend\n", len(report.Objects), report.Cluster)
+	return nil
+}
+
+func driftValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
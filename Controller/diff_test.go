@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name  string
+		kinds []string
+		// edit changes the service records after the cluster was synced
+		edit func(record map[string]interface{})
+		want []DriftObject
+	}{
+		{
+			name:  "in sync",
+			kinds: []string{"ingress", "service", "node"},
+			want:  []DriftObject{},
+		},
+		{
+			name:  "backend without nodes",
+			kinds: []string{"ingress", "service"},
+			want:  []DriftObject{},
+		},
+		{
+			name:  "backend storing fewer service fields",
+			kinds: []string{"ingress", "service", "node"},
+			edit: func(record map[string]interface{}) {
+				delete(record, "clusterIps")
+				delete(record, "portDetails")
+			},
+			want: []DriftObject{},
+		},
+		{
+			name:  "changed service",
+			kinds: []string{"ingress", "service", "node"},
+			edit: func(record map[string]interface{}) {
+				delete(record, "portDetails")
+				record["serviceType"] = "NodePort"
+			},
+			want: []DriftObject{{
+				Status:    driftChanged,
+				Kind:      kindService,
+				Namespace: "default",
+				Name:      "web",
+				Fields:    []FieldDiff{{Field: "serviceType", Cluster: "ClusterIP", Backend: "NodePort"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, server := newFakeBackend(t, tt.kinds...)
+			sink := NewRESTSink(server.URL, "test", http.DefaultClient)
+			w, _, _ := newTestWatcher(t, sink, testConfig(0),
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+					Spec: corev1.ServiceSpec{
+						Type:       corev1.ServiceTypeClusterIP,
+						ClusterIPs: []string{"10.0.0.1"},
+						Ports:      []corev1.ServicePort{{Name: "http", Port: 80}},
+					},
+				},
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			)
+
+			if err := w.RunOnce(context.Background()); err != nil {
+				t.Fatalf("RunOnce() error = %v", err)
+			}
+			if tt.edit != nil {
+				backend.edit("service", tt.edit)
+			}
+			// the backend answers with its own field names, not the ones
+			// cluster info is sent with
+			info, err := w.collectClusterInfo()
+			if err != nil {
+				t.Fatalf("collectClusterInfo() error = %v", err)
+			}
+			backend.cluster = map[string]interface{}{
+				"id":               1,
+				"clusterName":      info.ClusterName,
+				"clusterUid":       info.ClusterUID,
+				"apiserverVersion": info.APIServerVersion,
+				"kubeletVersions":  info.KubeletVersions,
+				"kernelVersions":   info.KernelVersions,
+			}
+
+			report, err := w.Diff(context.Background())
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if !reflect.DeepEqual(report.Objects, tt.want) {
+				t.Errorf("Diff() objects = %+v, want %+v", report.Objects, tt.want)
+			}
+			if report.Drift != (len(tt.want) > 0) {
+				t.Errorf("Diff() drift = %v with %d objects", report.Drift, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	web := ServicePayload{Namespace: "default", ServiceName: "web", ServiceType: "ClusterIP", Ports: []int32{80}}
+
+	tests := []struct {
+		name    string
+		cluster interface{}
+		backend interface{}
+		stored  map[string]bool
+		want    []FieldDiff
+	}{
+		{
+			name:    "equal",
+			cluster: web,
+			backend: web,
+		},
+		{
+			name:    "null and empty are equal",
+			cluster: ServicePayload{ServiceName: "web", ClusterIPs: []string{}},
+			backend: ServicePayload{ServiceName: "web"},
+		},
+		{
+			name:    "changed field",
+			cluster: web,
+			backend: ServicePayload{Namespace: "default", ServiceName: "web", ServiceType: "NodePort", Ports: []int32{80}},
+			want:    []FieldDiff{{Field: "serviceType", Cluster: "ClusterIP", Backend: "NodePort"}},
+		},
+		{
+			name:    "field missing from the backend",
+			cluster: web,
+			backend: ServicePayload{Namespace: "default", ServiceName: "web", ServiceType: "ClusterIP"},
+			want:    []FieldDiff{{Field: "ports", Cluster: []interface{}{float64(80)}, Backend: nil}},
+		},
+		{
+			name:    "field the backend doesn't store",
+			cluster: web,
+			backend: ServicePayload{Namespace: "default", ServiceName: "web", ServiceType: "ClusterIP"},
+			stored:  map[string]bool{"namespace": true, "serviceName": true, "serviceType": true},
+		},
+		{
+			name:    "sorted by field",
+			cluster: web,
+			backend: ServicePayload{Namespace: "other", ServiceName: "web", ServiceType: "NodePort", Ports: []int32{80}},
+			want: []FieldDiff{
+				{Field: "namespace", Cluster: "default", Backend: "other"},
+				{Field: "serviceType", Cluster: "ClusterIP", Backend: "NodePort"},
+			},
+		},
+		{
+			name:    "maps",
+			cluster: map[string]interface{}{"kubeletVersions": []string{"v1.30.1"}},
+			backend: map[string]interface{}{"kubeletVersions": []string{"v1.29.4"}},
+			want:    []FieldDiff{{Field: "kubeletVersions", Cluster: []interface{}{"v1.30.1"}, Backend: []interface{}{"v1.29.4"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffFields(tt.cluster, tt.backend, tt.stored); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffFields() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// dryRunTransport is an http.RoundTripper that logs backend writes instead of
+// sending them and answers them as the backend would on success. Reads go to
+// the backend so creates can still be told from updates, unless reads is off,
+// in which case the backend looks empty and everything is a create.
+type dryRunTransport struct {
+	transport http.RoundTripper
+	reads     bool
+}
+
+func newDryRunTransport(transport http.RoundTripper, reads bool) *dryRunTransport {
+	return &dryRunTransport{transport: transport, reads: reads}
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		if t.reads {
+			return t.transport.RoundTrip(req)
+		}
+		// cluster lists are /api/<resource>/cluster/<name>, everything else
+		// is a lookup of a single record
+		if strings.Contains(req.URL.Path, "/cluster/") {
+			return dryRunResponse(req, http.StatusOK, []byte("[]")), nil
+		}
+		return dryRunResponse(req, http.StatusNotFound, nil), nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+	}
+	slog.Info("Dry run, not sending request", "method", req.Method, "url", req.URL.String(), "body", rawJSON(body))
+
+	if strings.HasSuffix(req.URL.Path, "/bulk") {
+		response, err := dryRunBulkResponse(req.URL.Path, body)
+		if err != nil {
+			return nil, err
+		}
+		return dryRunResponse(req, http.StatusOK, response), nil
+	}
+	return dryRunResponse(req, http.StatusOK, []byte("{}")), nil
+}
+
+// dryRunBulkResponse reports every item of a bulk request to
+// /api/<kind>/bulk as applied
+func dryRunBulkResponse(urlPath string, body []byte) ([]byte, error) {
+	kind := path.Base(path.Dir(urlPath))
+	nameField := map[string]string{kindIngress: "ingressName", kindService: "serviceName", kindNode: "nodeName"}[kind]
+
+	var request struct {
+		Upserts []map[string]interface{} `json:"upserts"`
+		Deletes []bulkDelete             `json:"deletes"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk request: %v", err)
+	}
+
+	response := bulkResponse{Results: []bulkResult{}}
+	for _, upsert := range request.Upserts {
+		namespace, _ := upsert["namespace"].(string)
+		name, _ := upsert[nameField].(string)
+		response.Results = append(response.Results, bulkResult{Namespace: namespace, Name: name, Operation: operationUpsert, Success: true})
+	}
+	for _, del := range request.Deletes {
+		response.Results = append(response.Results, bulkResult{Namespace: del.Namespace, Name: del.Name, Operation: operationDelete, Success: true})
+	}
+	return json.Marshal(response)
+}
+
+func dryRunResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
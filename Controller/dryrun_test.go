@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// captureLogs sends the default logger's records to a buffer for the rest of
+// the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestDryRunApplyBatch(t *testing.T) {
+	backend, server := newFakeBackend(t, "ingress", "service", "node")
+	backend.add("service", ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "web"})
+	backend.add("service", ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "stale"})
+	logs := captureLogs(t)
+
+	sink := NewRESTSink(server.URL, "test", &http.Client{Transport: newDryRunTransport(http.DefaultTransport, true)})
+	errs, err := sink.ApplyBatch(context.Background(), kindService, []Change{
+		changeForService(ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "web", Ports: []int32{80}}),
+		changeForService(ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "new"}),
+		{Kind: kindService, Operation: operationDelete, Namespace: "default", Name: "stale"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("change %d: %v", i, err)
+		}
+	}
+
+	// a real run sends the whole batch as one bulk request
+	var logged []string
+	decoder := json.NewDecoder(logs)
+	for decoder.More() {
+		var record struct {
+			Msg    string `json:"msg"`
+			Method string `json:"method"`
+			URL    string `json:"url"`
+		}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Msg == "Dry run, not sending request" {
+			u, _ := url.Parse(record.URL)
+			logged = append(logged, record.Method+" "+u.Path)
+		}
+	}
+	want := []string{"POST /api/service/bulk"}
+	if !reflect.DeepEqual(logged, want) {
+		t.Errorf("logged requests = %v, want %v", logged, want)
+	}
+
+	if got, want := backend.names("service", "serviceName"), []string{"stale", "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backend services = %v, want them untouched as %v", got, want)
+	}
+	for request := range backend.requests {
+		if request[:3] != "GET" {
+			t.Errorf("dry run sent %s to the backend", request)
+		}
+	}
+}
+
+// roundTripFunc forwards requests to a function, standing in for the backend
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDryRunTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		reads      bool
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+		forwarded  bool
+	}{
+		{name: "list without reads", method: http.MethodGet, path: "/api/service/cluster/test", wantStatus: http.StatusOK, wantBody: "[]"},
+		{name: "lookup without reads", method: http.MethodGet, path: "/api/clusters/name/test", wantStatus: http.StatusNotFound},
+		{name: "read with reads", reads: true, method: http.MethodGet, path: "/api/service/cluster/test", wantStatus: http.StatusTeapot, forwarded: true},
+		{name: "create", reads: true, method: http.MethodPost, path: "/api/service", wantStatus: http.StatusOK, wantBody: "{}"},
+		{name: "update", reads: true, method: http.MethodPut, path: "/api/service/1", wantStatus: http.StatusOK, wantBody: "{}"},
+		{name: "delete", reads: true, method: http.MethodDelete, path: "/api/service/1", wantStatus: http.StatusOK, wantBody: "{}"},
+		{name: "bulk", reads: true, method: http.MethodPost, path: "/api/service/bulk", wantStatus: http.StatusOK, wantBody: `{"results":[{"namespace":"","name":"web","operation":"upsert","success":true,"error":""}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded := false
+			transport := newDryRunTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				forwarded = true
+				return dryRunResponse(req, http.StatusTeapot, nil), nil
+			}), tt.reads)
+
+			req, err := http.NewRequest(tt.method, "http://backend"+tt.path, bytes.NewBufferString(`{"upserts":[{"serviceName":"web"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body bytes.Buffer
+			body.ReadFrom(resp.Body)
+			if resp.StatusCode != tt.wantStatus || body.String() != tt.wantBody {
+				t.Errorf("response = %d %q, want %d %q", resp.StatusCode, body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if forwarded != tt.forwarded {
+				t.Errorf("forwarded = %v, want %v", forwarded, tt.forwarded)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointSliceServiceIndex indexes EndpointSlices by the namespace/name of the
+// Service that owns them
+const endpointSliceServiceIndex = "service"
+
+type EndpointCounts struct {
+	Ready    int `json:"ready"`
+	NotReady int `json:"notReady"`
+}
+
+func endpointSliceServiceKey(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type for endpointslice object")
+	}
+
+	serviceName := slice.Labels[discoveryv1.LabelServiceName]
+	if serviceName == "" {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("%s/%s", slice.Namespace, serviceName)}, nil
+}
+
+// countEndpoints sums endpoints across every slice of a Service. Dual-stack
+// services publish one slice per address family, so endpoints are deduplicated
+// by their target (usually a pod) before counting.
+func (w *ResourceWatcher) countEndpoints(namespace, serviceName string) EndpointCounts {
+	counts := EndpointCounts{}
+	if w.endpointSliceIndexer == nil {
+		return counts
+	}
+
+	objs, err := w.endpointSliceIndexer.ByIndex(
+		endpointSliceServiceIndex,
+		fmt.Sprintf("%s/%s", namespace, serviceName),
+	)
+	if err != nil {
+		slog.Error("Failed to list endpointslices", "kind", kindService, "namespace", namespace, "name", serviceName, "error", err)
+		return counts
+	}
+
+	ready := make(map[string]bool)
+	for _, obj := range objs {
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			var key string
+			switch {
+			case endpoint.TargetRef != nil:
+				key = fmt.Sprintf("%s/%s/%s", endpoint.TargetRef.Kind, endpoint.TargetRef.Namespace, endpoint.TargetRef.Name)
+			case len(endpoint.Addresses) > 0:
+				key = endpoint.Addresses[0]
+			default:
+				continue
+			}
+
+			// a nil ready condition means unknown and must be treated as ready
+			isReady := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+			ready[key] = ready[key] || isReady
+		}
+	}
+
+	for _, isReady := range ready {
+		if isReady {
+			counts.Ready++
+		} else {
+			counts.NotReady++
+		}
+	}
+
+	return counts
+}
+
+func (w *ResourceWatcher) handleEndpointSliceChange(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		slog.Error("Unexpected type for endpointslice object")
+		return
+	}
+
+	serviceName := slice.Labels[discoveryv1.LabelServiceName]
+	if serviceName == "" {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", slice.Namespace, serviceName)
+	counts := w.countEndpoints(slice.Namespace, serviceName)
+
+	w.endpointCountsMu.Lock()
+	previous, seen := w.endpointCounts[key]
+	if seen && previous == counts {
+		w.endpointCountsMu.Unlock()
+		return
+	}
+	w.endpointCounts[key] = counts
+	w.endpointCountsMu.Unlock()
+
+	// slices outlive their Service briefly, and during the initial sync the
+	// services informer sends every Service with its counts anyway
+	if _, exists, err := w.serviceStore.GetByKey(key); err != nil || !exists {
+		return
+	}
+
+	if seen && previous.Ready > 0 && counts.Ready == 0 {
+		slog.Warn("Service has no ready endpoints left", "kind", kindService, "namespace", slice.Namespace, "name", serviceName, "notReady", counts.NotReady)
+	}
+
+	w.enqueueAfter(w.serviceQueue, kindService, workQueueItem{
+		key:       key,
+		namespace: slice.Namespace,
+		name:      serviceName,
+		operation: "update",
+	}, w.config.EndpointsDebounce)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func testEndpointSlice(name string, ready ...bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name + "-abc",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: name},
+		},
+	}
+	for i, r := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{fmt.Sprintf("10.0.0.%d", i+1)},
+			Conditions: discoveryv1.EndpointConditions{Ready: &r},
+		})
+	}
+	return slice
+}
+
+func TestHandleEndpointSliceChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		// each update replaces the slice with one of these readiness sets
+		updates [][]bool
+		want    int
+	}{
+		{name: "service exists", service: "web", updates: [][]bool{{true}}, want: 1},
+		{name: "service gone", service: "gone", updates: [][]bool{{true}}, want: 0},
+		{name: "counts unchanged", service: "web", updates: [][]bool{{true}, {true}}, want: 1},
+		{name: "flapping coalesced", service: "web", updates: [][]bool{{true}, {false}, {true}, {false}}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceStore := cache.NewStore(cache.MetaNamespaceKeyFunc)
+			serviceStore.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{endpointSliceServiceIndex: endpointSliceServiceKey})
+
+			w := &ResourceWatcher{
+				config:               &Config{EndpointsDebounce: 50 * time.Millisecond},
+				serviceQueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "services"),
+				serviceStore:         serviceStore,
+				endpointSliceIndexer: indexer,
+				endpointCounts:       make(map[string]EndpointCounts),
+				pendingTraces:        make(map[string]pendingTrace),
+			}
+			defer w.serviceQueue.ShutDown()
+
+			for _, ready := range tt.updates {
+				slice := testEndpointSlice(tt.service, ready...)
+				indexer.Update(slice)
+				w.handleEndpointSliceChange(slice)
+			}
+
+			if got := w.serviceQueue.Len(); got != 0 {
+				t.Errorf("queued before the debounce = %d, want 0", got)
+			}
+			time.Sleep(150 * time.Millisecond)
+			if got := w.serviceQueue.Len(); got != tt.want {
+				t.Errorf("queued = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
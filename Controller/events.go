@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	kindCluster = "cluster"
+
+	eventCreated = "created"
+	eventUpdated = "updated"
+	eventDeleted = "deleted"
+)
+
+// ChangeEvent is the message streaming sinks publish for every change. Object
+// holds the same payload the REST backend receives and is left out of deletes.
+type ChangeEvent struct {
+	Type      string      `json:"type"`
+	Cluster   string      `json:"cluster"`
+	Kind      string      `json:"kind"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Time      time.Time   `json:"time"`
+	Object    interface{} `json:"object,omitempty"`
+}
+
+// eventTracker tells creates from updates for streaming sinks, which unlike
+// the backend can't be asked whether they already hold an object. It only
+// knows what was published since the controller started, so after a restart
+// the first event for every object is a create and consumers should treat
+// creates and updates alike.
+type eventTracker struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newEventTracker() *eventTracker {
+	return &eventTracker{seen: make(map[string]bool)}
+}
+
+// newEvent builds the event for change. The tracker isn't updated until the
+// event has been delivered, see sent.
+func (t *eventTracker) newEvent(cluster string, change Change) ChangeEvent {
+	event := ChangeEvent{
+		Type:      eventCreated,
+		Cluster:   cluster,
+		Kind:      change.Kind,
+		Namespace: change.Namespace,
+		Name:      change.Name,
+		Time:      time.Now().UTC(),
+		Object:    change.Payload,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if change.Operation == operationDelete {
+		event.Type = eventDeleted
+		event.Object = nil
+	} else if t.seen[change.Kind+"/"+change.Key()] {
+		event.Type = eventUpdated
+	}
+	return event
+}
+
+func (t *eventTracker) sent(change Change) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if change.Operation == operationDelete {
+		delete(t.seen, change.Kind+"/"+change.Key())
+	} else {
+		t.seen[change.Kind+"/"+change.Key()] = true
+	}
+}
+
+// changeForIngress and friends adapt the per-kind Sink methods to Change so
+// streaming sinks can implement them all through ApplyBatch
+func changeForIngress(payload IngressPayload) Change {
+	return Change{Kind: kindIngress, Operation: operationUpsert, Namespace: payload.Namespace, Name: payload.IngressName, Payload: payload}
+}
+
+func changeForService(payload ServicePayload) Change {
+	return Change{Kind: kindService, Operation: operationUpsert, Namespace: payload.Namespace, Name: payload.ServiceName, Payload: payload}
+}
+
+func changeForNode(payload NodePayload) Change {
+	return Change{Kind: kindNode, Operation: operationUpsert, Name: payload.NodeName, Payload: payload}
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// fileSchemaVersion is bumped on any incompatible change to FileRecord or
+// Snapshot so importers can refuse files they don't understand
+const fileSchemaVersion = 1
+
+const (
+	recordChange   = "change"
+	recordSnapshot = "snapshot"
+
+	fileTimeFormat = "20060102T150405.000Z"
+)
+
+type FileSinkConfig struct {
+	// Path is a directory, or "-" for stdout
+	Path             string
+	Format           string
+	SnapshotInterval time.Duration
+	MaxChangeLogSize int64
+	Retain           int
+}
+
+// loadFileSinkConfig reads the FILE_SINK_* settings, only needed when the
+// file sink is enabled
+func loadFileSinkConfig() (FileSinkConfig, error) {
+	config := FileSinkConfig{
+		Path:             os.Getenv("FILE_SINK_PATH"),
+		Format:           os.Getenv("FILE_SINK_FORMAT"),
+		MaxChangeLogSize: 64 << 20,
+		Retain:           24,
+	}
+
+	if config.Path == "" {
+		return config, fmt.Errorf("FILE_SINK_PATH environment variable is required for the file sink")
+	}
+	logSetting("FILE_SINK_PATH", config.Path)
+
+	switch config.Format {
+	case "":
+		config.Format = "ndjson"
+	case "json", "ndjson":
+		logSetting("FILE_SINK_FORMAT", config.Format)
+	default:
+		return config, fmt.Errorf("invalid FILE_SINK_FORMAT %q, must be json or ndjson", config.Format)
+	}
+
+	var err error
+	config.SnapshotInterval, err = durationFromEnv("FILE_SINK_SNAPSHOT_INTERVAL", time.Hour)
+	if err != nil {
+		return config, err
+	}
+
+	if value := os.Getenv("FILE_SINK_MAX_SIZE"); value != "" {
+		size, err := resource.ParseQuantity(value)
+		if err != nil || size.Value() < 1 {
+			return config, fmt.Errorf("invalid FILE_SINK_MAX_SIZE %q", value)
+		}
+		config.MaxChangeLogSize = size.Value()
+		logSetting("FILE_SINK_MAX_SIZE", config.MaxChangeLogSize)
+	}
+
+	if value := os.Getenv("FILE_SINK_RETAIN"); value != "" {
+		config.Retain, err = strconv.Atoi(value)
+		if err != nil || config.Retain < 1 {
+			return config, fmt.Errorf("invalid FILE_SINK_RETAIN %q", value)
+		}
+		logSetting("FILE_SINK_RETAIN", config.Retain)
+	}
+
+	return config, nil
+}
+
+// FileRecord is one line of a change log or NDJSON snapshot. Change records
+// carry the event Type; snapshot records describe an object as it was when
+// the snapshot was taken. Object is the same payload the REST backend gets,
+// cluster info for kind "cluster", and absent for deletes.
+type FileRecord struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Record        string          `json:"record"`
+	Type          string          `json:"type,omitempty"`
+	Cluster       string          `json:"cluster"`
+	Kind          string          `json:"kind"`
+	Namespace     string          `json:"namespace,omitempty"`
+	Name          string          `json:"name"`
+	Time          time.Time       `json:"time"`
+	Object        json.RawMessage `json:"object,omitempty"`
+}
+
+// Snapshot is the full inventory of a cluster, written as a single document
+// in json format
+type Snapshot struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Cluster       string           `json:"cluster"`
+	Time          time.Time        `json:"time"`
+	ClusterInfo   *ClusterInfo     `json:"clusterInfo"`
+	Ingresses     []IngressPayload `json:"ingresses"`
+	Services      []ServicePayload `json:"services"`
+	Nodes         []NodePayload    `json:"nodes"`
+}
+
+// FileSink writes the inventory for clusters without a route to any backend.
+// Every change is appended to a change log and the full state is written as a
+// snapshot once the caches have synced and then every SnapshotInterval,
+// starting a new change log each time, so the latest snapshot plus the change
+// log after it is always the current state. Change logs are NDJSON; snapshots
+// are NDJSON or a single JSON document.
+//
+// A directory also carries the state across restarts: it is restored on
+// startup, so objects deleted while the controller was down get a delete
+// record once the caches show them gone.
+//
+// In a directory, change logs are also rotated at MaxChangeLogSize and only
+// the newest Retain snapshots, and the change logs since the oldest of them,
+// are kept. On stdout everything is written as NDJSON lines.
+type FileSink struct {
+	*MemorySink
+
+	config      FileSinkConfig
+	clusterName string
+	events      *eventTracker
+
+	mu              sync.Mutex
+	changeLog       io.Writer
+	changeLogFile   *os.File
+	changeLogSize   int64
+	changeLogTime   time.Time
+	lastClusterInfo []byte
+}
+
+func NewFileSink(config FileSinkConfig, clusterName string) (*FileSink, error) {
+	s := &FileSink{
+		MemorySink:  NewMemorySink(),
+		config:      config,
+		clusterName: clusterName,
+		events:      newEventTracker(),
+	}
+
+	if config.Path == "-" {
+		s.changeLog = os.Stdout
+	} else {
+		if err := os.MkdirAll(config.Path, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create file sink directory: %v", err)
+		}
+		if err := s.restore(); err != nil {
+			return nil, err
+		}
+		if err := s.rotateChangeLog(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+// restore loads the state the previous run left in the directory, so that
+// objects deleted while the controller was down can be recorded as deleted
+// once the caches have synced, see recordDeletes
+func (s *FileSink) restore() error {
+	previous, _ := filepath.Glob(filepath.Join(s.config.Path, "*-*.*json"))
+	if len(previous) == 0 {
+		return nil
+	}
+
+	files, err := importFiles([]string{s.config.Path})
+	if err != nil {
+		return fmt.Errorf("failed to restore file sink state: %v", err)
+	}
+	inventory := NewInventory()
+	inventory.Cluster = s.clusterName
+	for _, file := range files {
+		if err := inventory.loadFile(file); err != nil {
+			return fmt.Errorf("failed to restore file sink state: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	if inventory.ClusterInfo != nil {
+		s.lastClusterInfo, _ = json.Marshal(inventory.ClusterInfo)
+		s.MemorySink.SendClusterInfo(ctx, inventory.ClusterInfo)
+	}
+	for _, ingress := range inventory.Ingresses {
+		if ingress != nil {
+			s.MemorySink.UpsertIngress(ctx, *ingress)
+			s.events.sent(changeForIngress(*ingress))
+		}
+	}
+	for _, service := range inventory.Services {
+		if service != nil {
+			s.MemorySink.UpsertService(ctx, *service)
+			s.events.sent(changeForService(*service))
+		}
+	}
+	for _, node := range inventory.Nodes {
+		if node != nil {
+			s.MemorySink.UpsertNode(ctx, *node)
+			s.events.sent(changeForNode(*node))
+		}
+	}
+	slog.Info("Restored file sink state", "files", len(files))
+	return nil
+}
+
+// RunSnapshots writes a snapshot right away and then every SnapshotInterval
+// until ctx is done
+func (s *FileSink) RunSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(s.config.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.WriteSnapshot(); err != nil {
+			slog.Error("Failed to write snapshot", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordDeletes writes a delete for every object the sink holds that isn't in
+// inventory, a complete view of the cluster. Run after a restart, that is what
+// was deleted while the controller was down; updates are left to the workers.
+func (s *FileSink) recordDeletes(ctx context.Context, inventory *Inventory) error {
+	plan, err := planChanges(ctx, s, inventory)
+	if err != nil {
+		return err
+	}
+
+	var deletes []PlannedChange
+	for _, planned := range plan {
+		if planned.Action == actionDelete {
+			deletes = append(deletes, planned)
+		}
+	}
+	if failed := applyPlan(ctx, s, deletes); len(failed) > 0 {
+		return fmt.Errorf("%d of %d deletes failed", len(failed), len(deletes))
+	}
+	if len(deletes) > 0 {
+		slog.Info("Recorded objects deleted while the controller was down", "count", len(deletes))
+	}
+	return nil
+}
+
+// fileSinks returns the file sinks in sink, which may be a MultiSink
+func fileSinks(sink Sink) []*FileSink {
+	switch s := sink.(type) {
+	case *FileSink:
+		return []*FileSink{s}
+	case *MultiSink:
+		var sinks []*FileSink
+		for _, inner := range s.sinks {
+			sinks = append(sinks, fileSinks(inner)...)
+		}
+		return sinks
+	}
+	return nil
+}
+
+func newFileRecord(record string, event ChangeEvent) (FileRecord, error) {
+	fileRecord := FileRecord{
+		SchemaVersion: fileSchemaVersion,
+		Record:        record,
+		Type:          event.Type,
+		Cluster:       event.Cluster,
+		Kind:          event.Kind,
+		Namespace:     event.Namespace,
+		Name:          event.Name,
+		Time:          event.Time,
+	}
+	if event.Object != nil {
+		object, err := json.Marshal(event.Object)
+		if err != nil {
+			return fileRecord, fmt.Errorf("failed to marshal %s: %v", event.Kind, err)
+		}
+		fileRecord.Object = object
+	}
+	return fileRecord, nil
+}
+
+// rotateChangeLog starts a new change log file. Callers hold s.mu, except
+// during construction.
+func (s *FileSink) rotateChangeLog(now time.Time) error {
+	if s.changeLogFile != nil {
+		if err := s.changeLogFile.Close(); err != nil {
+			slog.Error("Failed to close change log", "error", err)
+		}
+	}
+
+	name := filepath.Join(s.config.Path, "changes-"+now.UTC().Format(fileTimeFormat)+".ndjson")
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open change log: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat change log: %v", err)
+	}
+
+	s.changeLog = file
+	s.changeLogFile = file
+	s.changeLogSize = info.Size()
+	s.changeLogTime = now.UTC().Truncate(time.Millisecond)
+	return nil
+}
+
+// appendChange logs change and then applies it to the in-memory state with
+// apply, both under s.mu so a snapshot can't fall between the two
+func (s *FileSink) appendChange(change Change, apply func() error) error {
+	event := s.events.newEvent(s.clusterName, change)
+	record, err := newFileRecord(recordChange, event)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change record: %v", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changeLogFile != nil && s.changeLogSize+int64(len(line)) > s.config.MaxChangeLogSize {
+		if err := s.rotateChangeLog(time.Now()); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.changeLog.Write(line)
+	s.changeLogSize += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write change log: %v", err)
+	}
+
+	s.events.sent(change)
+	return apply()
+}
+
+// snapshot collects the current state from the embedded MemorySink
+func (s *FileSink) snapshot(now time.Time) *Snapshot {
+	ctx := context.Background()
+	ingresses, _ := s.ListIngresses(ctx)
+	services, _ := s.ListServices(ctx)
+	nodes, _ := s.ListNodes(ctx)
+
+	return &Snapshot{
+		SchemaVersion: fileSchemaVersion,
+		Cluster:       s.clusterName,
+		Time:          now,
+		ClusterInfo:   s.ClusterInfo(),
+		Ingresses:     ingresses,
+		Services:      services,
+		Nodes:         nodes,
+	}
+}
+
+// snapshotRecords flattens a snapshot into NDJSON records, cluster info first
+func snapshotRecords(snapshot *Snapshot) ([]FileRecord, error) {
+	events := []ChangeEvent{{Kind: kindCluster, Name: snapshot.Cluster, Object: snapshot.ClusterInfo}}
+	for _, ingress := range snapshot.Ingresses {
+		events = append(events, ChangeEvent{Kind: kindIngress, Namespace: ingress.Namespace, Name: ingress.IngressName, Object: ingress})
+	}
+	for _, service := range snapshot.Services {
+		events = append(events, ChangeEvent{Kind: kindService, Namespace: service.Namespace, Name: service.ServiceName, Object: service})
+	}
+	for _, node := range snapshot.Nodes {
+		events = append(events, ChangeEvent{Kind: kindNode, Name: node.NodeName, Object: node})
+	}
+
+	records := make([]FileRecord, 0, len(events))
+	for _, event := range events {
+		event.Cluster = snapshot.Cluster
+		event.Time = snapshot.Time
+		record, err := newFileRecord(recordSnapshot, event)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func writeNDJSON(w io.Writer, records []FileRecord) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSnapshot writes the full inventory and starts a new change log
+func (s *FileSink) WriteSnapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	// file names have millisecond precision; a snapshot named like the
+	// change log before it would start a log sharing that file, and an
+	// import would replay the records before the snapshot after it
+	if !now.Truncate(time.Millisecond).After(s.changeLogTime) {
+		now = s.changeLogTime.Add(time.Millisecond)
+	}
+	snapshot := s.snapshot(now)
+
+	if s.config.Path == "-" {
+		records, err := snapshotRecords(snapshot)
+		if err != nil {
+			return err
+		}
+		return writeNDJSON(os.Stdout, records)
+	}
+
+	name := filepath.Join(s.config.Path, "snapshot-"+now.Format(fileTimeFormat)+"."+s.config.Format)
+	if err := writeSnapshotFile(name, snapshot, s.config.Format); err != nil {
+		return err
+	}
+	slog.Info("Wrote snapshot", "file", name)
+
+	if err := s.rotateChangeLog(now); err != nil {
+		return err
+	}
+	s.prune()
+	return nil
+}
+
+// writeSnapshotFile writes through a temporary file so readers never see a
+// partial snapshot
+func writeSnapshotFile(name string, snapshot *Snapshot, format string) error {
+	tmp := name + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(snapshot)
+	} else {
+		var records []FileRecord
+		records, err = snapshotRecords(snapshot)
+		if err == nil {
+			err = writeNDJSON(file, records)
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return nil
+}
+
+// prune keeps the newest Retain snapshots and the change logs written since
+// the oldest of them. File names sort by time.
+func (s *FileSink) prune() {
+	snapshots, _ := filepath.Glob(filepath.Join(s.config.Path, "snapshot-*"))
+	sort.Strings(snapshots)
+	if len(snapshots) <= s.config.Retain {
+		return
+	}
+
+	oldest := snapshots[len(snapshots)-s.config.Retain]
+	cutoff := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(oldest), "snapshot-"), filepath.Ext(oldest))
+
+	for _, name := range snapshots[:len(snapshots)-s.config.Retain] {
+		if err := os.Remove(name); err != nil {
+			slog.Error("Failed to remove old snapshot", "error", err)
+		}
+	}
+
+	changeLogs, _ := filepath.Glob(filepath.Join(s.config.Path, "changes-*.ndjson"))
+	for _, name := range changeLogs {
+		if strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "changes-"), ".ndjson") < cutoff {
+			if err := os.Remove(name); err != nil {
+				slog.Error("Failed to remove old change log", "error", err)
+			}
+		}
+	}
+}
+
+func (s *FileSink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster info: %v", err)
+	}
+
+	s.mu.Lock()
+	unchanged := string(data) == string(s.lastClusterInfo)
+	s.mu.Unlock()
+	if unchanged {
+		return s.MemorySink.SendClusterInfo(ctx, info)
+	}
+
+	return s.appendChange(Change{Kind: kindCluster, Operation: operationUpsert, Name: s.clusterName, Payload: info}, func() error {
+		s.lastClusterInfo = data
+		return s.MemorySink.SendClusterInfo(ctx, info)
+	})
+}
+
+func (s *FileSink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	return s.appendChange(changeForIngress(payload), func() error {
+		return s.MemorySink.UpsertIngress(ctx, payload)
+	})
+}
+
+func (s *FileSink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	return s.appendChange(Change{Kind: kindIngress, Operation: operationDelete, Namespace: namespace, Name: name}, func() error {
+		return s.MemorySink.DeleteIngress(ctx, namespace, name)
+	})
+}
+
+func (s *FileSink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	return s.appendChange(changeForService(payload), func() error {
+		return s.MemorySink.UpsertService(ctx, payload)
+	})
+}
+
+func (s *FileSink) DeleteService(ctx context.Context, namespace, name string) error {
+	return s.appendChange(Change{Kind: kindService, Operation: operationDelete, Namespace: namespace, Name: name}, func() error {
+		return s.MemorySink.DeleteService(ctx, namespace, name)
+	})
+}
+
+func (s *FileSink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	return s.appendChange(changeForNode(payload), func() error {
+		return s.MemorySink.UpsertNode(ctx, payload)
+	})
+}
+
+func (s *FileSink) DeleteNode(ctx context.Context, name string) error {
+	return s.appendChange(Change{Kind: kindNode, Operation: operationDelete, Name: name}, func() error {
+		return s.MemorySink.DeleteNode(ctx, name)
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSinkRestart(t *testing.T) {
+	dir := t.TempDir()
+	config := FileSinkConfig{Path: dir, Format: "ndjson", SnapshotInterval: time.Hour, MaxChangeLogSize: 1 << 20, Retain: 5}
+	ctx := context.Background()
+
+	// the first run records two services and stops before any snapshot
+	sink, err := NewFileSink(config, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept", "gone"} {
+		if err := sink.UpsertService(ctx, ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.changeLogFile.Close()
+	// change logs are named by time, the second run's must sort after
+	time.Sleep(2 * time.Millisecond)
+
+	// the second run finds only one of them in the cluster
+	sink, err = NewFileSink(config, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inventory := NewInventory()
+	inventory.Cluster = "test"
+	inventory.Complete = true
+	inventory.Services["default/kept"] = &ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "kept"}
+	if err := sink.recordDeletes(ctx, inventory); err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		sink.RunSnapshots(runCtx)
+		close(done)
+	}()
+	eventually(t, "first snapshot", func() bool {
+		snapshots, _ := filepath.Glob(filepath.Join(dir, "snapshot-*.ndjson"))
+		return len(snapshots) == 1
+	})
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunSnapshots didn't stop with its context")
+	}
+
+	changeLogs, _ := filepath.Glob(filepath.Join(dir, "changes-*.ndjson"))
+	var deletes int
+	for _, name := range changeLogs {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deletes += strings.Count(string(data), `"type":"deleted"`)
+	}
+	if deletes != 1 {
+		t.Errorf("change logs hold %d delete records, want 1", deletes)
+	}
+
+	files, err := importFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewInventory()
+	for _, file := range files {
+		if err := imported.loadFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(imported.Services) != 1 || imported.Services["default/kept"] == nil {
+		t.Errorf("imported services = %v, want only default/kept", imported.Services)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serveHealth serves healthHandler on the metrics address until ctx is done
+func (w *ResourceWatcher) serveHealth(ctx context.Context) {
+	server := &http.Server{
+		Addr:              w.config.MetricsAddr,
+		Handler:           w.healthHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Serving metrics and health checks", "addr", w.config.MetricsAddr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("Failed to serve metrics and health checks", "error", err)
+	}
+}
+
+// healthHandler exposes /metrics, a /healthz liveness check and a /readyz
+// check that fails while the backend circuit breaker is not closed, plus the
+// inventory API when enabled
+func (w *ResourceWatcher) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+		if state := w.breaker.State(); state != circuitClosed {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			rw.Write([]byte("backend circuit " + state.String() + "\n"))
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("ok\n"))
+	})
+	if w.config.InventoryAPI {
+		w.registerInventoryAPI(mux)
+	}
+	return mux
+}
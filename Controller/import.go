@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runImport implements `watcher import`, which replays inventory written by
+// the file sink into the REST backend, for clusters that can't reach it
+// directly. Files are applied in the order given; a directory stands for its
+// latest snapshot and the change logs written after it. A snapshot makes the
+// import complete, so backend objects it doesn't list are deleted, while
+// change logs alone only touch the objects they mention.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	apiEndpoint := flags.String("api-endpoint", os.Getenv("API_ENDPOINT"), "tracker backend URL, defaults to API_ENDPOINT")
+	dryRun := flags.Bool("dry-run", false, "print the changes that would be made without making them")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import [flags] FILE|DIR...\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files to import")
+	}
+	if *apiEndpoint == "" {
+		return fmt.Errorf("--api-endpoint or API_ENDPOINT is required")
+	}
+
+	files, err := importFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	inventory := NewInventory()
+	for _, file := range files {
+		slog.Info("Reading file", "file", file)
+		if err := inventory.loadFile(file); err != nil {
+			return err
+		}
+	}
+	if inventory.Cluster == "" {
+		return fmt.Errorf("no records found in %s", strings.Join(files, ", "))
+	}
+	logCluster(inventory.Cluster)
+
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	sink := NewRESTSink(*apiEndpoint, inventory.Cluster, httpClient)
+
+	ctx := context.Background()
+	plan, err := planChanges(ctx, sink, inventory)
+	if err != nil {
+		return fmt.Errorf("failed to read current inventory from backend: %v", err)
+	}
+
+	if *dryRun {
+		printPlan(os.Stdout, inventory, plan)
+		return nil
+	}
+
+	if inventory.ClusterInfo != nil {
+		if err := sink.SendClusterInfo(ctx, inventory.ClusterInfo); err != nil {
+			return fmt.Errorf("failed to send cluster info: %v", err)
+		}
+	}
+
+	failed := applyPlan(ctx, sink, plan)
+	for i, planned := range plan {
+		if err, ok := failed[i]; ok {
+			slog.Error("Import change failed", "kind", planned.Kind, "namespace", planned.Namespace, "name", planned.Name, "operation", planned.Action, "error", err)
+		}
+	}
+	slog.Info("Import finished", "summary", planSummary(plan, failed))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d changes failed", len(failed), len(plan))
+	}
+	return nil
+}
+
+// importFiles expands directories into their latest snapshot followed by the
+// change logs written since, or all change logs if there is no snapshot. File
+// names sort by time.
+func importFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		snapshots, _ := filepath.Glob(filepath.Join(arg, "snapshot-*.json"))
+		ndjsonSnapshots, _ := filepath.Glob(filepath.Join(arg, "snapshot-*.ndjson"))
+		snapshots = append(snapshots, ndjsonSnapshots...)
+		sort.Strings(snapshots)
+		changeLogs, _ := filepath.Glob(filepath.Join(arg, "changes-*.ndjson"))
+		sort.Strings(changeLogs)
+
+		cutoff := ""
+		if len(snapshots) > 0 {
+			latest := snapshots[len(snapshots)-1]
+			files = append(files, latest)
+			cutoff = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(latest), "snapshot-"), filepath.Ext(latest))
+		}
+		for _, name := range changeLogs {
+			if strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "changes-"), ".ndjson") >= cutoff {
+				files = append(files, name)
+			}
+		}
+		if len(snapshots) == 0 && len(changeLogs) == 0 {
+			return nil, fmt.Errorf("no snapshots or change logs in %s", arg)
+		}
+	}
+	return files, nil
+}
+
+// loadFile applies a snapshot or change log to the inventory. Both formats
+// are a stream of JSON values: a json snapshot is a single Snapshot, anything
+// else is FileRecords, where a run of snapshot records replaces the inventory
+// like a Snapshot does.
+func (inv *Inventory) loadFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	inSnapshot := false
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: record %d: %v", file, n, err)
+		}
+
+		if err := inv.loadRecord(raw, &inSnapshot); err != nil {
+			return fmt.Errorf("%s: record %d: %v", file, n, err)
+		}
+	}
+}
+
+func (inv *Inventory) loadRecord(raw json.RawMessage, inSnapshot *bool) error {
+	var header struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		Record        string `json:"record"`
+		Cluster       string `json:"cluster"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return err
+	}
+	if header.SchemaVersion != fileSchemaVersion {
+		return fmt.Errorf("unsupported schema version %d, expected %d", header.SchemaVersion, fileSchemaVersion)
+	}
+	if inv.Cluster != "" && header.Cluster != inv.Cluster {
+		return fmt.Errorf("record for cluster %q in an import of cluster %q", header.Cluster, inv.Cluster)
+	}
+	inv.Cluster = header.Cluster
+
+	switch header.Record {
+	case "":
+		var snapshot Snapshot
+		if err := json.Unmarshal(raw, &snapshot); err != nil {
+			return err
+		}
+		inv.reset()
+		inv.ClusterInfo = snapshot.ClusterInfo
+		for i := range snapshot.Ingresses {
+			ingress := &snapshot.Ingresses[i]
+			inv.Ingresses[ingress.Namespace+"/"+ingress.IngressName] = ingress
+		}
+		for i := range snapshot.Services {
+			service := &snapshot.Services[i]
+			inv.Services[service.Namespace+"/"+service.ServiceName] = service
+		}
+		for i := range snapshot.Nodes {
+			node := &snapshot.Nodes[i]
+			inv.Nodes[node.NodeName] = node
+		}
+		*inSnapshot = false
+		return nil
+	case recordSnapshot:
+		if !*inSnapshot {
+			inv.reset()
+		}
+		*inSnapshot = true
+	case recordChange:
+		*inSnapshot = false
+	default:
+		return fmt.Errorf("unknown record type %q", header.Record)
+	}
+
+	var record FileRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return err
+	}
+	return inv.apply(record)
+}
+
+// reset empties the inventory for a snapshot, which describes the whole
+// cluster
+func (inv *Inventory) reset() {
+	cluster := inv.Cluster
+	*inv = *NewInventory()
+	inv.Cluster = cluster
+	inv.Complete = true
+}
+
+func (inv *Inventory) apply(record FileRecord) error {
+	deleted := record.Type == eventDeleted
+	key := record.Name
+	if record.Namespace != "" {
+		key = record.Namespace + "/" + record.Name
+	}
+
+	var err error
+	switch record.Kind {
+	case kindCluster:
+		if deleted || len(record.Object) == 0 {
+			return nil
+		}
+		err = decodeObject(record.Object, &inv.ClusterInfo)
+	case kindIngress:
+		var ingress *IngressPayload
+		if !deleted {
+			err = decodeObject(record.Object, &ingress)
+		}
+		inv.Ingresses[key] = ingress
+	case kindService:
+		var service *ServicePayload
+		if !deleted {
+			err = decodeObject(record.Object, &service)
+		}
+		inv.Services[key] = service
+	case kindNode:
+		var node *NodePayload
+		if !deleted {
+			err = decodeObject(record.Object, &node)
+		}
+		inv.Nodes[key] = node
+	default:
+		return fmt.Errorf("unknown kind %q", record.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %s: %v", record.Kind, key, err)
+	}
+	return nil
+}
+
+func decodeObject(object json.RawMessage, out interface{}) error {
+	if len(bytes.TrimSpace(object)) == 0 {
+		return fmt.Errorf("object is missing")
+	}
+	return json.Unmarshal(object, out)
+}
+
+// printPlan writes the changes an import would make, one per line
+func printPlan(w io.Writer, inventory *Inventory, plan []PlannedChange) {
+	if inventory.ClusterInfo != nil {
+		fmt.Fprintf(w, "send cluster info for %s\n", inventory.Cluster)
+	}
+	for _, planned := range plan {
+		fmt.Fprintf(w, "%s %s %s\n", planned.Action, planned.Kind, planned.Key())
+	}
+	counts := planCounts(plan, nil)
+	fmt.Fprintf(w, "%d to create, %d to update, %d to delete\n", counts[actionCreate], counts[actionUpdate], counts[actionDelete])
+}
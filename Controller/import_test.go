@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRunImport(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(FileSinkConfig{Path: dir, Format: "ndjson", SnapshotInterval: time.Hour, MaxChangeLogSize: 1 << 20, Retain: 5}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sink.UpsertService(ctx, ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "web"})
+	sink.UpsertNode(ctx, NodePayload{ClusterName: "test", NodeName: "node-1"})
+	if err := sink.WriteSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	sink.changeLogFile.Close()
+
+	// a backend from before nodes were tracked
+	backend, server := newFakeBackend(t, "ingress", "service")
+	backend.add("service", ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "stale"})
+
+	if err := runImport([]string{"--api-endpoint", server.URL, dir}); err != nil {
+		t.Fatalf("runImport() error = %v", err)
+	}
+
+	if got, want := backend.names("service", "serviceName"), []string{"web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backend services = %v, want %v", got, want)
+	}
+	if n := backend.requests["GET /api/node/cluster/test"]; n != 1 {
+		t.Errorf("node list requested %d times, want 1", n)
+	}
+}
+
+func TestLoadRecord(t *testing.T) {
+	web := `"object":{"clusterName":"test","namespace":"default","serviceName":"web"}`
+	db := `"object":{"clusterName":"test","namespace":"default","serviceName":"db"}`
+
+	tests := []struct {
+		name     string
+		cluster  string
+		records  []string
+		want     []string
+		complete bool
+		wantErr  bool
+	}{
+		{
+			name:    "change adds",
+			records: []string{`{"schemaVersion":1,"record":"change","type":"created","cluster":"test","kind":"service","namespace":"default","name":"web",` + web + `}`},
+			want:    []string{"default/web"},
+		},
+		{
+			name: "delete is kept as known deleted",
+			records: []string{
+				`{"schemaVersion":1,"record":"change","type":"created","cluster":"test","kind":"service","namespace":"default","name":"web",` + web + `}`,
+				`{"schemaVersion":1,"record":"change","type":"deleted","cluster":"test","kind":"service","namespace":"default","name":"web"}`,
+			},
+			want: []string{"default/web (deleted)"},
+		},
+		{
+			name: "snapshot records replace what came before",
+			records: []string{
+				`{"schemaVersion":1,"record":"change","type":"created","cluster":"test","kind":"service","namespace":"default","name":"web",` + web + `}`,
+				`{"schemaVersion":1,"record":"snapshot","cluster":"test","kind":"cluster","name":"test"}`,
+				`{"schemaVersion":1,"record":"snapshot","cluster":"test","kind":"service","namespace":"default","name":"db",` + db + `}`,
+			},
+			want:     []string{"default/db"},
+			complete: true,
+		},
+		{
+			name:     "json snapshot",
+			records:  []string{`{"schemaVersion":1,"cluster":"test","services":[{"clusterName":"test","namespace":"default","serviceName":"db"}]}`},
+			want:     []string{"default/db"},
+			complete: true,
+		},
+		{
+			name:    "unsupported schema version",
+			records: []string{`{"schemaVersion":2,"record":"change","cluster":"test","kind":"service","name":"web"}`},
+			wantErr: true,
+		},
+		{
+			name:    "other cluster",
+			cluster: "prod",
+			records: []string{`{"schemaVersion":1,"record":"change","type":"created","cluster":"test","kind":"service","namespace":"default","name":"web",` + web + `}`},
+			wantErr: true,
+		},
+		{
+			name:    "unknown record type",
+			records: []string{`{"schemaVersion":1,"record":"diff","cluster":"test","kind":"service","name":"web"}`},
+			wantErr: true,
+		},
+		{
+			name:    "upsert without object",
+			records: []string{`{"schemaVersion":1,"record":"change","type":"updated","cluster":"test","kind":"service","namespace":"default","name":"web"}`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := NewInventory()
+			inventory.Cluster = tt.cluster
+			inSnapshot := false
+
+			var err error
+			for _, record := range tt.records {
+				if err = inventory.loadRecord(json.RawMessage(record), &inSnapshot); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for key, service := range inventory.Services {
+				if service == nil {
+					key += " (deleted)"
+				}
+				got = append(got, key)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("services = %v, want %v", got, tt.want)
+			}
+			if inventory.Complete != tt.complete {
+				t.Errorf("complete = %v, want %v", inventory.Complete, tt.complete)
+			}
+		})
+	}
+}
+
+func TestImportFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "change logs only",
+			files: []string{"changes-20250101T000000.000Z.ndjson", "changes-20250102T000000.000Z.ndjson"},
+			want:  []string{"changes-20250101T000000.000Z.ndjson", "changes-20250102T000000.000Z.ndjson"},
+		},
+		{
+			name: "latest snapshot and the change logs from it on",
+			files: []string{
+				"snapshot-20250101T000000.000Z.ndjson",
+				"changes-20250101T000000.000Z.ndjson",
+				"snapshot-20250102T000000.000Z.ndjson",
+				"changes-20250102T000000.000Z.ndjson",
+				"changes-20250102T060000.000Z.ndjson",
+			},
+			want: []string{
+				"snapshot-20250102T000000.000Z.ndjson",
+				"changes-20250102T000000.000Z.ndjson",
+				"changes-20250102T060000.000Z.ndjson",
+			},
+		},
+		{
+			name:  "json snapshots",
+			files: []string{"snapshot-20250101T000000.000Z.json", "changes-20241231T000000.000Z.ndjson", "changes-20250101T120000.000Z.ndjson"},
+			want:  []string{"snapshot-20250101T000000.000Z.json", "changes-20250101T120000.000Z.ndjson"},
+		},
+		{
+			name:  "other files are ignored",
+			files: []string{"changes-20250101T000000.000Z.ndjson", "snapshot-20250102T000000.000Z.ndjson.tmp", "notes.txt"},
+			want:  []string{"changes-20250101T000000.000Z.ndjson"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, err := importFiles([]string{dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, file := range files {
+				got = append(got, filepath.Base(file))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("importFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := importFiles([]string{t.TempDir()}); err == nil {
+		t.Error("importFiles() of an empty directory succeeded")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// registerInventoryAPI adds the read-only inventory endpoints, which serve
+// the same payloads the backend gets straight from the informer caches so
+// in-cluster tools don't depend on the backend being up:
+//
+//	GET /inventory/ingresses?namespace=<ns>&labelSelector=<selector>
+//	GET /inventory/services?namespace=<ns>&labelSelector=<selector>
+//	GET /inventory/cluster
+//
+// Lists answer 503 until the caches have synced, the cluster until cluster
+// info has been collected once.
+func (w *ResourceWatcher) registerInventoryAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /inventory/ingresses", func(rw http.ResponseWriter, r *http.Request) {
+		objs, ok := w.inventoryObjects(rw, r, func() []interface{} { return w.ingressStore.List() })
+		if !ok {
+			return
+		}
+		payloads := []IngressPayload{}
+		for _, obj := range objs {
+			payloads = append(payloads, w.createIngressPayload(obj.(*networkingv1.Ingress)))
+		}
+		sort.Slice(payloads, func(i, j int) bool {
+			return payloads[i].Namespace+"/"+payloads[i].IngressName < payloads[j].Namespace+"/"+payloads[j].IngressName
+		})
+		writeJSON(rw, payloads)
+	})
+
+	mux.HandleFunc("GET /inventory/services", func(rw http.ResponseWriter, r *http.Request) {
+		objs, ok := w.inventoryObjects(rw, r, func() []interface{} { return w.serviceStore.List() })
+		if !ok {
+			return
+		}
+		payloads := []ServicePayload{}
+		for _, obj := range objs {
+			payloads = append(payloads, w.createServicePayload(obj.(*corev1.Service)))
+		}
+		sort.Slice(payloads, func(i, j int) bool {
+			return payloads[i].Namespace+"/"+payloads[i].ServiceName < payloads[j].Namespace+"/"+payloads[j].ServiceName
+		})
+		writeJSON(rw, payloads)
+	})
+
+	mux.HandleFunc("GET /inventory/cluster", func(rw http.ResponseWriter, r *http.Request) {
+		w.clusterInfoMu.RLock()
+		info := w.lastClusterInfo
+		w.clusterInfoMu.RUnlock()
+		if info == nil {
+			http.Error(rw, "cluster info not collected yet", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(rw, info)
+	})
+}
+
+// inventoryObjects lists a cache and applies the namespace and labelSelector
+// query parameters, writing an error response and reporting false if it
+// can't
+func (w *ResourceWatcher) inventoryObjects(rw http.ResponseWriter, r *http.Request, list func() []interface{}) ([]interface{}, bool) {
+	if !w.cachesSynced.Load() {
+		http.Error(rw, "inventory caches not synced yet", http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		http.Error(rw, "invalid labelSelector: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	var objs []interface{}
+	for _, obj := range list() {
+		meta, ok := obj.(metav1.Object)
+		if !ok {
+			continue
+		}
+		if namespace != "" && meta.GetNamespace() != namespace {
+			continue
+		}
+		if !selector.Matches(labels.Set(meta.GetLabels())) {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, true
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		slog.Error("Failed to write inventory response", "error", err)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newInventoryServer serves the inventory API of a watcher whose caches hold
+// the given services and ingresses
+func newInventoryServer(t *testing.T, objects ...interface{}) (*ResourceWatcher, *httptest.Server) {
+	t.Helper()
+	config := testConfig(0)
+	config.InventoryAPI = true
+	w, _, _ := newTestWatcher(t, nil, config)
+	w.ingressStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	w.serviceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *networkingv1.Ingress:
+			w.ingressStore.Add(obj)
+		case *corev1.Service:
+			w.serviceStore.Add(obj)
+		}
+	}
+
+	server := httptest.NewServer(w.healthHandler())
+	t.Cleanup(server.Close)
+	return w, server
+}
+
+// getInventory returns the status and body of a GET on the inventory API
+func getInventory(t *testing.T, server *httptest.Server, path string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestInventoryAPI(t *testing.T) {
+	service := func(namespace, name string, labels map[string]string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+	}
+	ingress := func(namespace, name string, labels map[string]string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+	}
+	w, server := newInventoryServer(t,
+		service("shop", "web", map[string]string{"app": "web", "tier": "frontend"}),
+		service("default", "web", map[string]string{"app": "web"}),
+		service("shop", "db", map[string]string{"app": "db", "tier": "backend"}),
+		service("default", "api", nil),
+		ingress("shop", "web", map[string]string{"app": "web"}),
+		ingress("default", "docs", nil),
+	)
+	w.cachesSynced.Store(true)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantKeys   []string
+	}{
+		{
+			name:       "all services sorted",
+			path:       "/inventory/services",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"default/api", "default/web", "shop/db", "shop/web"},
+		},
+		{
+			name:       "services in a namespace",
+			path:       "/inventory/services?namespace=shop",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"shop/db", "shop/web"},
+		},
+		{
+			name:       "services by label",
+			path:       "/inventory/services?labelSelector=app%3Dweb",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"default/web", "shop/web"},
+		},
+		{
+			name:       "services by set selector in a namespace",
+			path:       "/inventory/services?namespace=shop&labelSelector=tier+in+(frontend,backend),app!=db",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"shop/web"},
+		},
+		{
+			name:       "no match",
+			path:       "/inventory/services?namespace=missing",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{},
+		},
+		{
+			name:       "bad selector",
+			path:       "/inventory/services?labelSelector=app%3D%3D%3Dweb",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "all ingresses sorted",
+			path:       "/inventory/ingresses",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"default/docs", "shop/web"},
+		},
+		{
+			name:       "ingresses by label",
+			path:       "/inventory/ingresses?labelSelector=app",
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"shop/web"},
+		},
+		{
+			name:       "bad ingress selector",
+			path:       "/inventory/ingresses?labelSelector=app+in+web",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := getInventory(t, server, tt.path)
+			if status != tt.wantStatus {
+				t.Fatalf("GET %s = %d %s, want %d", tt.path, status, body, tt.wantStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			// ingress and service payloads share the namespace field and
+			// differ in the name one
+			var payloads []struct {
+				Namespace   string `json:"namespace"`
+				IngressName string `json:"ingressName"`
+				ServiceName string `json:"serviceName"`
+			}
+			if err := json.Unmarshal(body, &payloads); err != nil {
+				t.Fatalf("GET %s: %v in %s", tt.path, err, body)
+			}
+			keys := []string{}
+			for _, payload := range payloads {
+				keys = append(keys, payload.Namespace+"/"+payload.IngressName+payload.ServiceName)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("GET %s = %v, want %v", tt.path, keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestInventoryAPIBeforeSync(t *testing.T) {
+	w, server := newInventoryServer(t, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+
+	for _, path := range []string{"/inventory/ingresses", "/inventory/services", "/inventory/cluster"} {
+		if status, body := getInventory(t, server, path); status != http.StatusServiceUnavailable {
+			t.Errorf("GET %s before sync = %d %s, want %d", path, status, body, http.StatusServiceUnavailable)
+		}
+	}
+
+	// the lists are served once the caches sync, the cluster only once it
+	// has been collected
+	w.cachesSynced.Store(true)
+	if status, body := getInventory(t, server, "/inventory/services"); status != http.StatusOK {
+		t.Errorf("GET /inventory/services after sync = %d %s, want %d", status, body, http.StatusOK)
+	}
+	if status, body := getInventory(t, server, "/inventory/cluster"); status != http.StatusServiceUnavailable {
+		t.Errorf("GET /inventory/cluster before collection = %d %s, want %d", status, body, http.StatusServiceUnavailable)
+	}
+
+	w.clusterInfoMu.Lock()
+	w.lastClusterInfo = &ClusterInfo{ClusterName: "test", APIServerVersion: "v1.29.1"}
+	w.clusterInfoMu.Unlock()
+	status, body := getInventory(t, server, "/inventory/cluster")
+	if status != http.StatusOK {
+		t.Fatalf("GET /inventory/cluster = %d %s, want %d", status, body, http.StatusOK)
+	}
+	var info ClusterInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.ClusterName != "test" || info.APIServerVersion != "v1.29.1" {
+		t.Errorf("GET /inventory/cluster = %+v, want the last collected cluster info", info)
+	}
+}
+
+func TestInventoryAPIDisabled(t *testing.T) {
+	w, _, _ := newTestWatcher(t, nil, testConfig(0))
+	w.cachesSynced.Store(true)
+	server := httptest.NewServer(w.healthHandler())
+	t.Cleanup(server.Close)
+
+	if status, _ := getInventory(t, server, "/inventory/services"); status != http.StatusNotFound {
+		t.Errorf("GET /inventory/services with the API disabled = %d, want %d", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+var (
+	ingressInfoDesc = prometheus.NewDesc("tracker_ingress_info",
+		"Ingresses in the cluster, one series per host.",
+		[]string{"namespace", "name", "host", "class"}, nil)
+	serviceInfoDesc = prometheus.NewDesc("tracker_service_info",
+		"Services in the cluster.",
+		[]string{"namespace", "name", "type", "external_ip"}, nil)
+	nodeInfoDesc = prometheus.NewDesc("tracker_node_info",
+		"Nodes in the cluster with their versions.",
+		[]string{"node", "kubelet_version", "kernel_version"}, nil)
+
+	ingressCountDesc = prometheus.NewDesc("tracker_ingresses",
+		"Number of ingresses by ingress class.",
+		[]string{"class"}, nil)
+	serviceCountDesc = prometheus.NewDesc("tracker_services",
+		"Number of services by type.",
+		[]string{"type"}, nil)
+	nodeCountDesc = prometheus.NewDesc("tracker_nodes",
+		"Number of nodes by kubelet version.",
+		[]string{"kubelet_version"}, nil)
+)
+
+// inventoryCollector exports the inventory itself as metrics, read from the
+// informer caches at scrape time. Nothing is exported until the caches have
+// synced, so a restart doesn't look like everything disappeared and came
+// back one object at a time.
+type inventoryCollector struct {
+	w *ResourceWatcher
+}
+
+func (c inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ingressInfoDesc
+	ch <- serviceInfoDesc
+	ch <- nodeInfoDesc
+	ch <- ingressCountDesc
+	ch <- serviceCountDesc
+	ch <- nodeCountDesc
+}
+
+func (c inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	w := c.w
+	if !w.cachesSynced.Load() {
+		return
+	}
+
+	ingressCounts := make(map[string]int)
+	for _, obj := range w.ingressStore.List() {
+		ingress := obj.(*networkingv1.Ingress)
+		class := ingressClass(ingress)
+		ingressCounts[class]++
+
+		hosts := w.createIngressPayload(ingress).Hosts
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		// rules often repeat a host for different paths
+		seen := make(map[string]bool)
+		for _, host := range hosts {
+			if seen[host] {
+				continue
+			}
+			seen[host] = true
+			ch <- prometheus.MustNewConstMetric(ingressInfoDesc, prometheus.GaugeValue, 1, ingress.Namespace, ingress.Name, host, class)
+		}
+	}
+	for class, count := range ingressCounts {
+		ch <- prometheus.MustNewConstMetric(ingressCountDesc, prometheus.GaugeValue, float64(count), class)
+	}
+
+	serviceCounts := make(map[string]int)
+	for _, obj := range w.serviceStore.List() {
+		payload := w.createServicePayload(obj.(*corev1.Service))
+		serviceCounts[payload.ServiceType]++
+		ch <- prometheus.MustNewConstMetric(serviceInfoDesc, prometheus.GaugeValue, 1, payload.Namespace, payload.ServiceName, payload.ServiceType, payload.ExternalIP)
+	}
+	for serviceType, count := range serviceCounts {
+		ch <- prometheus.MustNewConstMetric(serviceCountDesc, prometheus.GaugeValue, float64(count), serviceType)
+	}
+
+	nodeCounts := make(map[string]int)
+	for _, obj := range w.nodeStore.List() {
+		payload := w.createNodePayload(obj.(*corev1.Node))
+		nodeCounts[payload.KubeletVersion]++
+		ch <- prometheus.MustNewConstMetric(nodeInfoDesc, prometheus.GaugeValue, 1, payload.NodeName, payload.KubeletVersion, payload.KernelVersion)
+	}
+	for version, count := range nodeCounts {
+		ch <- prometheus.MustNewConstMetric(nodeCountDesc, prometheus.GaugeValue, float64(count), version)
+	}
+}
+
+// ingressClass prefers spec.ingressClassName and falls back to the
+// deprecated annotation older ingresses still use
+func ingressClass(ingress *networkingv1.Ingress) string {
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName
+	}
+	return ingress.Annotations["kubernetes.io/ingress.class"]
+}
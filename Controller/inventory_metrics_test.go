@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInventoryCollector(t *testing.T) {
+	nginx := "nginx"
+	rule := func(host string) networkingv1.IngressRule {
+		return networkingv1.IngressRule{Host: host}
+	}
+	w, _, _ := newTestWatcher(t, nil, testConfig(0))
+	w.ingressStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	w.serviceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	w.nodeStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	// shop/web repeats a host for different paths, docs uses the old class
+	// annotation and default/internal has no host at all
+	w.ingressStore.Add(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &nginx,
+			Rules:            []networkingv1.IngressRule{rule("shop.example.com"), rule("www.example.com"), rule("shop.example.com")},
+		},
+	})
+	w.ingressStore.Add(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "docs", Annotations: map[string]string{"kubernetes.io/ingress.class": "traefik"}},
+		Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{rule("docs.example.com")}},
+	})
+	w.ingressStore.Add(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "internal"},
+		Spec:       networkingv1.IngressSpec{IngressClassName: &nginx},
+	})
+
+	w.serviceStore.Add(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		Status:     corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}}},
+	})
+	w.serviceStore.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"}, Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP}})
+	w.serviceStore.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}, Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP}})
+
+	w.nodeStore.Add(testNode("node-a", "v1.29.1"))
+	w.nodeStore.Add(testNode("node-b", "v1.29.1"))
+	w.nodeStore.Add(testNode("node-c", "v1.28.5"))
+
+	collector := inventoryCollector{w: w}
+
+	// a restart mustn't look like everything disappeared
+	if count := testutil.CollectAndCount(collector); count != 0 {
+		t.Errorf("%d series before the caches synced, want none", count)
+	}
+
+	w.cachesSynced.Store(true)
+	want := `
+# HELP tracker_ingress_info Ingresses in the cluster, one series per host.
+# TYPE tracker_ingress_info gauge
+tracker_ingress_info{class="nginx",host="",name="internal",namespace="default"} 1
+tracker_ingress_info{class="nginx",host="shop.example.com",name="web",namespace="shop"} 1
+tracker_ingress_info{class="nginx",host="www.example.com",name="web",namespace="shop"} 1
+tracker_ingress_info{class="traefik",host="docs.example.com",name="docs",namespace="default"} 1
+# HELP tracker_ingresses Number of ingresses by ingress class.
+# TYPE tracker_ingresses gauge
+tracker_ingresses{class="nginx"} 2
+tracker_ingresses{class="traefik"} 1
+# HELP tracker_service_info Services in the cluster.
+# TYPE tracker_service_info gauge
+tracker_service_info{external_ip="",name="api",namespace="default",type="ClusterIP"} 1
+tracker_service_info{external_ip="",name="db",namespace="shop",type="ClusterIP"} 1
+tracker_service_info{external_ip="203.0.113.10",name="web",namespace="shop",type="LoadBalancer"} 1
+# HELP tracker_services Number of services by type.
+# TYPE tracker_services gauge
+tracker_services{type="ClusterIP"} 2
+tracker_services{type="LoadBalancer"} 1
+# HELP tracker_node_info Nodes in the cluster with their versions.
+# TYPE tracker_node_info gauge
+tracker_node_info{kernel_version="6.1.0",kubelet_version="v1.28.5",node="node-c"} 1
+tracker_node_info{kernel_version="6.1.0",kubelet_version="v1.29.1",node="node-a"} 1
+tracker_node_info{kernel_version="6.1.0",kubelet_version="v1.29.1",node="node-b"} 1
+# HELP tracker_nodes Number of nodes by kubelet version.
+# TYPE tracker_nodes gauge
+tracker_nodes{kubelet_version="v1.28.5"} 1
+tracker_nodes{kubelet_version="v1.29.1"} 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// kafkaFlushTimeout bounds how long closing the sink waits for buffered
+// records, so shutdown can't hang on an unreachable broker
+const kafkaFlushTimeout = 10 * time.Second
+
+var errKafkaSinkClosed = errors.New("kafka sink closed")
+
+type KafkaConfig struct {
+	Brokers       []string
+	Topics        map[string]string
+	TLS           bool
+	TLSCAFile     string
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+}
+
+// loadKafkaConfig reads the KAFKA_* settings, only needed when the kafka sink
+// is enabled
+func loadKafkaConfig() (KafkaConfig, error) {
+	config := KafkaConfig{
+		Brokers: listFromEnv("KAFKA_BROKERS", nil),
+		Topics: map[string]string{
+			kindCluster: "tracker.clusters",
+			kindIngress: "tracker.ingresses",
+			kindService: "tracker.services",
+			kindNode:    "tracker.nodes",
+		},
+		TLS:           os.Getenv("KAFKA_TLS") == "true",
+		TLSCAFile:     os.Getenv("KAFKA_TLS_CA_FILE"),
+		SASLMechanism: strings.ToUpper(os.Getenv("KAFKA_SASL_MECHANISM")),
+		SASLUsername:  os.Getenv("KAFKA_SASL_USERNAME"),
+		SASLPassword:  os.Getenv("KAFKA_SASL_PASSWORD"),
+	}
+
+	if len(config.Brokers) == 0 {
+		return config, fmt.Errorf("KAFKA_BROKERS environment variable is required for the kafka sink")
+	}
+	logSetting("KAFKA_BROKERS", strings.Join(config.Brokers, ","))
+
+	for kind, env := range map[string]string{
+		kindCluster: "KAFKA_TOPIC_CLUSTERS",
+		kindIngress: "KAFKA_TOPIC_INGRESSES",
+		kindService: "KAFKA_TOPIC_SERVICES",
+		kindNode:    "KAFKA_TOPIC_NODES",
+	} {
+		if topic := os.Getenv(env); topic != "" {
+			config.Topics[kind] = topic
+			logSetting(env, topic)
+		}
+	}
+
+	switch config.SASLMechanism {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if config.SASLUsername == "" || config.SASLPassword == "" {
+			return config, fmt.Errorf("KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required for KAFKA_SASL_MECHANISM %s", config.SASLMechanism)
+		}
+		logSetting("KAFKA_SASL_MECHANISM", config.SASLMechanism)
+	default:
+		return config, fmt.Errorf("invalid KAFKA_SASL_MECHANISM %q", config.SASLMechanism)
+	}
+
+	return config, nil
+}
+
+// KafkaSink publishes a ChangeEvent per change to a topic per kind, keyed by
+// cluster/namespace/name so a compacted topic keeps the latest state of every
+// object. Deletes are followed by a tombstone so compaction eventually drops
+// the key. Writes wait for all in-sync replicas to acknowledge them, so an
+// item only leaves the workqueue once it is safely in Kafka.
+type KafkaSink struct {
+	client      *kgo.Client
+	clusterName string
+	topics      map[string]string
+	events      *eventTracker
+
+	// produces hold closeMu for reading; the client blocks produces after it
+	// is closed, so Close waits for them and later ones fail instead
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+func NewKafkaSink(config KafkaConfig, clusterName string) (*KafkaSink, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(config.Brokers...),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		// give up eventually so a lost broker fails the item back to the
+		// workqueue instead of blocking the worker forever
+		kgo.RecordDeliveryTimeout(30 * time.Second),
+	}
+
+	if config.TLS {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if config.TLSCAFile != "" {
+			ca, err := os.ReadFile(config.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read kafka CA file: %v", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates found in kafka CA file %s", config.TLSCAFile)
+			}
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	switch config.SASLMechanism {
+	case "PLAIN":
+		opts = append(opts, kgo.SASL(plain.Auth{User: config.SASLUsername, Pass: config.SASLPassword}.AsMechanism()))
+	case "SCRAM-SHA-256":
+		opts = append(opts, kgo.SASL(scram.Auth{User: config.SASLUsername, Pass: config.SASLPassword}.AsSha256Mechanism()))
+	case "SCRAM-SHA-512":
+		opts = append(opts, kgo.SASL(scram.Auth{User: config.SASLUsername, Pass: config.SASLPassword}.AsSha512Mechanism()))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %v", err)
+	}
+
+	return &KafkaSink{
+		client:      client,
+		clusterName: clusterName,
+		topics:      config.Topics,
+		events:      newEventTracker(),
+	}, nil
+}
+
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+// Close flushes records still buffered in the client and closes it. Changes
+// published afterwards fail with errKafkaSinkClosed.
+func (s *KafkaSink) Close() error {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaFlushTimeout)
+	defer cancel()
+	err := s.client.Flush(ctx)
+	s.client.Close()
+	if err != nil {
+		return fmt.Errorf("failed to flush kafka client: %v", err)
+	}
+	return nil
+}
+
+// kafkaSinks returns the kafka sinks in sink, looking inside a MultiSink
+func kafkaSinks(sink Sink) []*KafkaSink {
+	switch s := sink.(type) {
+	case *KafkaSink:
+		return []*KafkaSink{s}
+	case *MultiSink:
+		var sinks []*KafkaSink
+		for _, inner := range s.sinks {
+			sinks = append(sinks, kafkaSinks(inner)...)
+		}
+		return sinks
+	}
+	return nil
+}
+
+// closeKafkaSinks closes the kafka sinks in sink on shutdown
+func closeKafkaSinks(sink Sink) {
+	for _, kafkaSink := range kafkaSinks(sink) {
+		if err := kafkaSink.Close(); err != nil {
+			slog.Error("Failed to close kafka sink", "error", err)
+		}
+	}
+}
+
+// produce publishes changes and returns one error per change. Records for
+// the same key land on the same partition in order, so a delete's tombstone
+// always follows its event.
+func (s *KafkaSink) produce(ctx context.Context, changes []Change) []error {
+	errs := make([]error, len(changes))
+
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+	if s.closed {
+		for i := range errs {
+			errs[i] = errKafkaSinkClosed
+		}
+		return errs
+	}
+	records := make([]*kgo.Record, 0, len(changes))
+	owner := make(map[*kgo.Record]int, len(changes))
+
+	for i, change := range changes {
+		value, err := json.Marshal(s.events.newEvent(s.clusterName, change))
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal %s event: %v", change.Kind, err)
+			continue
+		}
+
+		key := []byte(s.clusterName + "/" + change.Key())
+		record := &kgo.Record{Topic: s.topics[change.Kind], Key: key, Value: value}
+		records = append(records, record)
+		owner[record] = i
+
+		if change.Operation == operationDelete {
+			tombstone := &kgo.Record{Topic: s.topics[change.Kind], Key: key}
+			records = append(records, tombstone)
+			owner[tombstone] = i
+		}
+	}
+
+	for _, result := range s.client.ProduceSync(ctx, records...) {
+		i := owner[result.Record]
+		if result.Err != nil && errs[i] == nil {
+			errs[i] = fmt.Errorf("failed to publish %s %s to %s: %v", changes[i].Kind, changes[i].Key(), result.Record.Topic, result.Err)
+		}
+	}
+
+	for i, change := range changes {
+		if errs[i] == nil {
+			s.events.sent(change)
+		}
+	}
+	return errs
+}
+
+func (s *KafkaSink) produceOne(ctx context.Context, change Change) error {
+	return s.produce(ctx, []Change{change})[0]
+}
+
+func (s *KafkaSink) ApplyBatch(ctx context.Context, kind string, changes []Change) ([]error, error) {
+	return s.produce(ctx, changes), nil
+}
+
+func (s *KafkaSink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	return s.produceOne(ctx, Change{Kind: kindCluster, Operation: operationUpsert, Name: s.clusterName, Payload: info})
+}
+
+func (s *KafkaSink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	return s.produceOne(ctx, changeForIngress(payload))
+}
+
+func (s *KafkaSink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	return s.produceOne(ctx, Change{Kind: kindIngress, Operation: operationDelete, Namespace: namespace, Name: name})
+}
+
+func (s *KafkaSink) ListIngresses(ctx context.Context) ([]IngressPayload, error) {
+	return nil, fmt.Errorf("the kafka sink can't list ingresses")
+}
+
+func (s *KafkaSink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	return s.produceOne(ctx, changeForService(payload))
+}
+
+func (s *KafkaSink) DeleteService(ctx context.Context, namespace, name string) error {
+	return s.produceOne(ctx, Change{Kind: kindService, Operation: operationDelete, Namespace: namespace, Name: name})
+}
+
+func (s *KafkaSink) ListServices(ctx context.Context) ([]ServicePayload, error) {
+	return nil, fmt.Errorf("the kafka sink can't list services")
+}
+
+func (s *KafkaSink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	return s.produceOne(ctx, changeForNode(payload))
+}
+
+func (s *KafkaSink) DeleteNode(ctx context.Context, name string) error {
+	return s.produceOne(ctx, Change{Kind: kindNode, Operation: operationDelete, Name: name})
+}
+
+func (s *KafkaSink) ListNodes(ctx context.Context) ([]NodePayload, error) {
+	return nil, fmt.Errorf("the kafka sink can't list nodes")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKafkaSinkApplyBatch(t *testing.T) {
+	topic := "tracker.services"
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	sink, err := NewKafkaSink(KafkaConfig{
+		Brokers: cluster.ListenAddrs(),
+		Topics:  map[string]string{kindService: topic},
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	changes := []Change{
+		changeForService(ServicePayload{Namespace: "default", ServiceName: "web", ClusterName: "test"}),
+		{Kind: kindService, Operation: operationDelete, Namespace: "default", Name: "old"},
+	}
+	errs, err := sink.ApplyBatch(ctx, kindService, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("change %d: %v", i, err)
+		}
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	var records []*kgo.Record
+	for len(records) < 3 {
+		fetches := consumer.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("got %d records before timing out, want 3", len(records))
+		}
+		records = append(records, fetches.Records()...)
+	}
+
+	byKey := make(map[string][]*kgo.Record)
+	for _, record := range records {
+		byKey[string(record.Key)] = append(byKey[string(record.Key)], record)
+	}
+
+	tests := []struct {
+		key   string
+		types []string
+	}{
+		{key: "test/default/web", types: []string{eventCreated}},
+		// the tombstone follows the delete event on the same partition
+		{key: "test/default/old", types: []string{eventDeleted, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got := byKey[tt.key]
+			if len(got) != len(tt.types) {
+				t.Fatalf("got %d records, want %d", len(got), len(tt.types))
+			}
+			for i, record := range got {
+				if tt.types[i] == "" {
+					if record.Value != nil {
+						t.Errorf("record %d value = %s, want a tombstone", i, record.Value)
+					}
+					if record.Partition != got[0].Partition {
+						t.Errorf("tombstone on partition %d, event on %d", record.Partition, got[0].Partition)
+					}
+					continue
+				}
+				var event ChangeEvent
+				if err := json.Unmarshal(record.Value, &event); err != nil {
+					t.Fatal(err)
+				}
+				if event.Type != tt.types[i] || event.Cluster != "test" {
+					t.Errorf("record %d = %+v, want a %s event for cluster test", i, event, tt.types[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKafkaSinkClose(t *testing.T) {
+	topic := "tracker.services"
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	sink, err := NewKafkaSink(KafkaConfig{
+		Brokers: cluster.ListenAddrs(),
+		Topics:  map[string]string{kindService: topic},
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the shutdown path finds the kafka sink behind a MultiSink
+	sinks := kafkaSinks(NewMultiSink(NewMemorySink(), sink))
+	if len(sinks) != 1 || sinks[0] != sink {
+		t.Fatalf("kafkaSinks() = %v, want the kafka sink", sinks)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sink.UpsertService(ctx, ServicePayload{Namespace: "default", ServiceName: "web", ClusterName: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := sink.UpsertService(ctx, ServicePayload{Namespace: "default", ServiceName: "db", ClusterName: "test"}); !errors.Is(err, errKafkaSinkClosed) {
+		t.Errorf("publish after Close() error = %v, want %v", err, errKafkaSinkClosed)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	reasonSyncFailed = "SyncFailed"
+	reasonSynced     = "Synced"
+
+	// failed attempts before a sync counts as persistently failing and the
+	// object gets an event
+	syncFailureEventAttempts = 5
+)
+
+// startEventRecorder records Kubernetes events on the objects being synced,
+// so their owners see in `kubectl describe` when one isn't reaching the
+// inventory. The returned broadcaster must be shut down when done.
+func (w *ResourceWatcher) startEventRecorder() record.EventBroadcaster {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: w.clientset.CoreV1().Events("")})
+	w.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "k8s-watcher"})
+	return broadcaster
+}
+
+// recordSyncFailure records a warning event on an object once its sync has
+// failed syncFailureEventAttempts times, and again if it is given up on
+func (w *ResourceWatcher) recordSyncFailure(kind string, item workQueueItem, attempt int, dropped bool, err error) {
+	if !dropped && attempt != syncFailureEventAttempts {
+		return
+	}
+	obj, ok := w.eventObject(kind, item)
+	if !ok {
+		return
+	}
+
+	w.syncFailuresMu.Lock()
+	w.syncFailures[kind+"/"+item.key] = true
+	w.syncFailuresMu.Unlock()
+
+	if dropped {
+		w.recorder.Eventf(obj, corev1.EventTypeWarning, reasonSyncFailed,
+			"Gave up sending to the inventory after %d attempts: %v", attempt, err)
+		return
+	}
+	w.recorder.Eventf(obj, corev1.EventTypeWarning, reasonSyncFailed,
+		"Failed to send to the inventory %d times, still retrying: %v", attempt, err)
+}
+
+// recordSyncSuccess records a normal event on an object that reached the
+// inventory after a failure was reported on it
+func (w *ResourceWatcher) recordSyncSuccess(kind string, item workQueueItem) {
+	if w.recorder == nil {
+		return
+	}
+
+	w.syncFailuresMu.Lock()
+	failed := w.syncFailures[kind+"/"+item.key]
+	delete(w.syncFailures, kind+"/"+item.key)
+	w.syncFailuresMu.Unlock()
+	if !failed {
+		return
+	}
+
+	if obj, ok := w.eventObject(kind, item); ok {
+		w.recorder.Event(obj, corev1.EventTypeNormal, reasonSynced, "Sent to the inventory after earlier failures")
+	}
+}
+
+// eventObject looks up the object an item is about in the informer cache.
+// Deleted objects have nothing left to put events on.
+func (w *ResourceWatcher) eventObject(kind string, item workQueueItem) (runtime.Object, bool) {
+	if w.recorder == nil || item.operation == "delete" {
+		return nil, false
+	}
+
+	obj, exists, err := w.store(kind).GetByKey(item.key)
+	if err != nil || !exists {
+		return nil, false
+	}
+	object, ok := obj.(runtime.Object)
+	return object, ok
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSyncEvents(t *testing.T) {
+	unavailable := &statusError{status: http.StatusServiceUnavailable, msg: "unavailable"}
+	rejected := &statusError{status: http.StatusBadRequest, msg: "rejected"}
+
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		succeeded bool
+		want      []string
+	}{
+		{
+			name:      "recovers before the fifth attempt",
+			failures:  4,
+			err:       unavailable,
+			succeeded: true,
+		},
+		{
+			name:      "fifth attempt and later success",
+			failures:  5,
+			err:       unavailable,
+			succeeded: true,
+			want: []string{
+				"Warning SyncFailed Failed to send to the inventory 5 times, still retrying: unavailable",
+				"Normal Synced Sent to the inventory after earlier failures",
+			},
+		},
+		{
+			name:     "given up",
+			failures: 6,
+			err:      unavailable,
+			want: []string{
+				"Warning SyncFailed Failed to send to the inventory 5 times, still retrying: unavailable",
+				"Warning SyncFailed Gave up sending to the inventory after 6 attempts: unavailable",
+			},
+		},
+		{
+			name:     "rejected by the backend",
+			failures: 1,
+			err:      rejected,
+			want:     []string{"Warning SyncFailed Gave up sending to the inventory after 1 attempts: rejected"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _, _ := newTestWatcher(t, nil, testConfig(0))
+			recorder := record.NewFakeRecorder(10)
+			w.recorder = recorder
+			w.serviceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+			w.serviceStore.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+			item := workQueueItem{key: "default/web", namespace: "default", name: "web", operation: "update"}
+
+			for i := 0; i < tt.failures; i++ {
+				w.requeueOrDrop(context.Background(), w.serviceQueue, kindService, item, item, tt.err)
+			}
+			if tt.succeeded {
+				w.recordSyncSuccess(kindService, item)
+			}
+
+			var got []string
+			for len(recorder.Events) > 0 {
+				got = append(got, <-recorder.Events)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"k8s.io/klog/v2"
+)
+
+// setupLogging installs the default slog logger writing to stderr in the
+// given format, json or text, and routes klog (client-go) and the standard
+// log package through it
+func setupLogging(level, format string) error {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q, must be debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, must be json or text", format)
+	}
+
+	setLogger(slog.New(handler))
+	return nil
+}
+
+// logCluster adds the cluster name to everything logged from now on
+func logCluster(name string) {
+	setLogger(slog.Default().With("cluster", name))
+}
+
+func setLogger(logger *slog.Logger) {
+	slog.SetDefault(logger)
+	klog.SetSlogLogger(logger)
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// logSetting logs a configuration value read from the environment
+func logSetting(name string, value interface{}) {
+	slog.Info("Loaded setting", "setting", name, "value", value)
+}
+
+// rawJSON logs a JSON document nested in json output and as plain text in
+// text output
+type rawJSON []byte
+
+func (r rawJSON) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 || !json.Valid(r) {
+		return json.Marshal(string(r))
+	}
+	return r, nil
+}
+
+func (r rawJSON) MarshalText() ([]byte, error) {
+	return r, nil
+}
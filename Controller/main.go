@@ -1,77 +1,362 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
 type Config struct {
-	APIEndpoint        string
-	ConfigMapName      string
-	ConfigMapNamespace string
+	APIEndpoint                string
+	Sinks                      []string
+	ConfigMapName              string
+	ConfigMapNamespace         string
+	ClusterInfoInterval        time.Duration
+	ClusterInfoDebounce        time.Duration
+	ClusterVersionPollInterval time.Duration
+	EndpointsDebounce          time.Duration
+	SupportCalendarFile        string
+	OutboxPath                 string
+	MetricsAddr                string
+	InventoryAPI               bool
+	InventoryMetrics           bool
+	TracingEndpoint            string
+	CircuitFailureThreshold    int
+	CircuitOpenTimeout         time.Duration
+	Kafka                      KafkaConfig
+	NATS                       NATSConfig
+	Webhooks                   []Webhook
+	FileSink                   FileSinkConfig
+	BatchWindow                time.Duration
+	BatchMaxSize               int
+	// set from the command line rather than the environment
+	DryRun      bool
+	DryRunReads bool
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
+	sinks := listFromEnv("SINKS", []string{"rest"})
+	logSetting("SINKS", strings.Join(sinks, ","))
+
 	apiEndpoint := os.Getenv("API_ENDPOINT")
-	if apiEndpoint == "" {
+	if apiEndpoint == "" && contains(sinks, "rest") {
 		return nil, fmt.Errorf("API_ENDPOINT environment variable is required")
 	}
-	log.Printf("Loaded API_ENDPOINT: %s", apiEndpoint)
+	logSetting("API_ENDPOINT", apiEndpoint)
 
 	configMapName := os.Getenv("CONFIGMAP_NAME")
 	if configMapName == "" {
 		return nil, fmt.Errorf("CONFIGMAP_NAME environment variable is required")
 	}
-	log.Printf("Loaded CONFIGMAP_NAME: %s", configMapName)
+	logSetting("CONFIGMAP_NAME", configMapName)
 
 	configMapNamespace := os.Getenv("CONFIGMAP_NAMESPACE")
 	if configMapNamespace == "" {
 		return nil, fmt.Errorf("CONFIGMAP_NAMESPACE environment variable is required")
 	}
-	log.Printf("Loaded CONFIGMAP_NAMESPACE: %s", configMapNamespace)
+	logSetting("CONFIGMAP_NAMESPACE", configMapNamespace)
+
+	clusterInfoInterval, err := durationFromEnv("CLUSTER_INFO_INTERVAL", 4*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterInfoDebounce, err := durationFromEnv("CLUSTER_INFO_DEBOUNCE", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterVersionPollInterval, err := durationFromEnv("CLUSTER_VERSION_POLL_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// how long endpoint count changes are collected before the service is
+	// resent, so pods flapping between ready and not ready don't each cause
+	// an update
+	endpointsDebounce, err := durationFromEnv("ENDPOINTS_DEBOUNCE", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// optional JSON file extending the built-in Kubernetes support calendar
+	supportCalendarFile := os.Getenv("SUPPORT_CALENDAR_FILE")
+	if supportCalendarFile != "" {
+		logSetting("SUPPORT_CALENDAR_FILE", supportCalendarFile)
+	}
+
+	// a zero window turns batching off and syncs every item on its own
+	batchWindow := time.Second
+	if value := os.Getenv("BATCH_WINDOW"); value != "" {
+		batchWindow, err = time.ParseDuration(value)
+		if err != nil || batchWindow < 0 {
+			return nil, fmt.Errorf("invalid BATCH_WINDOW %q", value)
+		}
+		logSetting("BATCH_WINDOW", batchWindow.String())
+	}
+
+	batchMaxSize := 500
+	if value := os.Getenv("BATCH_MAX_SIZE"); value != "" {
+		batchMaxSize, err = strconv.Atoi(value)
+		if err != nil || batchMaxSize < 1 {
+			return nil, fmt.Errorf("invalid BATCH_MAX_SIZE %q", value)
+		}
+		logSetting("BATCH_MAX_SIZE", batchMaxSize)
+	}
+
+	// optional bbolt file for changes not yet delivered, should live on a
+	// volume that outlasts the container
+	outboxPath := os.Getenv("OUTBOX_PATH")
+	if outboxPath != "" {
+		logSetting("OUTBOX_PATH", outboxPath)
+	}
+
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = ":8080"
+	}
+	logSetting("METRICS_ADDR", metricsAddr)
+
+	// optional read-only inventory API served next to the metrics
+	inventoryAPI := os.Getenv("INVENTORY_API") == "true"
+	if inventoryAPI {
+		logSetting("INVENTORY_API", true)
+	}
+
+	// optional per-object inventory metrics, off by default since their
+	// cardinality grows with the cluster
+	inventoryMetrics := os.Getenv("INVENTORY_METRICS") == "true"
+	if inventoryMetrics {
+		logSetting("INVENTORY_METRICS", true)
+	}
+
+	// optional OTLP trace export, the exporter reads the rest of the
+	// standard OTEL_EXPORTER_OTLP_* variables itself
+	tracingEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if tracingEndpoint == "" {
+		tracingEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if tracingEndpoint != "" {
+		logSetting("OTEL_EXPORTER_OTLP_ENDPOINT", tracingEndpoint)
+	}
+
+	// consecutive backend failures before requests are paused
+	circuitFailureThreshold := 5
+	if value := os.Getenv("CIRCUIT_FAILURE_THRESHOLD"); value != "" {
+		circuitFailureThreshold, err = strconv.Atoi(value)
+		if err != nil || circuitFailureThreshold < 1 {
+			return nil, fmt.Errorf("invalid CIRCUIT_FAILURE_THRESHOLD %q", value)
+		}
+		logSetting("CIRCUIT_FAILURE_THRESHOLD", circuitFailureThreshold)
+	}
+
+	circuitOpenTimeout, err := durationFromEnv("CIRCUIT_OPEN_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var kafkaConfig KafkaConfig
+	if contains(sinks, "kafka") {
+		kafkaConfig, err = loadKafkaConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var natsConfig NATSConfig
+	if contains(sinks, "nats") {
+		natsConfig, err = loadNATSConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var webhooks []Webhook
+	if contains(sinks, "webhook") {
+		webhooksFile := os.Getenv("WEBHOOKS_FILE")
+		if webhooksFile == "" {
+			return nil, fmt.Errorf("WEBHOOKS_FILE environment variable is required for the webhook sink")
+		}
+		logSetting("WEBHOOKS_FILE", webhooksFile)
+
+		webhooks, err = loadWebhooks(webhooksFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fileSinkConfig FileSinkConfig
+	if contains(sinks, "file") {
+		fileSinkConfig, err = loadFileSinkConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &Config{
-		APIEndpoint:        apiEndpoint,
-		ConfigMapName:      configMapName,
-		ConfigMapNamespace: configMapNamespace,
+		APIEndpoint:                apiEndpoint,
+		Sinks:                      sinks,
+		ConfigMapName:              configMapName,
+		ConfigMapNamespace:         configMapNamespace,
+		ClusterInfoInterval:        clusterInfoInterval,
+		ClusterInfoDebounce:        clusterInfoDebounce,
+		ClusterVersionPollInterval: clusterVersionPollInterval,
+		EndpointsDebounce:          endpointsDebounce,
+		SupportCalendarFile:        supportCalendarFile,
+		OutboxPath:                 outboxPath,
+		MetricsAddr:                metricsAddr,
+		InventoryAPI:               inventoryAPI,
+		InventoryMetrics:           inventoryMetrics,
+		TracingEndpoint:            tracingEndpoint,
+		CircuitFailureThreshold:    circuitFailureThreshold,
+		CircuitOpenTimeout:         circuitOpenTimeout,
+		Kafka:                      kafkaConfig,
+		NATS:                       natsConfig,
+		Webhooks:                   webhooks,
+		FileSink:                   fileSinkConfig,
+		BatchWindow:                batchWindow,
+		BatchMaxSize:               batchMaxSize,
 	}, nil
 }
 
+// listFromEnv reads an optional comma separated list from the environment,
+// falling back to def when unset
+func listFromEnv(name string, def []string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// durationFromEnv reads an optional duration such as "30s" or "4h" from the
+// environment, falling back to def when unset
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", name, value)
+	}
+	logSetting(name, d.String())
+	return d, nil
+}
+
 type ResourceWatcher struct {
-	clientset    *kubernetes.Clientset
-	clusterName  string
-	httpClient   *http.Client
-	config       *Config
-	ingressQueue workqueue.RateLimitingInterface
-	serviceQueue workqueue.RateLimitingInterface
+	clientset            kubernetes.Interface
+	dynamicClient        dynamic.Interface
+	clusterName          string
+	clusterEnvironment   string
+	clusterUIDMu         sync.Mutex
+	clusterUID           string
+	sink                 Sink
+	breaker              *CircuitBreaker
+	outbox               *Outbox
+	config               *Config
+	ingressQueue         workqueue.RateLimitingInterface
+	serviceQueue         workqueue.RateLimitingInterface
+	nodeQueue            workqueue.RateLimitingInterface
+	ingressStore         cache.Store
+	serviceStore         cache.Store
+	nodeStore            cache.Store
+	endpointSliceIndexer cache.Indexer
+	endpointCountsMu     sync.Mutex
+	endpointCounts       map[string]EndpointCounts
+	clusterInfoTrigger   chan string
+	supportCalendar      map[string]time.Time
+	lastAPIServerVersion string
+
+	// the latest cluster info collected, and whether the informer caches
+	// have synced, for the inventory API
+	clusterInfoMu   sync.RWMutex
+	lastClusterInfo *ClusterInfo
+	cachesSynced    atomic.Bool
+
+	// event spans of queued items, see pendingTrace
+	pendingTracesMu sync.Mutex
+	pendingTraces   map[string]pendingTrace
+
+	// records Kubernetes events on objects failing to sync, and which
+	// objects have a failure reported on them
+	recorder       record.EventRecorder
+	syncFailuresMu sync.Mutex
+	syncFailures   map[string]bool
+
+	// where the informers list and watch a resource from, the API server
+	// when nil
+	listWatch func(resource string) cache.ListerWatcher
 }
 
 type ClusterInfo struct {
-	ClusterName      string   `json:"clusterName"`
-	APIServerVersion string   `json:"apiServerVersion"`
-	KubeletVersions  []string `json:"kubeletVersions"`
-	KernelVersions   []string `json:"kernelVersions"`
+	ClusterName       string            `json:"clusterName"`
+	ClusterUID        string            `json:"clusterUid"`
+	Environment       string            `json:"environment"`
+	Distribution      string            `json:"distribution"`
+	Provider          string            `json:"provider"`
+	APIServerVersion  string            `json:"apiServerVersion"`
+	KubeletVersions   []string          `json:"kubeletVersions"`
+	KernelVersions    []string          `json:"kernelVersions"`
+	ContainerRuntimes []string          `json:"containerRuntimes"`
+	OSImages          []string          `json:"osImages"`
+	Architectures     []string          `json:"architectures"`
+	NodeCount         int               `json:"nodeCount"`
+	TotalCapacity     NodeResources     `json:"totalCapacity"`
+	TotalAllocatable  NodeResources     `json:"totalAllocatable"`
+	APIGroups         []APIGroupInfo    `json:"apiGroups"`
+	Addons            []Addon           `json:"addons"`
+	UpgradeReadiness  *UpgradeReadiness `json:"upgradeReadiness"`
+	VersionAnalysis   *VersionAnalysis  `json:"versionAnalysis"`
 }
 
 type IngressPayload struct {
@@ -82,32 +367,29 @@ type IngressPayload struct {
 	Ports       []int32  `json:"ports"`
 }
 
-type IngressResponse struct {
-	ID          int      `json:"id"`
-	ClusterName string   `json:"clusterName"`
-	Namespace   string   `json:"namespace"`
-	IngressName string   `json:"ingressName"`
-	Hosts       []string `json:"hosts"`
-	Ports       []int32  `json:"ports"`
+type ServicePort struct {
+	Name       string `json:"name"`
+	Protocol   string `json:"protocol"`
+	Port       int32  `json:"port"`
+	TargetPort string `json:"targetPort"`
+	NodePort   int32  `json:"nodePort,omitempty"`
 }
 
 type ServicePayload struct {
-	ClusterName string  `json:"clusterName"`
-	Namespace   string  `json:"namespace"`
-	ServiceName string  `json:"serviceName"`
-	ExternalIP  string  `json:"externalIp"`
-	Ports       []int32 `json:"ports"`
-	ServiceType string  `json:"serviceType"`
-}
-
-type ServiceResponse struct {
-	ID          int     `json:"id"`
-	ClusterName string  `json:"clusterName"`
-	Namespace   string  `json:"namespace"`
-	ServiceName string  `json:"serviceName"`
-	ExternalIP  string  `json:"externalIp"`
-	Ports       []int32 `json:"ports"`
-	ServiceType string  `json:"serviceType"`
+	ClusterName           string        `json:"clusterName"`
+	Namespace             string        `json:"namespace"`
+	ServiceName           string        `json:"serviceName"`
+	ExternalIP            string        `json:"externalIp"`
+	Ports                 []int32       `json:"ports"`
+	ServiceType           string        `json:"serviceType"`
+	ClusterIPs            []string      `json:"clusterIps"`
+	ExternalIPs           []string      `json:"externalIps"`
+	LoadBalancerIPs       []string      `json:"loadBalancerIps"`
+	LoadBalancerHostnames []string      `json:"loadBalancerHostnames"`
+	ExternalName          string        `json:"externalName,omitempty"`
+	PortDetails           []ServicePort `json:"portDetails"`
+	ReadyEndpoints        int           `json:"readyEndpoints"`
+	NotReadyEndpoints     int           `json:"notReadyEndpoints"`
 }
 
 type workQueueItem struct {
@@ -115,6 +397,10 @@ type workQueueItem struct {
 	namespace string
 	name      string
 	operation string
+	// uid and resourceVersion are those of the deleted object on deletes,
+	// which can't be read from the cache anymore
+	uid             string
+	resourceVersion string
 }
 
 func NewResourceWatcher(k8sConfig *rest.Config, appConfig *Config) (*ResourceWatcher, error) {
@@ -123,6 +409,11 @@ func NewResourceWatcher(k8sConfig *rest.Config, appConfig *Config) (*ResourceWat
 		return nil, fmt.Errorf("failed to create kubernetes clientset: %v", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+
 	cm, err := clientset.CoreV1().ConfigMaps(appConfig.ConfigMapNamespace).Get(
 		context.Background(),
 		appConfig.ConfigMapName,
@@ -136,21 +427,65 @@ func NewResourceWatcher(k8sConfig *rest.Config, appConfig *Config) (*ResourceWat
 	if !ok {
 		return nil, fmt.Errorf("cluster_name not found in configmap")
 	}
+	logCluster(clusterName)
 
-	return &ResourceWatcher{
-		clientset:   clientset,
-		clusterName: clusterName,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
-			},
+	supportCalendar, err := loadSupportCalendar(appConfig.SupportCalendarFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// optional, the chart always sets it but older configmaps may not have it
+	clusterEnvironment := cm.Data["cluster-environment"]
+
+	// every backend request goes through the breaker so an unhealthy backend
+	// isn't hammered by retries from all workers
+	var transport http.RoundTripper = &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
 		},
-		config:       appConfig,
-		ingressQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ingresses"),
-		serviceQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "services"),
+	}
+	if appConfig.DryRun {
+		slog.Info("Dry run, backend writes are logged instead of sent")
+		transport = newDryRunTransport(transport, appConfig.DryRunReads)
+	}
+	breaker := NewCircuitBreaker(transport, appConfig.CircuitFailureThreshold, appConfig.CircuitOpenTimeout)
+
+	httpClient := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: tracingTransport(breaker),
+	}
+
+	sink, err := newSink(appConfig, clusterName, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sink: %v", err)
+	}
+	slog.Info("Sending inventory to sink", "sink", sink.Name())
+
+	var outbox *Outbox
+	if appConfig.OutboxPath != "" {
+		outbox, err = OpenOutbox(appConfig.OutboxPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ResourceWatcher{
+		clientset:          clientset,
+		dynamicClient:      dynamicClient,
+		clusterName:        clusterName,
+		clusterEnvironment: clusterEnvironment,
+		sink:               sink,
+		breaker:            breaker,
+		outbox:             outbox,
+		config:             appConfig,
+		ingressQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ingresses"),
+		serviceQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "services"),
+		nodeQueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "nodes"),
+		endpointCounts:     make(map[string]EndpointCounts),
+		pendingTraces:      make(map[string]pendingTrace),
+		syncFailures:       make(map[string]bool),
+		clusterInfoTrigger: make(chan string, 1),
+		supportCalendar:    supportCalendar,
 	}, nil
 }
 
@@ -158,46 +493,31 @@ func (w *ResourceWatcher) WatchResources(ctx context.Context) error {
 	defer runtime.HandleCrash()
 	defer w.ingressQueue.ShutDown()
 	defer w.serviceQueue.ShutDown()
+	defer w.nodeQueue.ShutDown()
+	if w.outbox != nil {
+		defer w.outbox.Close()
+	}
+	defer closeKafkaSinks(w.sink)
+	defer w.startEventRecorder().Shutdown()
 
 	// initial blocking run of cluster update
 	// make sure no service/ingress are attempted before a cluster exists in the db
 	if err := w.collectAndSendClusterInfo(); err != nil {
-		log.Printf("Initial cluster info collection failed: %v", err)
+		slog.Error("Initial cluster info collection failed", "error", err)
 	}
 
-	go func() {
-		// check cluster info every 4 hours
-		ticker := time.NewTicker(4 * time.Hour)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				log.Printf("Context cancelled: %v", ctx.Err())
-				return
-			case <-ticker.C:
-				log.Printf("Ticker fired at %v", time.Now())
-				if err := w.collectAndSendClusterInfo(); err != nil {
-					log.Printf("Periodic cluster info collection failed: %v", err)
-				}
-			}
-		}
-	}()
-
-	// Start worker goroutines
-	for i := 0; i < 2; i++ {
-		go wait.Until(func() { w.runIngressWorker(ctx) }, time.Second, ctx.Done())
-		go wait.Until(func() { w.runServiceWorker(ctx) }, time.Second, ctx.Done())
+	if w.config.InventoryMetrics {
+		prometheus.MustRegister(inventoryCollector{w: w})
 	}
+	go w.serveHealth(ctx)
+	go w.runClusterInfoLoop(ctx)
 
-	ingressListWatcher := cache.NewListWatchFromClient(
-		w.clientset.NetworkingV1().RESTClient(),
-		"ingresses",
-		corev1.NamespaceAll,
-		fields.Everything(),
-	)
+	ingressListWatcher, err := w.listerWatcher("ingresses")
+	if err != nil {
+		return err
+	}
 
-	_, ingressController := cache.NewInformer(
+	ingressStore, ingressController := cache.NewInformer(
 		ingressListWatcher,
 		&networkingv1.Ingress{},
 		// catch-all resync run daily
@@ -211,14 +531,33 @@ func (w *ResourceWatcher) WatchResources(ctx context.Context) error {
 		},
 	)
 
-	serviceListWatcher := cache.NewListWatchFromClient(
-		w.clientset.CoreV1().RESTClient(),
-		"services",
-		corev1.NamespaceAll,
-		fields.Everything(),
+	endpointSliceListWatcher, err := w.listerWatcher("endpointslices")
+	if err != nil {
+		return err
+	}
+
+	endpointSliceIndexer, endpointSliceController := cache.NewIndexerInformer(
+		endpointSliceListWatcher,
+		&discoveryv1.EndpointSlice{},
+		// catch-all resync run daily
+		time.Hour*24,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: w.handleEndpointSliceChange,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				w.handleEndpointSliceChange(newObj)
+			},
+			DeleteFunc: w.handleEndpointSliceChange,
+		},
+		cache.Indexers{endpointSliceServiceIndex: endpointSliceServiceKey},
 	)
+	w.endpointSliceIndexer = endpointSliceIndexer
+
+	serviceListWatcher, err := w.listerWatcher("services")
+	if err != nil {
+		return err
+	}
 
-	_, serviceController := cache.NewInformer(
+	serviceStore, serviceController := cache.NewInformer(
 		serviceListWatcher,
 		&corev1.Service{},
 		// catch-all resync run daily
@@ -232,19 +571,103 @@ func (w *ResourceWatcher) WatchResources(ctx context.Context) error {
 		},
 	)
 
+	nodeListWatcher, err := w.listerWatcher("nodes")
+	if err != nil {
+		return err
+	}
+
+	nodeStore, nodeController := cache.NewInformer(
+		nodeListWatcher,
+		&corev1.Node{},
+		// catch-all resync run daily
+		time.Hour*24,
+		cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc:    w.handleNodeAdd,
+			UpdateFunc: w.handleNodeUpdate,
+			DeleteFunc: w.handleNodeDelete,
+		},
+	)
+
+	w.ingressStore = ingressStore
+	w.serviceStore = serviceStore
+	w.nodeStore = nodeStore
+
+	// workers resolve queued keys against the stores, so they may only start
+	// once all of them are assigned
+	if w.config.BatchWindow > 0 || w.outbox != nil {
+		// batch workers must be the only consumer of their queue; they also
+		// own the outbox, so with batching off they run without a window
+		go wait.Until(func() { w.runBatchWorker(ctx, w.ingressQueue, kindIngress) }, time.Second, ctx.Done())
+		go wait.Until(func() { w.runBatchWorker(ctx, w.serviceQueue, kindService) }, time.Second, ctx.Done())
+		go wait.Until(func() { w.runBatchWorker(ctx, w.nodeQueue, kindNode) }, time.Second, ctx.Done())
+	} else {
+		for i := 0; i < 2; i++ {
+			go wait.Until(func() { w.runIngressWorker(ctx) }, time.Second, ctx.Done())
+			go wait.Until(func() { w.runServiceWorker(ctx) }, time.Second, ctx.Done())
+			go wait.Until(func() { w.runNodeWorker(ctx) }, time.Second, ctx.Done())
+		}
+	}
+
+	// endpoint counts are read while building service payloads, so the slice
+	// cache has to be populated before the first services are synced
+	go endpointSliceController.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), endpointSliceController.HasSynced) {
+		return fmt.Errorf("failed to sync endpointslice cache")
+	}
+
 	go ingressController.Run(ctx.Done())
 	go serviceController.Run(ctx.Done())
+	go nodeController.Run(ctx.Done())
+	go w.newCRDInformer().Run(ctx.Done())
+
+	go func() {
+		if cache.WaitForCacheSync(ctx.Done(), ingressController.HasSynced, serviceController.HasSynced, nodeController.HasSynced) {
+			w.cachesSynced.Store(true)
+			w.startSnapshots(ctx)
+		}
+	}()
+
+	if w.outbox != nil {
+		if !cache.WaitForCacheSync(ctx.Done(), ingressController.HasSynced, serviceController.HasSynced, nodeController.HasSynced) {
+			return fmt.Errorf("failed to sync caches before replaying the outbox")
+		}
+		if err := w.replayOutbox(); err != nil {
+			slog.Error("Failed to replay outbox", "error", err)
+		}
+	}
 
 	<-ctx.Done()
 	return nil
 }
 
+// startSnapshots brings file sinks up to date with the synced caches and
+// starts their periodic snapshots, the first of them right away
+func (w *ResourceWatcher) startSnapshots(ctx context.Context) {
+	sinks := fileSinks(w.sink)
+	if len(sinks) == 0 {
+		return
+	}
+
+	inventory := w.inventoryFromStores(w.ingressStore, w.serviceStore, w.nodeStore)
+	for _, sink := range sinks {
+		if err := sink.recordDeletes(ctx, inventory); err != nil {
+			slog.Error("Failed to record deletes in the file sink", "error", err)
+		}
+		go sink.RunSnapshots(ctx)
+	}
+}
+
 func (w *ResourceWatcher) collectClusterInfo() (*ClusterInfo, error) {
 	serverVersion, err := w.clientset.Discovery().ServerVersion()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get server version: %v", err)
 	}
 
+	clusterUID, err := w.getClusterUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster uid: %v", err)
+	}
+
 	nodes, err := w.clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list nodes: %v", err)
@@ -252,92 +675,66 @@ func (w *ResourceWatcher) collectClusterInfo() (*ClusterInfo, error) {
 
 	kernelVersions := make(map[string]struct{})
 	kubeletVersions := make(map[string]struct{})
+	containerRuntimes := make(map[string]struct{})
+	osImages := make(map[string]struct{})
+	architectures := make(map[string]struct{})
+	var capacity, allocatable NodeResources
 
-	for _, node := range nodes.Items {
-		kernelVersions[node.Status.NodeInfo.KernelVersion] = struct{}{}
-		kubeletVersions[node.Status.NodeInfo.KubeletVersion] = struct{}{}
-	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		info := node.Status.NodeInfo
 
-	kernelVersionsList := make([]string, 0, len(kernelVersions))
-	kubeletVersionsList := make([]string, 0, len(kubeletVersions))
+		kernelVersions[info.KernelVersion] = struct{}{}
+		kubeletVersions[info.KubeletVersion] = struct{}{}
+		containerRuntimes[info.ContainerRuntimeVersion] = struct{}{}
+		osImages[info.OSImage] = struct{}{}
+		architectures[info.Architecture] = struct{}{}
 
-	for version := range kernelVersions {
-		kernelVersionsList = append(kernelVersionsList, version)
-	}
-	for version := range kubeletVersions {
-		kubeletVersionsList = append(kubeletVersionsList, version)
+		capacity = capacity.add(nodeResources(node.Status.Capacity))
+		allocatable = allocatable.add(nodeResources(node.Status.Allocatable))
 	}
 
-	return &ClusterInfo{
-		ClusterName:      w.clusterName,
-		APIServerVersion: serverVersion.GitVersion,
-		KubeletVersions:  kubeletVersionsList,
-		KernelVersions:   kernelVersionsList,
-	}, nil
-}
+	distribution, provider := detectPlatform(serverVersion.GitVersion, nodes.Items)
 
-func (w *ResourceWatcher) sendClusterInfo(clusterInfo *ClusterInfo) error {
-	resp, err := w.httpClient.Get(
-		fmt.Sprintf("%s/api/clusters/name/%s", w.config.APIEndpoint, w.clusterName),
-	)
+	// the add-on inventory is best effort, a missing permission shouldn't keep
+	// the rest of the cluster info from being sent
+	apiGroups, addons, err := w.collectAPIInventory()
 	if err != nil {
-		return fmt.Errorf("failed to check cluster existence: %v", err)
+		slog.Warn("Failed to collect API inventory", "error", err)
+		apiGroups = []APIGroupInfo{}
+		addons = []Addon{}
 	}
-	defer resp.Body.Close()
 
-	type ClusterResponse struct {
-		ID               int      `json:"id"`
-		ClusterName      string   `json:"clusterName"`
-		APIServerVersion string   `json:"apiserverVersion"`
-		KubeletVersions  []string `json:"kubeletVersions"`
-	}
-
-	var req *http.Request
-	jsonData, err := json.Marshal(clusterInfo)
+	upgradeReadiness, err := w.collectUpgradeReadiness(serverVersion.GitVersion)
 	if err != nil {
-		return fmt.Errorf("failed to marshal cluster info: %v", err)
-	}
-
-	if resp.StatusCode == http.StatusOK {
-		// Cluster exists, get its ID and update
-		var existingCluster ClusterResponse
-		if err := json.NewDecoder(resp.Body).Decode(&existingCluster); err != nil {
-			return fmt.Errorf("failed to decode existing cluster response: %v", err)
-		}
-
-		req, err = http.NewRequest(
-			http.MethodPut,
-			fmt.Sprintf("%s/api/clusters/%d", w.config.APIEndpoint, existingCluster.ID),
-			bytes.NewBuffer(jsonData),
-		)
-		log.Printf("Updating existing cluster with ID: %d", existingCluster.ID)
-	} else {
-		// Cluster doesn't exist, create new
-		req, err = http.NewRequest(
-			http.MethodPost,
-			fmt.Sprintf("%s/api/clusters", w.config.APIEndpoint),
-			bytes.NewBuffer(jsonData),
-		)
-		log.Printf("Creating new cluster entry")
+		slog.Warn("Failed to collect upgrade readiness", "error", err)
 	}
 
+	versionAnalysis, err := analyzeVersions(serverVersion.GitVersion, nodes.Items, w.supportCalendar, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		slog.Warn("Failed to analyze versions", "error", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err = w.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("received non-OK response: %d - %s", resp.StatusCode, string(body))
-	}
-
-	return nil
+	return &ClusterInfo{
+		ClusterName:       w.clusterName,
+		ClusterUID:        clusterUID,
+		Environment:       w.clusterEnvironment,
+		Distribution:      distribution,
+		Provider:          provider,
+		APIServerVersion:  serverVersion.GitVersion,
+		KubeletVersions:   sortedKeys(kubeletVersions),
+		KernelVersions:    sortedKeys(kernelVersions),
+		ContainerRuntimes: sortedKeys(containerRuntimes),
+		OSImages:          sortedKeys(osImages),
+		Architectures:     sortedKeys(architectures),
+		NodeCount:         len(nodes.Items),
+		TotalCapacity:     capacity,
+		TotalAllocatable:  allocatable,
+		APIGroups:         apiGroups,
+		Addons:            addons,
+		UpgradeReadiness:  upgradeReadiness,
+		VersionAnalysis:   versionAnalysis,
+	}, nil
 }
 
 func (w *ResourceWatcher) collectAndSendClusterInfo() error {
@@ -346,11 +743,16 @@ func (w *ResourceWatcher) collectAndSendClusterInfo() error {
 		return fmt.Errorf("failed to collect cluster info: %v", err)
 	}
 
-	if err := w.sendClusterInfo(clusterInfo); err != nil {
+	w.clusterInfoMu.Lock()
+	w.lastClusterInfo = clusterInfo
+	w.clusterInfoMu.Unlock()
+
+	if err := w.sink.SendClusterInfo(context.TODO(), clusterInfo); err != nil {
 		return fmt.Errorf("failed to send cluster info: %v", err)
 	}
 
-	log.Printf("Successfully collected and sent cluster info for cluster: %s", w.clusterName)
+	w.lastAPIServerVersion = clusterInfo.APIServerVersion
+	slog.Info("Collected and sent cluster info", "kind", kindCluster, "name", w.clusterName)
 	return nil
 }
 
@@ -412,53 +814,20 @@ func uniquePorts(ports []int32) []int32 {
 	return unique
 }
 
-func (w *ResourceWatcher) findIngressID(ingressName string) (int, error) {
-	resp, err := w.httpClient.Get(
-		fmt.Sprintf("%s/api/ingress/cluster/%s", w.config.APIEndpoint, w.clusterName),
-	)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var ingresses []IngressResponse
-	if err := json.Unmarshal(body, &ingresses); err != nil {
-		return 0, err
-	}
-
-	for _, ing := range ingresses {
-		if ing.IngressName == ingressName {
-			id := ing.ID
-			return id, nil
-		}
-	}
-
-	return 0, fmt.Errorf("ingress not found")
-}
-
 func (w *ResourceWatcher) handleIngressChange(obj interface{}) {
 	if obj == nil {
-		log.Printf("Error: received nil object in handleIngressChange")
+		slog.Error("Received nil object in handleIngressChange")
 		return
 	}
 
 	ingress, ok := obj.(*networkingv1.Ingress)
 	if !ok {
-		log.Printf("Error: unexpected type for ingress object")
+		slog.Error("Unexpected type for ingress object")
 		return
 	}
 
 	key := fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name)
-	w.ingressQueue.Add(workQueueItem{
+	w.enqueue(w.ingressQueue, kindIngress, workQueueItem{
 		key:       key,
 		namespace: ingress.Namespace,
 		name:      ingress.Name,
@@ -467,99 +836,139 @@ func (w *ResourceWatcher) handleIngressChange(obj interface{}) {
 }
 
 func (w *ResourceWatcher) handleIngressDelete(obj interface{}) {
-	ingress := obj.(*networkingv1.Ingress)
-	key := fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name)
-	w.ingressQueue.Add(workQueueItem{
-		key:       key,
-		namespace: ingress.Namespace,
-		name:      ingress.Name,
-		operation: "delete",
-	})
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		slog.Error("Unexpected type for ingress object")
+		return
+	}
+
+	item := workQueueItem{
+		key:             fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name),
+		namespace:       ingress.Namespace,
+		name:            ingress.Name,
+		operation:       "delete",
+		uid:             string(ingress.UID),
+		resourceVersion: ingress.ResourceVersion,
+	}
+	w.recordDelete(kindIngress, item)
+	w.enqueue(w.ingressQueue, kindIngress, item)
 }
 
 func (w *ResourceWatcher) createServicePayload(service *corev1.Service) ServicePayload {
 	if service == nil {
 		return ServicePayload{
-			ClusterName: w.clusterName,
-			Ports:       []int32{},
+			ClusterName:           w.clusterName,
+			Ports:                 []int32{},
+			ClusterIPs:            []string{},
+			ExternalIPs:           []string{},
+			LoadBalancerIPs:       []string{},
+			LoadBalancerHostnames: []string{},
+			PortDetails:           []ServicePort{},
 		}
 	}
 
+	lbIPs := []string{}
+	lbHostnames := []string{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			lbIPs = append(lbIPs, ingress.IP)
+		}
+		if ingress.Hostname != "" {
+			lbHostnames = append(lbHostnames, ingress.Hostname)
+		}
+	}
+
+	// externalIp stays a single address for the backend; prefer an LB IP, then an
+	// LB hostname (e.g. AWS ELBs), then the first spec.externalIPs entry
 	var externalIP string
-	if len(service.Status.LoadBalancer.Ingress) > 0 {
-		externalIP = service.Status.LoadBalancer.Ingress[0].IP
+	switch {
+	case len(lbIPs) > 0:
+		externalIP = lbIPs[0]
+	case len(lbHostnames) > 0:
+		externalIP = lbHostnames[0]
+	case len(service.Spec.ExternalIPs) > 0:
+		externalIP = service.Spec.ExternalIPs[0]
+	}
+
+	// clusterIPs holds both families on dual-stack services; headless services report "None"
+	specClusterIPs := service.Spec.ClusterIPs
+	if len(specClusterIPs) == 0 && service.Spec.ClusterIP != "" {
+		specClusterIPs = []string{service.Spec.ClusterIP}
+	}
+	clusterIPs := []string{}
+	for _, ip := range specClusterIPs {
+		if ip != corev1.ClusterIPNone {
+			clusterIPs = append(clusterIPs, ip)
+		}
 	}
 
+	externalIPs := []string{}
+	externalIPs = append(externalIPs, service.Spec.ExternalIPs...)
+
 	ports := []int32{}
+	portDetails := []ServicePort{}
 	if service.Spec.Ports != nil {
 		for _, port := range service.Spec.Ports {
 			ports = append(ports, port.Port)
+
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			portDetails = append(portDetails, ServicePort{
+				Name:       port.Name,
+				Protocol:   string(protocol),
+				Port:       port.Port,
+				TargetPort: port.TargetPort.String(),
+				NodePort:   port.NodePort,
+			})
 		}
 	}
 
+	endpoints := w.countEndpoints(service.Namespace, service.Name)
+
 	serviceType := service.Spec.Type
 	if serviceType == "" {
 		serviceType = corev1.ServiceTypeClusterIP
-		log.Printf("Setting default service type to ClusterIP for %s/%s", service.Namespace, service.Name)
+		slog.Debug("Setting default service type to ClusterIP", "kind", kindService, "namespace", service.Namespace, "name", service.Name)
 	}
 
 	return ServicePayload{
-		ClusterName: w.clusterName,
-		Namespace:   service.Namespace,
-		ServiceName: service.Name,
-		ExternalIP:  externalIP,
-		Ports:       ports,
-		ServiceType: string(serviceType),
+		ClusterName:           w.clusterName,
+		Namespace:             service.Namespace,
+		ServiceName:           service.Name,
+		ExternalIP:            externalIP,
+		Ports:                 ports,
+		ServiceType:           string(serviceType),
+		ClusterIPs:            clusterIPs,
+		ExternalIPs:           externalIPs,
+		LoadBalancerIPs:       lbIPs,
+		LoadBalancerHostnames: lbHostnames,
+		ExternalName:          service.Spec.ExternalName,
+		PortDetails:           portDetails,
+		ReadyEndpoints:        endpoints.Ready,
+		NotReadyEndpoints:     endpoints.NotReady,
 	}
 }
 
-func (w *ResourceWatcher) findServiceID(serviceName string) (int, error) {
-	resp, err := w.httpClient.Get(
-		fmt.Sprintf("%s/api/service/cluster/%s", w.config.APIEndpoint, w.clusterName),
-	)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var services []ServiceResponse
-	if err := json.Unmarshal(body, &services); err != nil {
-		return 0, err
-	}
-
-	for _, svc := range services {
-		if svc.ServiceName == serviceName {
-			id := svc.ID
-			return id, nil
-		}
-	}
-
-	return 0, fmt.Errorf("service not found")
-}
-
 func (w *ResourceWatcher) handleServiceChange(obj interface{}) {
 	if obj == nil {
-		log.Printf("Error: received nil object in handleServiceChange")
+		slog.Error("Received nil object in handleServiceChange")
 		return
 	}
 
 	service, ok := obj.(*corev1.Service)
 	if !ok {
-		log.Printf("Error: unexpected type for service object")
+		slog.Error("Unexpected type for service object")
 		return
 	}
 
 	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	w.serviceQueue.Add(workQueueItem{
+	w.enqueue(w.serviceQueue, kindService, workQueueItem{
 		key:       key,
 		namespace: service.Namespace,
 		name:      service.Name,
@@ -568,23 +977,41 @@ func (w *ResourceWatcher) handleServiceChange(obj interface{}) {
 }
 
 func (w *ResourceWatcher) handleServiceDelete(obj interface{}) {
-	service := obj.(*corev1.Service)
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		slog.Error("Unexpected type for service object")
+		return
+	}
+
 	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	w.serviceQueue.Add(workQueueItem{
-		key:       key,
-		namespace: service.Namespace,
-		name:      service.Name,
-		operation: "delete",
-	})
+
+	w.endpointCountsMu.Lock()
+	delete(w.endpointCounts, key)
+	w.endpointCountsMu.Unlock()
+
+	item := workQueueItem{
+		key:             key,
+		namespace:       service.Namespace,
+		name:            service.Name,
+		operation:       "delete",
+		uid:             string(service.UID),
+		resourceVersion: service.ResourceVersion,
+	}
+	w.recordDelete(kindService, item)
+	w.enqueue(w.serviceQueue, kindService, item)
 }
 
 func (w *ResourceWatcher) runIngressWorker(ctx context.Context) {
-	for w.processNextIngressWorkItem(ctx) {
+	for w.breaker.Wait(ctx) && w.processNextIngressWorkItem(ctx) {
 	}
 }
 
 func (w *ResourceWatcher) runServiceWorker(ctx context.Context) {
-	for w.processNextServiceWorkItem(ctx) {
+	for w.breaker.Wait(ctx) && w.processNextServiceWorkItem(ctx) {
 	}
 }
 
@@ -598,25 +1025,20 @@ func (w *ResourceWatcher) processNextIngressWorkItem(ctx context.Context) bool {
 	item, ok := obj.(workQueueItem)
 	if !ok {
 		w.ingressQueue.Forget(obj)
-		log.Printf("Error: expected workQueueItem in queue but got %#v", obj)
+		slog.Error("Expected workQueueItem in queue", "item", fmt.Sprintf("%#v", obj))
 		return true
 	}
 
+	ctx, span := w.startSync(ctx, kindIngress, item)
 	err := w.syncIngress(ctx, item)
+	endSpan(span, err)
 	if err == nil {
 		w.ingressQueue.Forget(obj)
+		w.recordSyncSuccess(kindIngress, item)
 		return true
 	}
 
-	if w.ingressQueue.NumRequeues(obj) < 5 {
-		log.Printf("Error syncing ingress %v: %v", item.key, err)
-		w.ingressQueue.AddRateLimited(obj)
-		return true
-	}
-
-	log.Printf("Dropping ingress %q out of the queue: %v", item.key, err)
-	w.ingressQueue.Forget(obj)
-	runtime.HandleError(err)
+	w.requeueOrDrop(ctx, w.ingressQueue, kindIngress, obj, item, err)
 	return true
 }
 
@@ -630,236 +1052,190 @@ func (w *ResourceWatcher) processNextServiceWorkItem(ctx context.Context) bool {
 	item, ok := obj.(workQueueItem)
 	if !ok {
 		w.serviceQueue.Forget(obj)
-		log.Printf("Error: expected workQueueItem in queue but got %#v", obj)
+		slog.Error("Expected workQueueItem in queue", "item", fmt.Sprintf("%#v", obj))
 		return true
 	}
 
+	ctx, span := w.startSync(ctx, kindService, item)
 	err := w.syncService(ctx, item)
+	endSpan(span, err)
 	if err == nil {
 		w.serviceQueue.Forget(obj)
+		w.recordSyncSuccess(kindService, item)
 		return true
 	}
 
-	if w.serviceQueue.NumRequeues(obj) < 5 {
-		log.Printf("Error syncing service %v: %v", item.key, err)
-		w.serviceQueue.AddRateLimited(obj)
-		return true
-	}
-
-	log.Printf("Dropping service %q out of the queue: %v", item.key, err)
-	w.serviceQueue.Forget(obj)
-	runtime.HandleError(err)
+	w.requeueOrDrop(ctx, w.serviceQueue, kindService, obj, item, err)
 	return true
 }
 
 func (w *ResourceWatcher) syncIngress(ctx context.Context, item workQueueItem) error {
 	if item.operation == "delete" {
-		id, err := w.findIngressID(item.name)
-		if err != nil {
-			return fmt.Errorf("error finding ingress ID: %v", err)
-		}
-
-		req, err := http.NewRequest(
-			http.MethodDelete,
-			fmt.Sprintf("%s/api/ingress/%d", w.config.APIEndpoint, id),
-			nil,
-		)
-		if err != nil {
-			return fmt.Errorf("error creating request: %v", err)
-		}
-
-		log.Printf("API DELETE Request - Ingress %s/%s - URL: %s", item.namespace, item.name, req.URL.String())
-
-		resp, err := w.httpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("error making DELETE request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API DELETE Response - Ingress %s/%s - Status: %d, Error: %s",
-				item.namespace, item.name, resp.StatusCode, string(body))
-		}
-
-		log.Printf("API DELETE Response - Ingress %s/%s - Status: %d",
-			item.namespace, item.name, resp.StatusCode)
-		return nil
+		return w.syncChange(ctx, deleteChangeForItem(kindIngress, item))
 	}
 
-	ingress, err := w.clientset.NetworkingV1().Ingresses(item.namespace).Get(ctx, item.name, metav1.GetOptions{})
+	getCtx, span := tracer.Start(ctx, "kubernetes get ingress")
+	ingress, err := w.clientset.NetworkingV1().Ingresses(item.namespace).Get(getCtx, item.name, metav1.GetOptions{})
+	endSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("failed to get ingress: %v", err)
 	}
 
-	payload := w.createIngressPayload(ingress)
-	if payload.Hosts == nil {
-		payload.Hosts = []string{}
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("error marshaling payload: %v", err)
-	}
-
-	id, err := w.findIngressID(item.name)
-	var req *http.Request
-	var actionType string
+	change := changeForIngress(w.createIngressPayload(ingress))
+	change.UID, change.ResourceVersion = string(ingress.UID), ingress.ResourceVersion
+	return w.syncChange(ctx, change)
+}
 
-	if err == nil {
-		req, err = http.NewRequest(
-			http.MethodPut,
-			fmt.Sprintf("%s/api/ingress/%d", w.config.APIEndpoint, id),
-			bytes.NewBuffer(jsonData),
-		)
-		actionType = "UPDATE"
-	} else {
-		req, err = http.NewRequest(
-			http.MethodPost,
-			fmt.Sprintf("%s/api/ingress", w.config.APIEndpoint),
-			bytes.NewBuffer(jsonData),
-		)
-		actionType = "CREATE"
+func (w *ResourceWatcher) syncService(ctx context.Context, item workQueueItem) error {
+	if item.operation == "delete" {
+		return w.syncChange(ctx, deleteChangeForItem(kindService, item))
 	}
 
+	getCtx, span := tracer.Start(ctx, "kubernetes get service")
+	service, err := w.clientset.CoreV1().Services(item.namespace).Get(getCtx, item.name, metav1.GetOptions{})
+	endSpan(span, err)
 	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
+		return fmt.Errorf("failed to get service: %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	log.Printf("API %s Request - Ingress %s/%s - URL: %s", actionType, item.namespace, item.name, req.URL.String())
+	change := changeForService(w.createServicePayload(service))
+	change.UID, change.ResourceVersion = string(service.UID), service.ResourceVersion
+	return w.syncChange(ctx, change)
+}
 
-	resp, err := w.httpClient.Do(req)
+// syncChange sends a single change the way a batch is sent, so sinks that
+// implement ApplyBatch see the object version it was read from
+func (w *ResourceWatcher) syncChange(ctx context.Context, change Change) error {
+	errs, err := applyBatch(ctx, w.sink, change.Kind, []Change{change})
 	if err != nil {
-		return fmt.Errorf("error making HTTP request: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
+	return errs[0]
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API %s Response - Ingress %s/%s - Status: %d, Error: %s",
-			actionType, item.namespace, item.name, resp.StatusCode, string(body))
+func deleteChangeForItem(kind string, item workQueueItem) Change {
+	return Change{
+		Kind:            kind,
+		Operation:       operationDelete,
+		Namespace:       item.namespace,
+		Name:            item.name,
+		UID:             item.uid,
+		ResourceVersion: item.resourceVersion,
 	}
-
-	log.Printf("API %s Response - Ingress %s/%s - Status: %d",
-		actionType, item.namespace, item.name, resp.StatusCode)
-	return nil
 }
 
-func (w *ResourceWatcher) syncService(ctx context.Context, item workQueueItem) error {
-	if item.operation == "delete" {
-		id, err := w.findServiceID(item.name)
-		if err != nil {
-			return fmt.Errorf("error finding service ID: %v", err)
-		}
+// kubernetesConfig loads the kubeconfig at path, so commands like diff can
+// run from a workstation, and the in-cluster config without one
+func kubernetesConfig(path string) (*rest.Config, error) {
+	if path == "" {
+		return rest.InClusterConfig()
+	}
+	return clientcmd.BuildConfigFromFlags("", path)
+}
 
-		req, err := http.NewRequest(
-			http.MethodDelete,
-			fmt.Sprintf("%s/api/service/%d", w.config.APIEndpoint, id),
-			nil,
-		)
-		if err != nil {
-			return fmt.Errorf("error creating request: %v", err)
+func main() {
+	once := flag.Bool("once", false, "sync the cluster once, print what changed and exit instead of watching")
+	dryRun := flag.Bool("dry-run", false, "log the backend writes that would be made instead of making them")
+	dryRunReads := flag.Bool("dry-run-reads", true, "with --dry-run, still read from the backend to tell creates from updates")
+	logLevel := flag.String("log-level", "info", "log level, debug, info, warn or error")
+	logFormat := flag.String("log-format", "json", "log format, json or text")
+	kubeconfig := flag.String("kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig to use outside a cluster, defaults to KUBECONFIG; the in-cluster config is used when unset")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [snapshot|import|diff]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	command := flag.Arg(0)
+	var diffOpts diffOptions
+	switch command {
+	case "":
+	case "import":
+		if err := runImport(flag.Args()[1:]); err != nil {
+			fatal("Import failed", err)
 		}
-
-		log.Printf("API DELETE Request - Service %s/%s - URL: %s", item.namespace, item.name, req.URL.String())
-
-		resp, err := w.httpClient.Do(req)
+		return
+	case "snapshot":
+		*once = true
+	case "diff":
+		var err error
+		diffOpts, err = parseDiffArgs(flag.Args()[1:])
 		if err != nil {
-			return fmt.Errorf("error making DELETE request: %v", err)
+			slog.Error("Diff failed", "error", err)
+			os.Exit(2)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API DELETE Response - Service %s/%s - Status: %d, Error: %s",
-				item.namespace, item.name, resp.StatusCode, string(body))
-		}
-
-		log.Printf("API DELETE Response - Service %s/%s - Status: %d",
-			item.namespace, item.name, resp.StatusCode)
-		return nil
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	service, err := w.clientset.CoreV1().Services(item.namespace).Get(ctx, item.name, metav1.GetOptions{})
+	appConfig, err := LoadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to get service: %v", err)
+		fatal("Failed to load config", err)
 	}
+	appConfig.DryRun = *dryRun
+	appConfig.DryRunReads = *dryRunReads
 
-	payload := w.createServicePayload(service)
-	if payload.Ports == nil {
-		payload.Ports = []int32{}
-	}
-
-	jsonData, err := json.Marshal(payload)
+	shutdownTracing, err := setupTracing(context.Background(), appConfig.TracingEndpoint)
 	if err != nil {
-		return fmt.Errorf("error marshaling payload: %v", err)
+		fatal("Failed to set up tracing", err)
 	}
+	defer shutdownTracing()
 
-	id, err := w.findServiceID(item.name)
-	var req *http.Request
-	var actionType string
-
-	if err == nil {
-		req, err = http.NewRequest(
-			http.MethodPut,
-			fmt.Sprintf("%s/api/service/%d", w.config.APIEndpoint, id),
-			bytes.NewBuffer(jsonData),
-		)
-		actionType = "UPDATE"
-	} else {
-		req, err = http.NewRequest(
-			http.MethodPost,
-			fmt.Sprintf("%s/api/service", w.config.APIEndpoint),
-			bytes.NewBuffer(jsonData),
-		)
-		actionType = "CREATE"
+	if command == "diff" {
+		// the diff only reads from the backend, so the other sinks and the
+		// outbox are left alone
+		if appConfig.APIEndpoint == "" {
+			slog.Error("Diff failed", "error", "API_ENDPOINT environment variable is required")
+			os.Exit(2)
+		}
+		appConfig.Sinks = []string{"rest"}
+		appConfig.OutboxPath = ""
 	}
 
+	k8sConfig, err := kubernetesConfig(*kubeconfig)
 	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
+		fatal("Failed to get kubernetes config", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	log.Printf("API %s Request - Service %s/%s - URL: %s", actionType, item.namespace, item.name, req.URL.String())
 
-	resp, err := w.httpClient.Do(req)
+	watcher, err := NewResourceWatcher(k8sConfig, appConfig)
 	if err != nil {
-		return fmt.Errorf("error making HTTP request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API %s Response - Service %s/%s - Status: %d, Error: %s",
-			actionType, item.namespace, item.name, resp.StatusCode, string(body))
+		fatal("Failed to create watcher", err)
 	}
 
-	log.Printf("API %s Response - Service %s/%s - Status: %d",
-		actionType, item.namespace, item.name, resp.StatusCode)
-	return nil
-}
-
-func main() {
-	appConfig, err := LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
+	// SIGTERM from the kubelet cancels ctx, so the watch loop returns and its
+	// deferred cleanup flushes and closes the sinks and the outbox
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	k8sConfig, err := rest.InClusterConfig()
-	if err != nil {
-		log.Fatalf("Failed to get kubernetes config: %v", err)
+	if command == "diff" {
+		report, err := watcher.Diff(ctx)
+		if err == nil {
+			err = printDriftReport(os.Stdout, report, diffOpts.output)
+		}
+		if err != nil {
+			slog.Error("Diff failed", "error", err)
+			os.Exit(2)
+		}
+		if report.Drift {
+			os.Exit(1)
+		}
+		return
 	}
 
-	watcher, err := NewResourceWatcher(k8sConfig, appConfig)
-	if err != nil {
-		log.Fatalf("Failed to create watcher: %v", err)
+	if *once {
+		if err := watcher.RunOnce(ctx); err != nil {
+			fatal("Sync failed", err)
+		}
+		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	if err := watcher.WatchResources(ctx); err != nil {
-		log.Fatalf("Error watching resources: %v", err)
+		fatal("Error watching resources", err)
 	}
 }
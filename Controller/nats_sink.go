@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsTimeout bounds JetStream acks and flushes, which need a deadline so a
+// lost connection fails the item back to the workqueue instead of blocking
+const natsTimeout = 10 * time.Second
+
+type NATSConfig struct {
+	URL       string
+	CredsFile string
+	JetStream bool
+	Stream    string
+	KVBucket  string
+}
+
+// loadNATSConfig reads the NATS_* settings, only needed when the nats sink is
+// enabled
+func loadNATSConfig() (NATSConfig, error) {
+	config := NATSConfig{
+		URL:       os.Getenv("NATS_URL"),
+		CredsFile: os.Getenv("NATS_CREDS_FILE"),
+		JetStream: os.Getenv("NATS_JETSTREAM") == "true",
+		Stream:    os.Getenv("NATS_STREAM"),
+		KVBucket:  os.Getenv("NATS_KV_BUCKET"),
+	}
+
+	if config.URL == "" {
+		return config, fmt.Errorf("NATS_URL environment variable is required for the nats sink")
+	}
+	logSetting("NATS_URL", config.URL)
+
+	if config.JetStream {
+		logSetting("NATS_JETSTREAM", true)
+	}
+	if config.Stream != "" {
+		logSetting("NATS_STREAM", config.Stream)
+	}
+	if config.KVBucket != "" {
+		logSetting("NATS_KV_BUCKET", config.KVBucket)
+	}
+	if (config.Stream != "" || config.KVBucket != "") && !config.JetStream {
+		return config, fmt.Errorf("NATS_STREAM and NATS_KV_BUCKET require NATS_JETSTREAM=true")
+	}
+
+	return config, nil
+}
+
+// NATSSink publishes a ChangeEvent per change on tracker.<cluster>.<kind>.
+// <namespace>.<name> (cluster scoped objects have no namespace token, cluster
+// info goes to tracker.<cluster>.cluster). With JetStream every publish waits
+// for the stream's ack, and the current state of every object can also be
+// mirrored into a KV bucket under the same key minus the tracker prefix.
+type NATSSink struct {
+	conn        *nats.Conn
+	js          nats.JetStreamContext
+	clusterName string
+	events      *eventTracker
+
+	// the stream and KV bucket are set up by the first publish rather than at
+	// startup, which mustn't wait for a server that may be unreachable, and
+	// again after a reconnect in case the server lost them
+	stream   string
+	kvBucket string
+	setupMu  sync.Mutex
+	ready    bool
+	kv       nats.KeyValue
+}
+
+func NewNATSSink(config NATSConfig, clusterName string) (*NATSSink, error) {
+	sink := &NATSSink{
+		clusterName: clusterName,
+		events:      newEventTracker(),
+		stream:      config.Stream,
+		kvBucket:    config.KVBucket,
+	}
+
+	opts := []nats.Option{
+		nats.Name("k8s-tracker " + clusterName),
+		// edge clusters lose connectivity, keep trying in the background
+		// rather than failing startup or giving up
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("Disconnected from NATS", "error", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			slog.Info("Reconnected to NATS", "url", conn.ConnectedUrl())
+			sink.setupMu.Lock()
+			sink.ready = false
+			sink.setupMu.Unlock()
+		}),
+	}
+	if config.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(config.CredsFile))
+	}
+
+	conn, err := nats.Connect(config.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+	sink.conn = conn
+	if !config.JetStream {
+		return sink, nil
+	}
+
+	// creating the context doesn't talk to the server
+	sink.js, err = conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %v", err)
+	}
+	return sink, nil
+}
+
+// setupJetStream makes sure the stream and KV bucket exist before a publish
+// and returns the bucket to mirror state into, if any. A failure fails the
+// publish, so the workqueue retries the setup with its backoff.
+func (s *NATSSink) setupJetStream() (nats.KeyValue, error) {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+	if s.ready {
+		return s.kv, nil
+	}
+
+	if s.stream != "" {
+		if err := s.ensureStream(s.stream); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.kvBucket != "" {
+		kv, err := s.js.KeyValue(s.kvBucket)
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			slog.Info("Creating NATS KV bucket", "bucket", s.kvBucket)
+			kv, err = s.js.CreateKeyValue(&nats.KeyValueConfig{Bucket: s.kvBucket})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open NATS KV bucket %s: %v", s.kvBucket, err)
+		}
+		s.kv = kv
+	}
+
+	s.ready = true
+	return s.kv, nil
+}
+
+// ensureStream creates the stream for this cluster's subjects unless it
+// already exists; an existing stream is left as configured
+func (s *NATSSink) ensureStream(name string) error {
+	_, err := s.js.StreamInfo(name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("failed to look up NATS stream %s: %v", name, err)
+	}
+
+	slog.Info("Creating NATS stream", "stream", name)
+	_, err = s.js.AddStream(&nats.StreamConfig{
+		Name:     name,
+		Subjects: []string{"tracker." + natsToken(s.clusterName) + ".>"},
+		// only the latest event per object is needed to rebuild state
+		MaxMsgsPerSubject: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create NATS stream %s: %v", name, err)
+	}
+	return nil
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+// natsToken makes s usable as a single subject token. Kubernetes names can
+// contain dots but never underscores, so the replacement can't collide.
+func natsToken(s string) string {
+	return strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_").Replace(s)
+}
+
+// stateKey is where a change lives below the cluster, used both for subjects
+// and KV keys
+func (s *NATSSink) stateKey(change Change) string {
+	parts := []string{natsToken(s.clusterName), change.Kind}
+	if change.Namespace != "" {
+		parts = append(parts, natsToken(change.Namespace))
+	}
+	if change.Kind != kindCluster {
+		parts = append(parts, natsToken(change.Name))
+	}
+	return strings.Join(parts, ".")
+}
+
+func (s *NATSSink) publish(ctx context.Context, changes []Change) []error {
+	errs := make([]error, len(changes))
+
+	var kv nats.KeyValue
+	if s.js != nil {
+		var err error
+		kv, err = s.setupJetStream()
+		if err != nil {
+			slog.Warn("NATS JetStream setup failed", "error", err)
+			for i := range errs {
+				errs[i] = err
+			}
+			return errs
+		}
+	}
+
+	for i, change := range changes {
+		event := s.events.newEvent(s.clusterName, change)
+		data, err := json.Marshal(event)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal %s event: %v", change.Kind, err)
+			continue
+		}
+
+		key := s.stateKey(change)
+		subject := "tracker." + key
+		if s.js != nil {
+			pubCtx, cancel := context.WithTimeout(ctx, natsTimeout)
+			_, err = s.js.Publish(subject, data, nats.Context(pubCtx))
+			cancel()
+		} else {
+			err = s.conn.Publish(subject, data)
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to publish to %s: %v", subject, err)
+			continue
+		}
+
+		if kv != nil {
+			if err := mirror(kv, key, change); err != nil {
+				errs[i] = err
+			}
+		}
+	}
+
+	// core NATS publishes are buffered, only a flush tells us the server
+	// got them
+	if s.js == nil {
+		flushCtx, cancel := context.WithTimeout(ctx, natsTimeout)
+		err := s.conn.FlushWithContext(flushCtx)
+		cancel()
+		if err != nil {
+			for i := range errs {
+				if errs[i] == nil {
+					errs[i] = fmt.Errorf("failed to flush to NATS: %v", err)
+				}
+			}
+		}
+	}
+
+	for i, change := range changes {
+		if errs[i] == nil {
+			s.events.sent(change)
+		}
+	}
+	return errs
+}
+
+func mirror(kv nats.KeyValue, key string, change Change) error {
+	if change.Operation == operationDelete {
+		if err := kv.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete %s from NATS KV: %v", key, err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(change.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s state: %v", change.Kind, err)
+	}
+	if _, err := kv.Put(key, data); err != nil {
+		return fmt.Errorf("failed to put %s in NATS KV: %v", key, err)
+	}
+	return nil
+}
+
+func (s *NATSSink) publishOne(ctx context.Context, change Change) error {
+	return s.publish(ctx, []Change{change})[0]
+}
+
+func (s *NATSSink) ApplyBatch(ctx context.Context, kind string, changes []Change) ([]error, error) {
+	return s.publish(ctx, changes), nil
+}
+
+func (s *NATSSink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	return s.publishOne(ctx, Change{Kind: kindCluster, Operation: operationUpsert, Name: s.clusterName, Payload: info})
+}
+
+func (s *NATSSink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	return s.publishOne(ctx, changeForIngress(payload))
+}
+
+func (s *NATSSink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	return s.publishOne(ctx, Change{Kind: kindIngress, Operation: operationDelete, Namespace: namespace, Name: name})
+}
+
+func (s *NATSSink) ListIngresses(ctx context.Context) ([]IngressPayload, error) {
+	return nil, fmt.Errorf("the nats sink can't list ingresses")
+}
+
+func (s *NATSSink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	return s.publishOne(ctx, changeForService(payload))
+}
+
+func (s *NATSSink) DeleteService(ctx context.Context, namespace, name string) error {
+	return s.publishOne(ctx, Change{Kind: kindService, Operation: operationDelete, Namespace: namespace, Name: name})
+}
+
+func (s *NATSSink) ListServices(ctx context.Context) ([]ServicePayload, error) {
+	return nil, fmt.Errorf("the nats sink can't list services")
+}
+
+func (s *NATSSink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	return s.publishOne(ctx, changeForNode(payload))
+}
+
+func (s *NATSSink) DeleteNode(ctx context.Context, name string) error {
+	return s.publishOne(ctx, Change{Kind: kindNode, Operation: operationDelete, Name: name})
+}
+
+func (s *NATSSink) ListNodes(ctx context.Context) ([]NodePayload, error) {
+	return nil, fmt.Errorf("the nats sink can't list nodes")
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+// runNATSServer starts an embedded server with JetStream on port, or a free
+// port when it is -1
+func runNATSServer(t *testing.T, port int) *natsserver.Server {
+	t.Helper()
+	opts := natstest.DefaultTestOptions
+	opts.Port = port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := natstest.RunServer(&opts)
+	t.Cleanup(server.Shutdown)
+	return server
+}
+
+// newTestNATSSink connects a sink to server as the cluster "prod.eu"
+func newTestNATSSink(t *testing.T, server *natsserver.Server, config NATSConfig) *NATSSink {
+	t.Helper()
+	config.URL = server.ClientURL()
+	sink, err := NewNATSSink(config, "prod.eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sink.conn.Close)
+	return sink
+}
+
+func TestNewNATSSinkUnreachable(t *testing.T) {
+	start := time.Now()
+	sink, err := NewNATSSink(NATSConfig{
+		URL:       "nats://127.0.0.1:1",
+		JetStream: true,
+		Stream:    "tracker",
+		KVBucket:  "tracker",
+	}, "test")
+	if err != nil {
+		t.Fatalf("NewNATSSink() error = %v, want the JetStream setup left to the first publish", err)
+	}
+	defer sink.conn.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("NewNATSSink() took %v with the server down", elapsed)
+	}
+	if sink.ready {
+		t.Error("JetStream marked ready before reaching the server")
+	}
+}
+
+func TestNATSSinkSubjects(t *testing.T) {
+	server := runNATSServer(t, -1)
+	sink := newTestNATSSink(t, server, NATSConfig{})
+
+	conn, err := nats.Connect(server.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	sub, err := conn.SubscribeSync("tracker.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		send        func() error
+		wantSubject string
+		wantType    string
+	}{
+		{
+			name:        "cluster info",
+			send:        func() error { return sink.SendClusterInfo(ctx, &ClusterInfo{ClusterName: "prod.eu"}) },
+			wantSubject: "tracker.prod_eu.cluster",
+			wantType:    eventCreated,
+		},
+		{
+			name: "ingress in a dotted namespace",
+			send: func() error {
+				return sink.UpsertIngress(ctx, IngressPayload{Namespace: "team.a", IngressName: "web", Hosts: []string{}, Ports: []int32{}})
+			},
+			wantSubject: "tracker.prod_eu.ingress.team_a.web",
+			wantType:    eventCreated,
+		},
+		{
+			name:        "service",
+			send:        func() error { return sink.UpsertService(ctx, ServicePayload{Namespace: "default", ServiceName: "db"}) },
+			wantSubject: "tracker.prod_eu.service.default.db",
+			wantType:    eventCreated,
+		},
+		{
+			name:        "service update",
+			send:        func() error { return sink.UpsertService(ctx, ServicePayload{Namespace: "default", ServiceName: "db"}) },
+			wantSubject: "tracker.prod_eu.service.default.db",
+			wantType:    eventUpdated,
+		},
+		{
+			name:        "cluster scoped node",
+			send:        func() error { return sink.UpsertNode(ctx, NodePayload{NodeName: "ip-10-0-1-5.ec2.internal"}) },
+			wantSubject: "tracker.prod_eu.node.ip-10-0-1-5_ec2_internal",
+			wantType:    eventCreated,
+		},
+		{
+			name:        "service delete",
+			send:        func() error { return sink.DeleteService(ctx, "default", "db") },
+			wantSubject: "tracker.prod_eu.service.default.db",
+			wantType:    eventDeleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.send(); err != nil {
+				t.Fatal(err)
+			}
+			msg, err := sub.NextMsg(5 * time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Subject != tt.wantSubject {
+				t.Errorf("subject = %s, want %s", msg.Subject, tt.wantSubject)
+			}
+			var event ChangeEvent
+			if err := json.Unmarshal(msg.Data, &event); err != nil {
+				t.Fatal(err)
+			}
+			if event.Type != tt.wantType || event.Cluster != "prod.eu" {
+				t.Errorf("event = %s in %s, want %s in prod.eu", event.Type, event.Cluster, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestNATSSinkKVMirror(t *testing.T) {
+	server := runNATSServer(t, -1)
+	sink := newTestNATSSink(t, server, NATSConfig{JetStream: true, Stream: "tracker", KVBucket: "tracker"})
+	ctx := context.Background()
+
+	payload := ServicePayload{ClusterName: "prod.eu", Namespace: "default", ServiceName: "web", ServiceType: "ClusterIP", Ports: []int32{80}}
+	if err := sink.UpsertService(ctx, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err := sink.js.KeyValue("tracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := kv.Get("prod_eu.service.default.web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(payload)
+	if string(entry.Value()) != string(want) {
+		t.Errorf("KV value = %s, want %s", entry.Value(), want)
+	}
+
+	msg, err := sink.js.GetLastMsg("tracker", "tracker.prod_eu.service.default.web")
+	if err != nil {
+		t.Fatalf("event not in the stream: %v", err)
+	}
+	var event ChangeEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != eventCreated {
+		t.Errorf("stream event type = %s, want %s", event.Type, eventCreated)
+	}
+
+	if err := sink.DeleteService(ctx, "default", "web"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("prod_eu.service.default.web"); !errors.Is(err, nats.ErrKeyNotFound) {
+		t.Errorf("KV get after delete: err = %v, want %v", err, nats.ErrKeyNotFound)
+	}
+}
+
+func TestNATSSinkSetupAfterReconnect(t *testing.T) {
+	server := runNATSServer(t, -1)
+	port := server.Addr().(*net.TCPAddr).Port
+	sink := newTestNATSSink(t, server, NATSConfig{JetStream: true, Stream: "tracker", KVBucket: "tracker"})
+	ctx := context.Background()
+
+	if err := sink.UpsertNode(ctx, NodePayload{NodeName: "node-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a server restarted without its store has lost the stream and bucket
+	server.Shutdown()
+	server.WaitForShutdown()
+	runNATSServer(t, port)
+	eventually(t, "reconnect", func() bool {
+		sink.setupMu.Lock()
+		defer sink.setupMu.Unlock()
+		return sink.conn.IsConnected() && !sink.ready
+	})
+
+	if err := sink.UpsertNode(ctx, NodePayload{NodeName: "node-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.js.StreamInfo("tracker"); err != nil {
+		t.Errorf("stream after the reconnect: %v", err)
+	}
+	kv, err := sink.js.KeyValue("tracker")
+	if err != nil {
+		t.Fatalf("KV bucket after the reconnect: %v", err)
+	}
+	if _, err := kv.Get("prod_eu.node.node-2"); err != nil {
+		t.Errorf("KV get after the reconnect: %v", err)
+	}
+}
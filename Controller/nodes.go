@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+	legacyNodeRoleLabel = "kubernetes.io/role"
+)
+
+type NodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Effect string `json:"effect"`
+}
+
+type NodeResources struct {
+	CPUMillicores int64 `json:"cpuMillicores"`
+	MemoryBytes   int64 `json:"memoryBytes"`
+	Pods          int64 `json:"pods"`
+}
+
+func (r NodeResources) add(other NodeResources) NodeResources {
+	return NodeResources{
+		CPUMillicores: r.CPUMillicores + other.CPUMillicores,
+		MemoryBytes:   r.MemoryBytes + other.MemoryBytes,
+		Pods:          r.Pods + other.Pods,
+	}
+}
+
+type NodePayload struct {
+	ClusterName             string        `json:"clusterName"`
+	NodeName                string        `json:"nodeName"`
+	Roles                   []string      `json:"roles"`
+	OSImage                 string        `json:"osImage"`
+	OperatingSystem         string        `json:"operatingSystem"`
+	Architecture            string        `json:"architecture"`
+	ContainerRuntimeVersion string        `json:"containerRuntimeVersion"`
+	KubeletVersion          string        `json:"kubeletVersion"`
+	KernelVersion           string        `json:"kernelVersion"`
+	ProviderID              string        `json:"providerId"`
+	InstanceType            string        `json:"instanceType"`
+	Zone                    string        `json:"zone"`
+	Region                  string        `json:"region"`
+	Capacity                NodeResources `json:"capacity"`
+	Allocatable             NodeResources `json:"allocatable"`
+	Taints                  []NodeTaint   `json:"taints"`
+	Ready                   bool          `json:"ready"`
+}
+
+func nodeResources(list corev1.ResourceList) NodeResources {
+	resources := NodeResources{}
+	if cpu, ok := list[corev1.ResourceCPU]; ok {
+		resources.CPUMillicores = cpu.MilliValue()
+	}
+	if memory, ok := list[corev1.ResourceMemory]; ok {
+		resources.MemoryBytes = memory.Value()
+	}
+	if pods, ok := list[corev1.ResourcePods]; ok {
+		resources.Pods = pods.Value()
+	}
+	return resources
+}
+
+func nodeRoles(node *corev1.Node) []string {
+	roles := []string{}
+	for label, value := range node.Labels {
+		switch {
+		case strings.HasPrefix(label, nodeRoleLabelPrefix):
+			if role := strings.TrimPrefix(label, nodeRoleLabelPrefix); role != "" {
+				roles = append(roles, role)
+			}
+		case label == legacyNodeRoleLabel && value != "":
+			roles = append(roles, value)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func (w *ResourceWatcher) createNodePayload(node *corev1.Node) NodePayload {
+	if node == nil {
+		return NodePayload{
+			ClusterName: w.clusterName,
+			Roles:       []string{},
+			Taints:      []NodeTaint{},
+		}
+	}
+
+	taints := []NodeTaint{}
+	for _, taint := range node.Spec.Taints {
+		taints = append(taints, NodeTaint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: string(taint.Effect),
+		})
+	}
+
+	info := node.Status.NodeInfo
+	return NodePayload{
+		ClusterName:             w.clusterName,
+		NodeName:                node.Name,
+		Roles:                   nodeRoles(node),
+		OSImage:                 info.OSImage,
+		OperatingSystem:         info.OperatingSystem,
+		Architecture:            info.Architecture,
+		ContainerRuntimeVersion: info.ContainerRuntimeVersion,
+		KubeletVersion:          info.KubeletVersion,
+		KernelVersion:           info.KernelVersion,
+		ProviderID:              node.Spec.ProviderID,
+		InstanceType:            node.Labels[corev1.LabelInstanceTypeStable],
+		Zone:                    node.Labels[corev1.LabelTopologyZone],
+		Region:                  node.Labels[corev1.LabelTopologyRegion],
+		Capacity:                nodeResources(node.Status.Capacity),
+		Allocatable:             nodeResources(node.Status.Allocatable),
+		Taints:                  taints,
+		Ready:                   nodeReady(node),
+	}
+}
+
+func (w *ResourceWatcher) handleNodeChange(obj interface{}) {
+	if obj == nil {
+		slog.Error("Received nil object in handleNodeChange")
+		return
+	}
+
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		slog.Error("Unexpected type for node object")
+		return
+	}
+
+	w.enqueue(w.nodeQueue, kindNode, workQueueItem{
+		key:       node.Name,
+		name:      node.Name,
+		operation: "update",
+	})
+}
+
+func (w *ResourceWatcher) handleNodeAdd(obj interface{}, isInInitialList bool) {
+	// the initial list is already reflected in the startup cluster info
+	if !isInInitialList {
+		w.requestClusterInfoRefresh("node added")
+	}
+	w.handleNodeChange(obj)
+}
+
+// handleNodeUpdate skips the status heartbeats nodes emit constantly; only
+// changes to the inventoried fields (and the daily resync) reach the backend
+func (w *ResourceWatcher) handleNodeUpdate(oldObj, newObj interface{}) {
+	oldNode, oldOk := oldObj.(*corev1.Node)
+	newNode, newOk := newObj.(*corev1.Node)
+	if oldOk && newOk && nodeVersionsChanged(oldNode, newNode) {
+		w.requestClusterInfoRefresh("node versions changed")
+	}
+
+	if oldOk && newOk && oldNode.ResourceVersion != newNode.ResourceVersion &&
+		reflect.DeepEqual(w.createNodePayload(oldNode), w.createNodePayload(newNode)) {
+		return
+	}
+
+	w.handleNodeChange(newObj)
+}
+
+func (w *ResourceWatcher) handleNodeDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		slog.Error("Unexpected type for node object")
+		return
+	}
+
+	w.requestClusterInfoRefresh("node deleted")
+	item := workQueueItem{
+		key:             node.Name,
+		name:            node.Name,
+		operation:       "delete",
+		uid:             string(node.UID),
+		resourceVersion: node.ResourceVersion,
+	}
+	w.recordDelete(kindNode, item)
+	w.enqueue(w.nodeQueue, kindNode, item)
+}
+
+func (w *ResourceWatcher) runNodeWorker(ctx context.Context) {
+	for w.breaker.Wait(ctx) && w.processNextNodeWorkItem(ctx) {
+	}
+}
+
+func (w *ResourceWatcher) processNextNodeWorkItem(ctx context.Context) bool {
+	obj, shutdown := w.nodeQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.nodeQueue.Done(obj)
+
+	item, ok := obj.(workQueueItem)
+	if !ok {
+		w.nodeQueue.Forget(obj)
+		slog.Error("Expected workQueueItem in queue", "item", fmt.Sprintf("%#v", obj))
+		return true
+	}
+
+	ctx, span := w.startSync(ctx, kindNode, item)
+	err := w.syncNode(ctx, item)
+	endSpan(span, err)
+	if err == nil {
+		w.nodeQueue.Forget(obj)
+		w.recordSyncSuccess(kindNode, item)
+		return true
+	}
+
+	w.requeueOrDrop(ctx, w.nodeQueue, kindNode, obj, item, err)
+	return true
+}
+
+func (w *ResourceWatcher) syncNode(ctx context.Context, item workQueueItem) error {
+	if item.operation == "delete" {
+		return w.syncChange(ctx, deleteChangeForItem(kindNode, item))
+	}
+
+	getCtx, span := tracer.Start(ctx, "kubernetes get node")
+	node, err := w.clientset.CoreV1().Nodes().Get(getCtx, item.name, metav1.GetOptions{})
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to get node: %v", err)
+	}
+
+	change := changeForNode(w.createNodePayload(node))
+	change.UID, change.ResourceVersion = string(node.UID), node.ResourceVersion
+	return w.syncChange(ctx, change)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// collectInventory lists everything the watcher tracks into a complete
+// Inventory. It runs informers without handlers until their caches sync, so
+// payloads are built exactly as the watch loop builds them.
+func (w *ResourceWatcher) collectInventory(ctx context.Context) (*Inventory, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	clusterInfo, err := w.collectClusterInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect cluster info: %v", err)
+	}
+
+	listerWatchers := make(map[string]cache.ListerWatcher)
+	for _, resource := range []string{"endpointslices", "ingresses", "services", "nodes"} {
+		lw, err := w.listerWatcher(resource)
+		if err != nil {
+			return nil, err
+		}
+		listerWatchers[resource] = lw
+	}
+
+	endpointSliceIndexer, endpointSliceController := cache.NewIndexerInformer(
+		listerWatchers["endpointslices"],
+		&discoveryv1.EndpointSlice{},
+		0,
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{endpointSliceServiceIndex: endpointSliceServiceKey},
+	)
+	w.endpointSliceIndexer = endpointSliceIndexer
+
+	ingressStore, ingressController := listOnlyInformer(
+		listerWatchers["ingresses"],
+		&networkingv1.Ingress{},
+	)
+	serviceStore, serviceController := listOnlyInformer(
+		listerWatchers["services"],
+		&corev1.Service{},
+	)
+	nodeStore, nodeController := listOnlyInformer(
+		listerWatchers["nodes"],
+		&corev1.Node{},
+	)
+
+	for _, controller := range []cache.Controller{endpointSliceController, ingressController, serviceController, nodeController} {
+		go controller.Run(ctx.Done())
+	}
+	if !cache.WaitForCacheSync(ctx.Done(),
+		endpointSliceController.HasSynced,
+		ingressController.HasSynced,
+		serviceController.HasSynced,
+		nodeController.HasSynced,
+	) {
+		return nil, fmt.Errorf("failed to sync caches")
+	}
+
+	inventory := w.inventoryFromStores(ingressStore, serviceStore, nodeStore)
+	inventory.ClusterInfo = clusterInfo
+	return inventory, nil
+}
+
+// inventoryFromStores builds a complete Inventory from synced informer caches
+func (w *ResourceWatcher) inventoryFromStores(ingressStore, serviceStore, nodeStore cache.Store) *Inventory {
+	inventory := NewInventory()
+	inventory.Cluster = w.clusterName
+	inventory.Complete = true
+
+	for _, obj := range ingressStore.List() {
+		payload := w.createIngressPayload(obj.(*networkingv1.Ingress))
+		inventory.Ingresses[payload.Namespace+"/"+payload.IngressName] = &payload
+	}
+	for _, obj := range serviceStore.List() {
+		payload := w.createServicePayload(obj.(*corev1.Service))
+		inventory.Services[payload.Namespace+"/"+payload.ServiceName] = &payload
+	}
+	for _, obj := range nodeStore.List() {
+		payload := w.createNodePayload(obj.(*corev1.Node))
+		inventory.Nodes[payload.NodeName] = &payload
+	}
+	return inventory
+}
+
+func listOnlyInformer(lw cache.ListerWatcher, objType runtime.Object) (cache.Store, cache.Controller) {
+	return cache.NewInformer(lw, objType, 0, cache.ResourceEventHandlerFuncs{})
+}
+
+// listerWatcher returns what an informer for one of the tracked resources
+// lists and watches, across all namespaces
+func (w *ResourceWatcher) listerWatcher(resource string) (cache.ListerWatcher, error) {
+	if w.listWatch != nil {
+		return w.listWatch(resource), nil
+	}
+
+	var list func(context.Context, metav1.ListOptions) (runtime.Object, error)
+	var watchFunc func(context.Context, metav1.ListOptions) (watch.Interface, error)
+	switch resource {
+	case "ingresses":
+		ingresses := w.clientset.NetworkingV1().Ingresses(corev1.NamespaceAll)
+		list = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return ingresses.List(ctx, options)
+		}
+		watchFunc = ingresses.Watch
+	case "endpointslices":
+		slices := w.clientset.DiscoveryV1().EndpointSlices(corev1.NamespaceAll)
+		list = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return slices.List(ctx, options)
+		}
+		watchFunc = slices.Watch
+	case "services":
+		services := w.clientset.CoreV1().Services(corev1.NamespaceAll)
+		list = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return services.List(ctx, options)
+		}
+		watchFunc = services.Watch
+	case "nodes":
+		nodes := w.clientset.CoreV1().Nodes()
+		list = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return nodes.List(ctx, options)
+		}
+		watchFunc = nodes.Watch
+	default:
+		return nil, fmt.Errorf("no informer for %s", resource)
+	}
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return list(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watchFunc(context.TODO(), options)
+		},
+	}, nil
+}
+
+// RunOnce does a single full reconciliation instead of watching, for running
+// as a CronJob in clusters that change rarely. Objects are compared with what
+// the sink lists, so only differences are written and objects deleted since
+// the last run are removed; with several sinks the first one is compared.
+// It prints what changed and fails if anything couldn't be written.
+func (w *ResourceWatcher) RunOnce(ctx context.Context) error {
+	if w.outbox != nil {
+		defer w.outbox.Close()
+	}
+	defer closeKafkaSinks(w.sink)
+
+	inventory, err := w.collectInventory(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Collected inventory", "ingresses", len(inventory.Ingresses), "services", len(inventory.Services), "nodes", len(inventory.Nodes))
+
+	if err := w.sink.SendClusterInfo(ctx, inventory.ClusterInfo); err != nil {
+		return fmt.Errorf("failed to send cluster info: %v", err)
+	}
+
+	plan, err := planChanges(ctx, w.sink, inventory)
+	if err != nil {
+		return fmt.Errorf("failed to read current inventory from sink: %v", err)
+	}
+
+	failed := applyPlan(ctx, w.sink, plan)
+	printResult(os.Stdout, plan, failed)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d changes failed", len(failed), len(plan))
+	}
+
+	// only the first sink was compared, file sinks behind it still need
+	// their deletes; each run ends with a snapshot of what it wrote
+	for _, fileSink := range fileSinks(w.sink) {
+		if err := fileSink.recordDeletes(ctx, inventory); err != nil {
+			return fmt.Errorf("failed to record deletes in the file sink: %v", err)
+		}
+		if err := fileSink.WriteSnapshot(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printResult writes the changes that were applied, one per line, followed
+// by a summary
+func printResult(out io.Writer, plan []PlannedChange, failed map[int]error) {
+	for i, planned := range plan {
+		if err, ok := failed[i]; ok {
+			fmt.Fprintf(out, "%s %s %s failed: %v\n", planned.Action, planned.Kind, planned.Key(), err)
+		} else {
+			fmt.Fprintf(out, "%s %s %s\n", planned.Action, planned.Kind, planned.Key())
+		}
+	}
+	fmt.Fprintln(out, planSummary(plan, failed))
+}
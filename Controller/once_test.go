@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		kinds     []string
+		wantNodes []string
+	}{
+		{name: "current backend", kinds: []string{"ingress", "service", "node"}, wantNodes: []string{"node-1"}},
+		{name: "backend without nodes", kinds: []string{"ingress", "service"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, server := newFakeBackend(t, tt.kinds...)
+			backend.add("service", ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "stale"})
+
+			sink := NewRESTSink(server.URL, "test", http.DefaultClient)
+			w, _, _ := newTestWatcher(t, sink, testConfig(0),
+				&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			)
+
+			if err := w.RunOnce(context.Background()); err != nil {
+				t.Fatalf("RunOnce() error = %v", err)
+			}
+
+			if got, want := backend.names("ingress", "ingressName"), []string{"web"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("backend ingresses = %v, want %v", got, want)
+			}
+			if got, want := backend.names("service", "serviceName"), []string{"web"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("backend services = %v, want %v", got, want)
+			}
+			if got := backend.names("node", "nodeName"); !reflect.DeepEqual(got, tt.wantNodes) {
+				t.Errorf("backend nodes = %v, want %v", got, tt.wantNodes)
+			}
+			if backend.cluster == nil {
+				t.Error("cluster info wasn't sent")
+			}
+		})
+	}
+}
+
+func TestListerWatcherUnknownResource(t *testing.T) {
+	w, _, _ := newTestWatcher(t, nil, testConfig(0))
+
+	for _, resource := range []string{"ingresses", "endpointslices", "services", "nodes"} {
+		if _, err := w.listerWatcher(resource); err != nil {
+			t.Errorf("listerWatcher(%q) error = %v", resource, err)
+		}
+	}
+	if lw, err := w.listerWatcher("pods"); err == nil {
+		t.Errorf("listerWatcher(\"pods\") = %v, want an error", lw)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// outboxEntry is how a pending Change is stored on disk. Seq changes every
+// time the entry is overwritten so a send can tell whether what it delivered
+// is still the latest change for the object.
+type outboxEntry struct {
+	Seq       uint64          `json:"seq"`
+	Operation string          `json:"operation"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	// UID and ResourceVersion are those of the change, see Change
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// Outbox is a durable record of changes that have not reached the sink yet,
+// kept in a bbolt file with one bucket per kind keyed by object. Only the
+// latest change per object is kept, so an object updated many times while the
+// backend is down is sent once.
+type Outbox struct {
+	db *bolt.DB
+}
+
+func OpenOutbox(path string) (*Outbox, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, kind := range []string{kindIngress, kindService, kindNode} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(kind)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise outbox %s: %v", path, err)
+	}
+
+	return &Outbox{db: db}, nil
+}
+
+func (o *Outbox) Close() error {
+	return o.db.Close()
+}
+
+// Put records change as the pending change for its object, replacing any
+// earlier one, and returns the sequence number to pass to RemoveBatch
+func (o *Outbox) Put(change Change) (uint64, error) {
+	seqs, err := o.PutBatch([]Change{change})
+	if err != nil {
+		return 0, err
+	}
+	return seqs[0], nil
+}
+
+// PutBatch records several changes in one transaction, so a batch costs a
+// single fsync rather than one per change. The sequence numbers returned
+// line up with changes.
+func (o *Outbox) PutBatch(changes []Change) ([]uint64, error) {
+	entries := make([]outboxEntry, len(changes))
+	for i, change := range changes {
+		entries[i] = outboxEntry{
+			Operation:       change.Operation,
+			Namespace:       change.Namespace,
+			Name:            change.Name,
+			UID:             change.UID,
+			ResourceVersion: change.ResourceVersion,
+		}
+		if change.Payload != nil {
+			payload, err := json.Marshal(change.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s payload: %v", change.Kind, err)
+			}
+			entries[i].Payload = payload
+		}
+	}
+
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		for i, change := range changes {
+			bucket := tx.Bucket([]byte(change.Kind))
+			if bucket == nil {
+				return fmt.Errorf("unknown kind %q", change.Kind)
+			}
+
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			entries[i].Seq = seq
+
+			data, err := json.Marshal(entries[i])
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(change.Key()), data); err != nil {
+				return fmt.Errorf("%s %s: %v", change.Kind, change.Key(), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %d changes in outbox: %v", len(changes), err)
+	}
+
+	seqs := make([]uint64, len(entries))
+	for i, entry := range entries {
+		seqs[i] = entry.Seq
+	}
+	return seqs, nil
+}
+
+// RemoveBatch drops the pending changes of objects that have been delivered,
+// or given up on, in one transaction. seqs line up with changes; an entry
+// replaced since its seq was recorded is left alone, the newer change still
+// has to be sent.
+func (o *Outbox) RemoveBatch(changes []Change, seqs []uint64) error {
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		for i, change := range changes {
+			bucket := tx.Bucket([]byte(change.Kind))
+			if bucket == nil {
+				return fmt.Errorf("unknown kind %q", change.Kind)
+			}
+
+			data := bucket.Get([]byte(change.Key()))
+			if data == nil {
+				continue
+			}
+
+			var entry outboxEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return fmt.Errorf("%s %s: %v", change.Kind, change.Key(), err)
+			}
+			if entry.Seq != seqs[i] {
+				continue
+			}
+			if err := bucket.Delete([]byte(change.Key())); err != nil {
+				return fmt.Errorf("%s %s: %v", change.Kind, change.Key(), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove %d changes from outbox: %v", len(changes), err)
+	}
+	return nil
+}
+
+// Pending returns every change of a kind still waiting to be delivered
+func (o *Outbox) Pending(kind string) ([]Change, error) {
+	changes := []Change{}
+	err := o.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(kind))
+		if bucket == nil {
+			return fmt.Errorf("unknown kind %q", kind)
+		}
+
+		return bucket.ForEach(func(k, data []byte) error {
+			change, _, err := decodeOutboxEntry(kind, data)
+			if err != nil {
+				return fmt.Errorf("entry %s: %v", k, err)
+			}
+			changes = append(changes, change)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending %s changes from outbox: %v", kind, err)
+	}
+	return changes, nil
+}
+
+func decodeOutboxEntry(kind string, data []byte) (Change, uint64, error) {
+	var entry outboxEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Change{}, 0, err
+	}
+
+	change := Change{
+		Kind:            kind,
+		Operation:       entry.Operation,
+		Namespace:       entry.Namespace,
+		Name:            entry.Name,
+		UID:             entry.UID,
+		ResourceVersion: entry.ResourceVersion,
+	}
+	if entry.Operation == operationDelete {
+		return change, entry.Seq, nil
+	}
+
+	var err error
+	switch kind {
+	case kindIngress:
+		var payload IngressPayload
+		err = json.Unmarshal(entry.Payload, &payload)
+		change.Payload = payload
+	case kindService:
+		var payload ServicePayload
+		err = json.Unmarshal(entry.Payload, &payload)
+		change.Payload = payload
+	case kindNode:
+		var payload NodePayload
+		err = json.Unmarshal(entry.Payload, &payload)
+		change.Payload = payload
+	default:
+		err = fmt.Errorf("unknown kind %q", kind)
+	}
+	return change, entry.Seq, err
+}
+
+// recordDelete writes a delete to the outbox as soon as the informer reports
+// it. Unlike updates, a delete can't be recovered from the relist after a
+// restart, so it must not only live in the in-memory queue.
+func (w *ResourceWatcher) recordDelete(kind string, item workQueueItem) {
+	if w.outbox == nil {
+		return
+	}
+
+	change := Change{
+		Kind:            kind,
+		Operation:       operationDelete,
+		Namespace:       item.namespace,
+		Name:            item.name,
+		UID:             item.uid,
+		ResourceVersion: item.resourceVersion,
+	}
+	if _, err := w.outbox.Put(change); err != nil {
+		slog.Error("Failed to record delete in the outbox", "kind", kind, "namespace", item.namespace, "name", item.name, "error", err)
+	}
+}
+
+// replayOutbox queues every change left over from a previous run. It must run
+// after the informer caches have synced: replayed items are resolved against
+// the cache like any other, except that an object missing from it is deleted,
+// since it may have gone away while we were down.
+func (w *ResourceWatcher) replayOutbox() error {
+	queues := map[string]workqueue.RateLimitingInterface{
+		kindIngress: w.ingressQueue,
+		kindService: w.serviceQueue,
+		kindNode:    w.nodeQueue,
+	}
+
+	for kind, queue := range queues {
+		changes, err := w.outbox.Pending(kind)
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			slog.Info("Replaying pending changes from the outbox", "kind", kind, "count", len(changes))
+		}
+		for _, change := range changes {
+			queue.Add(workQueueItem{
+				key:             change.Key(),
+				namespace:       change.Namespace,
+				name:            change.Name,
+				operation:       operationReplay,
+				uid:             change.UID,
+				resourceVersion: change.ResourceVersion,
+			})
+		}
+	}
+	return nil
+}
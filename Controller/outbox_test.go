@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOutboxBatch(t *testing.T) {
+	outbox, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+
+	changes := []Change{
+		{Kind: kindService, Operation: operationUpsert, Namespace: "default", Name: "web", Payload: ServicePayload{Namespace: "default", ServiceName: "web"}},
+		{Kind: kindService, Operation: operationDelete, Namespace: "default", Name: "old"},
+	}
+	seqs, err := outbox.PutBatch(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != len(changes) {
+		t.Fatalf("got %d seqs, want %d", len(seqs), len(changes))
+	}
+
+	// web is replaced before its first send is confirmed, so only old goes
+	if _, err := outbox.Put(changes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.RemoveBatch(changes, seqs); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := outbox.Pending(kindService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Name != "web" {
+		t.Errorf("pending = %+v, want only web", pending)
+	}
+}
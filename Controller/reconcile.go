@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+)
+
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// Inventory is the state a sink should hold for one cluster. A nil entry is an
+// object known to be deleted. A Complete inventory is the whole cluster, so
+// anything else the sink holds is deleted too; otherwise only the objects in
+// the inventory are touched.
+type Inventory struct {
+	Cluster     string
+	ClusterInfo *ClusterInfo
+	Ingresses   map[string]*IngressPayload
+	Services    map[string]*ServicePayload
+	Nodes       map[string]*NodePayload
+	Complete    bool
+}
+
+func NewInventory() *Inventory {
+	return &Inventory{
+		Ingresses: make(map[string]*IngressPayload),
+		Services:  make(map[string]*ServicePayload),
+		Nodes:     make(map[string]*NodePayload),
+	}
+}
+
+// PlannedChange is a change needed to bring a sink in line with an inventory.
+// Previous is the payload the sink holds, set for updates.
+type PlannedChange struct {
+	Action string
+	Change
+	Previous interface{}
+}
+
+// errKindUnsupported is returned by sinks asked to list a kind they have
+// nowhere to store, like a backend that predates it
+var errKindUnsupported = errors.New("kind not supported")
+
+// planChanges compares desired with what sink currently lists and returns the
+// changes that reconcile them, ordered by kind and key. Objects whose payload
+// already matches are left alone, and so are kinds the sink doesn't support.
+func planChanges(ctx context.Context, sink Sink, desired *Inventory) ([]PlannedChange, error) {
+	var plan []PlannedChange
+
+	ingresses, err := sink.ListIngresses(ctx)
+	if ok, err := listed(sink, "ingresses", err); err != nil {
+		return nil, err
+	} else if ok {
+		current := make(map[string]Change, len(ingresses))
+		for _, ingress := range ingresses {
+			current[ingress.Namespace+"/"+ingress.IngressName] = changeForIngress(ingress)
+		}
+		wanted := make(map[string]*Change, len(desired.Ingresses))
+		for key, ingress := range desired.Ingresses {
+			if ingress != nil {
+				change := changeForIngress(*ingress)
+				wanted[key] = &change
+			} else {
+				wanted[key] = nil
+			}
+		}
+		plan = append(plan, planKind(current, wanted, desired.Complete)...)
+	}
+
+	services, err := sink.ListServices(ctx)
+	if ok, err := listed(sink, "services", err); err != nil {
+		return nil, err
+	} else if ok {
+		current := make(map[string]Change, len(services))
+		for _, service := range services {
+			current[service.Namespace+"/"+service.ServiceName] = changeForService(service)
+		}
+		wanted := make(map[string]*Change, len(desired.Services))
+		for key, service := range desired.Services {
+			if service != nil {
+				change := changeForService(*service)
+				wanted[key] = &change
+			} else {
+				wanted[key] = nil
+			}
+		}
+		plan = append(plan, planKind(current, wanted, desired.Complete)...)
+	}
+
+	nodes, err := sink.ListNodes(ctx)
+	if ok, err := listed(sink, "nodes", err); err != nil {
+		return nil, err
+	} else if ok {
+		current := make(map[string]Change, len(nodes))
+		for _, node := range nodes {
+			current[node.NodeName] = changeForNode(node)
+		}
+		wanted := make(map[string]*Change, len(desired.Nodes))
+		for key, node := range desired.Nodes {
+			if node != nil {
+				change := changeForNode(*node)
+				wanted[key] = &change
+			} else {
+				wanted[key] = nil
+			}
+		}
+		plan = append(plan, planKind(current, wanted, desired.Complete)...)
+	}
+
+	return plan, nil
+}
+
+// listed reports whether listing resources succeeded, turning a kind the sink
+// doesn't support into a warning so the rest of the plan can go ahead
+func listed(sink Sink, resources string, err error) (bool, error) {
+	if errors.Is(err, errKindUnsupported) {
+		slog.Warn("Sink doesn't support this kind, leaving it out", "sink", sink.Name(), "kind", resources, "error", err)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to list %s: %v", resources, err)
+	}
+	return true, nil
+}
+
+// planKind reconciles one kind. A nil wanted entry is a delete; with complete
+// set, so is every current object missing from wanted.
+func planKind(current map[string]Change, wanted map[string]*Change, complete bool) []PlannedChange {
+	var plan []PlannedChange
+
+	for key, change := range wanted {
+		existing, found := current[key]
+		switch {
+		case change == nil && found:
+			plan = append(plan, PlannedChange{Action: actionDelete, Change: deleteChange(existing)})
+		case change == nil:
+		case !found:
+			plan = append(plan, PlannedChange{Action: actionCreate, Change: *change})
+		case !payloadsEqual(existing.Payload, change.Payload):
+			plan = append(plan, PlannedChange{Action: actionUpdate, Change: *change, Previous: existing.Payload})
+		}
+	}
+
+	if complete {
+		for key, existing := range current {
+			if _, ok := wanted[key]; !ok {
+				plan = append(plan, PlannedChange{Action: actionDelete, Change: deleteChange(existing)})
+			}
+		}
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Key() < plan[j].Key()
+	})
+	return plan
+}
+
+func deleteChange(change Change) Change {
+	return Change{Kind: change.Kind, Operation: operationDelete, Namespace: change.Namespace, Name: change.Name}
+}
+
+// payloadsEqual compares payloads by their JSON, treating null and empty
+// lists alike since the backend doesn't preserve the difference
+func payloadsEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(canonicalJSON(a), canonicalJSON(b))
+}
+
+// canonicalJSON decodes v's JSON into generic values with null and empty
+// values dropped
+func canonicalJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	return pruneEmpty(generic)
+}
+
+func pruneEmpty(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if item = pruneEmpty(item); item == nil {
+				delete(value, key)
+			} else {
+				value[key] = item
+			}
+		}
+		if len(value) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = pruneEmpty(item)
+		}
+		if len(value) == 0 {
+			return nil
+		}
+	}
+	return v
+}
+
+// applyPlan sends plan through the sink one kind at a time, batched where the
+// sink supports it, and returns the changes that failed with their errors
+func applyPlan(ctx context.Context, sink Sink, plan []PlannedChange) map[int]error {
+	failed := make(map[int]error)
+
+	for _, kind := range []string{kindIngress, kindService, kindNode} {
+		var changes []Change
+		var index []int
+		for i, planned := range plan {
+			if planned.Kind == kind {
+				changes = append(changes, planned.Change)
+				index = append(index, i)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		errs, err := applyBatch(ctx, sink, kind, changes)
+		for i := range changes {
+			itemErr := err
+			if itemErr == nil && i < len(errs) {
+				itemErr = errs[i]
+			}
+			if itemErr != nil {
+				failed[index[i]] = itemErr
+			}
+		}
+	}
+
+	return failed
+}
+
+// planCounts counts the changes in plan by action, leaving out failed ones
+func planCounts(plan []PlannedChange, failed map[int]error) map[string]int {
+	counts := make(map[string]int)
+	for i, planned := range plan {
+		if _, ok := failed[i]; !ok {
+			counts[planned.Action]++
+		}
+	}
+	return counts
+}
+
+func planSummary(plan []PlannedChange, failed map[int]error) string {
+	counts := planCounts(plan, failed)
+	summary := fmt.Sprintf("%d created, %d updated, %d deleted", counts[actionCreate], counts[actionUpdate], counts[actionDelete])
+	if len(failed) > 0 {
+		summary += fmt.Sprintf(", %d failed", len(failed))
+	}
+	return summary
+}
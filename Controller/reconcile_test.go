@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanKind(t *testing.T) {
+	web := changeForService(ServicePayload{Namespace: "default", ServiceName: "web", Ports: []int32{80}})
+	webChanged := changeForService(ServicePayload{Namespace: "default", ServiceName: "web", Ports: []int32{443}})
+	db := changeForService(ServicePayload{Namespace: "default", ServiceName: "db"})
+
+	tests := []struct {
+		name     string
+		current  []Change
+		wanted   map[string]*Change
+		complete bool
+		want     []string
+	}{
+		{
+			name:   "create",
+			wanted: map[string]*Change{"default/web": &web},
+			want:   []string{"create default/web"},
+		},
+		{
+			name:    "unchanged",
+			current: []Change{web},
+			wanted:  map[string]*Change{"default/web": &web},
+		},
+		{
+			name:    "update",
+			current: []Change{web},
+			wanted:  map[string]*Change{"default/web": &webChanged},
+			want:    []string{"update default/web"},
+		},
+		{
+			name:    "known delete",
+			current: []Change{web},
+			wanted:  map[string]*Change{"default/web": nil},
+			want:    []string{"delete default/web"},
+		},
+		{
+			name:   "delete of an object the sink doesn't hold",
+			wanted: map[string]*Change{"default/web": nil},
+		},
+		{
+			name:    "partial inventory leaves others alone",
+			current: []Change{web, db},
+			wanted:  map[string]*Change{"default/web": &web},
+		},
+		{
+			name:     "complete inventory deletes others",
+			current:  []Change{web, db},
+			wanted:   map[string]*Change{"default/web": &web},
+			complete: true,
+			want:     []string{"delete default/db"},
+		},
+		{
+			name:     "ordered by key",
+			wanted:   map[string]*Change{"default/web": &web, "default/db": &db},
+			complete: true,
+			want:     []string{"create default/db", "create default/web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := make(map[string]Change)
+			for _, change := range tt.current {
+				current[change.Key()] = change
+			}
+
+			var got []string
+			for _, planned := range planKind(current, tt.wanted, tt.complete) {
+				got = append(got, planned.Action+" "+planned.Key())
+				if planned.Action == actionDelete && planned.Operation != operationDelete {
+					t.Errorf("%s planned as operation %q", planned.Key(), planned.Operation)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadsEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{
+			name: "identical",
+			a:    ServicePayload{ServiceName: "web", Ports: []int32{80}},
+			b:    ServicePayload{ServiceName: "web", Ports: []int32{80}},
+			want: true,
+		},
+		{
+			name: "nil and empty list",
+			a:    ServicePayload{ServiceName: "web", Ports: nil},
+			b:    ServicePayload{ServiceName: "web", Ports: []int32{}},
+			want: true,
+		},
+		{
+			name: "nil and empty map",
+			a:    map[string]interface{}{"name": "web", "labels": nil},
+			b:    map[string]interface{}{"name": "web", "labels": map[string]string{}},
+			want: true,
+		},
+		{
+			name: "different value",
+			a:    ServicePayload{ServiceName: "web", Ports: []int32{80}},
+			b:    ServicePayload{ServiceName: "web", Ports: []int32{443}},
+			want: false,
+		},
+		{
+			name: "list order matters",
+			a:    ServicePayload{ServiceName: "web", Ports: []int32{80, 443}},
+			b:    ServicePayload{ServiceName: "web", Ports: []int32{443, 80}},
+			want: false,
+		},
+		{
+			name: "empty string is a value",
+			a:    ServicePayload{ServiceName: "web", ExternalIP: ""},
+			b:    ServicePayload{ServiceName: "web", ExternalIP: "10.0.0.1"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := payloadsEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("payloadsEqual() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
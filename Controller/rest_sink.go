@@ -0,0 +1,685 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/tools/cache"
+)
+
+type IngressResponse struct {
+	ID int `json:"id"`
+	IngressPayload
+}
+
+type ServiceResponse struct {
+	ID int `json:"id"`
+	ServicePayload
+}
+
+type NodeResponse struct {
+	ID int `json:"id"`
+	NodePayload
+}
+
+type ClusterResponse struct {
+	ID               int      `json:"id"`
+	ClusterName      string   `json:"clusterName"`
+	APIServerVersion string   `json:"apiserverVersion"`
+	ClusterUID       string   `json:"clusterUid"`
+	KubeletVersions  []string `json:"kubeletVersions"`
+	KernelVersions   []string `json:"kernelVersions"`
+}
+
+// RESTSink writes to the tracker backend API. The backend addresses records by
+// its own numeric IDs, so every update and delete first looks the object up in
+// the cluster's list.
+type RESTSink struct {
+	endpoint    string
+	clusterName string
+	httpClient  *http.Client
+
+	// bulkUnsupported remembers resources whose bulk endpoint the backend
+	// doesn't have, so batches go straight to the per-item fallback
+	bulkMu          sync.Mutex
+	bulkUnsupported map[string]bool
+
+	// storedFields remembers the fields each resource's records came back
+	// with, a backend older than the payloads doesn't store all of them
+	fieldsMu     sync.Mutex
+	storedFields map[string]map[string]bool
+}
+
+func NewRESTSink(endpoint, clusterName string, httpClient *http.Client) *RESTSink {
+	return &RESTSink{
+		endpoint:    endpoint,
+		clusterName: clusterName,
+		httpClient:  httpClient,
+
+		bulkUnsupported: make(map[string]bool),
+		storedFields:    make(map[string]map[string]bool),
+	}
+}
+
+func (s *RESTSink) Name() string {
+	return "rest"
+}
+
+// GetCluster looks the cluster up by its stable uid first so a renamed
+// cluster finds its existing record, then falls back to the name for older
+// backends. It returns nil if the backend has no record of the cluster.
+func (s *RESTSink) GetCluster(ctx context.Context, uid string) (*ClusterResponse, error) {
+	var cluster ClusterResponse
+	found, err := s.get(ctx, fmt.Sprintf("%s/api/clusters/uid/%s", s.endpoint, uid), &cluster)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		found, err = s.get(ctx, fmt.Sprintf("%s/api/clusters/name/%s", s.endpoint, s.clusterName), &cluster)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return &cluster, nil
+}
+
+func (s *RESTSink) SendClusterInfo(ctx context.Context, clusterInfo *ClusterInfo) error {
+	existingCluster, err := s.GetCluster(ctx, clusterInfo.ClusterUID)
+	if err != nil {
+		return fmt.Errorf("failed to check cluster existence: %v", err)
+	}
+	found := existingCluster != nil
+
+	jsonData, err := json.Marshal(clusterInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster info: %v", err)
+	}
+
+	var req *http.Request
+	if found {
+		// Cluster exists, update it by ID
+		req, err = http.NewRequestWithContext(ctx,
+			http.MethodPut,
+			fmt.Sprintf("%s/api/clusters/%d", s.endpoint, existingCluster.ID),
+			bytes.NewBuffer(jsonData),
+		)
+		slog.Debug("Updating existing cluster", "id", existingCluster.ID)
+	} else {
+		// Cluster doesn't exist, create new
+		req, err = http.NewRequestWithContext(ctx,
+			http.MethodPost,
+			fmt.Sprintf("%s/api/clusters", s.endpoint),
+			bytes.NewBuffer(jsonData),
+		)
+		slog.Info("Creating new cluster entry")
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("received non-OK response: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+func (s *RESTSink) listIngresses(ctx context.Context) ([]IngressResponse, error) {
+	var ingresses []IngressResponse
+	if err := s.list(ctx, "ingress", &ingresses); err != nil {
+		return nil, err
+	}
+	return ingresses, nil
+}
+
+func (s *RESTSink) findIngressID(ctx context.Context, namespace, name string) (int, bool, error) {
+	listCtx, span := tracer.Start(ctx, "find ingress id")
+	ingresses, err := s.listIngresses(listCtx)
+	endSpan(span, err)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, ing := range ingresses {
+		if ing.Namespace == namespace && ing.IngressName == name {
+			return ing.ID, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (s *RESTSink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	id, found, err := s.findIngressID(ctx, payload.Namespace, payload.IngressName)
+	if err != nil {
+		return fmt.Errorf("error finding ingress ID: %v", err)
+	}
+	return s.upsert(ctx, "ingress", "Ingress", payload.Namespace+"/"+payload.IngressName, id, found, payload)
+}
+
+func (s *RESTSink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	id, found, err := s.findIngressID(ctx, namespace, name)
+	if err != nil {
+		return fmt.Errorf("error finding ingress ID: %v", err)
+	}
+	if !found {
+		slog.Debug("Not in backend, nothing to delete", "kind", kindIngress, "namespace", namespace, "name", name)
+		return nil
+	}
+	return s.delete(ctx, "ingress", "Ingress", namespace+"/"+name, id)
+}
+
+func (s *RESTSink) ListIngresses(ctx context.Context) ([]IngressPayload, error) {
+	ingresses, err := s.listIngresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([]IngressPayload, 0, len(ingresses))
+	for _, ing := range ingresses {
+		payloads = append(payloads, ing.IngressPayload)
+	}
+	return payloads, nil
+}
+
+func (s *RESTSink) listServices(ctx context.Context) ([]ServiceResponse, error) {
+	var services []ServiceResponse
+	if err := s.list(ctx, "service", &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (s *RESTSink) findServiceID(ctx context.Context, namespace, name string) (int, bool, error) {
+	listCtx, span := tracer.Start(ctx, "find service id")
+	services, err := s.listServices(listCtx)
+	endSpan(span, err)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, svc := range services {
+		if svc.Namespace == namespace && svc.ServiceName == name {
+			return svc.ID, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (s *RESTSink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	id, found, err := s.findServiceID(ctx, payload.Namespace, payload.ServiceName)
+	if err != nil {
+		return fmt.Errorf("error finding service ID: %v", err)
+	}
+	return s.upsert(ctx, "service", "Service", payload.Namespace+"/"+payload.ServiceName, id, found, payload)
+}
+
+func (s *RESTSink) DeleteService(ctx context.Context, namespace, name string) error {
+	id, found, err := s.findServiceID(ctx, namespace, name)
+	if err != nil {
+		return fmt.Errorf("error finding service ID: %v", err)
+	}
+	if !found {
+		slog.Debug("Not in backend, nothing to delete", "kind", kindService, "namespace", namespace, "name", name)
+		return nil
+	}
+	return s.delete(ctx, "service", "Service", namespace+"/"+name, id)
+}
+
+func (s *RESTSink) ListServices(ctx context.Context) ([]ServicePayload, error) {
+	services, err := s.listServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([]ServicePayload, 0, len(services))
+	for _, svc := range services {
+		payloads = append(payloads, svc.ServicePayload)
+	}
+	return payloads, nil
+}
+
+func (s *RESTSink) listNodes(ctx context.Context) ([]NodeResponse, error) {
+	var nodes []NodeResponse
+	if err := s.list(ctx, "node", &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+func (s *RESTSink) findNodeID(ctx context.Context, name string) (int, bool, error) {
+	listCtx, span := tracer.Start(ctx, "find node id")
+	nodes, err := s.listNodes(listCtx)
+	endSpan(span, err)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, node := range nodes {
+		if node.NodeName == name {
+			return node.ID, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (s *RESTSink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	id, found, err := s.findNodeID(ctx, payload.NodeName)
+	if err != nil {
+		return fmt.Errorf("error finding node ID: %v", err)
+	}
+	return s.upsert(ctx, "node", "Node", payload.NodeName, id, found, payload)
+}
+
+func (s *RESTSink) DeleteNode(ctx context.Context, name string) error {
+	id, found, err := s.findNodeID(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error finding node ID: %v", err)
+	}
+	if !found {
+		slog.Debug("Not in backend, nothing to delete", "kind", kindNode, "name", name)
+		return nil
+	}
+	return s.delete(ctx, "node", "Node", name, id)
+}
+
+func (s *RESTSink) ListNodes(ctx context.Context) ([]NodePayload, error) {
+	nodes, err := s.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([]NodePayload, 0, len(nodes))
+	for _, node := range nodes {
+		payloads = append(payloads, node.NodePayload)
+	}
+	return payloads, nil
+}
+
+// get decodes the resource at url into out, reporting false on a 404
+func (s *RESTSink) get(ctx context.Context, url string, out interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// list fetches every record of a resource for this cluster
+func (s *RESTSink) list(ctx context.Context, resource string, out interface{}) error {
+	var raw json.RawMessage
+	found, err := s.get(ctx, fmt.Sprintf("%s/api/%s/cluster/%s", s.endpoint, resource, s.clusterName), &raw)
+	if err != nil {
+		return err
+	}
+	// the list route answers an empty list for a cluster with no records, a
+	// 404 means the backend has no such resource at all
+	if !found {
+		return fmt.Errorf("backend has no /api/%s routes: %w", resource, errKindUnsupported)
+	}
+
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return err
+	}
+	fields := make(map[string]bool)
+	for _, record := range records {
+		for name := range record {
+			fields[name] = true
+		}
+	}
+	s.fieldsMu.Lock()
+	s.storedFields[resource] = fields
+	s.fieldsMu.Unlock()
+
+	return json.Unmarshal(raw, out)
+}
+
+// fieldsStored returns the fields the backend returned for a resource the
+// last time it was listed, nil if it hasn't been listed yet
+func (s *RESTSink) fieldsStored(resource string) map[string]bool {
+	s.fieldsMu.Lock()
+	defer s.fieldsMu.Unlock()
+	return s.storedFields[resource]
+}
+
+func (s *RESTSink) upsert(ctx context.Context, resource, kind, key string, id int, found bool, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling payload: %v", err)
+	}
+
+	var req *http.Request
+	var actionType string
+
+	if found {
+		req, err = http.NewRequestWithContext(ctx,
+			http.MethodPut,
+			fmt.Sprintf("%s/api/%s/%d", s.endpoint, resource, id),
+			bytes.NewBuffer(jsonData),
+		)
+		actionType = "UPDATE"
+	} else {
+		req, err = http.NewRequestWithContext(ctx,
+			http.MethodPost,
+			fmt.Sprintf("%s/api/%s", s.endpoint, resource),
+			bytes.NewBuffer(jsonData),
+		)
+		actionType = "CREATE"
+	}
+
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	operation := strings.ToLower(actionType)
+	slog.Debug("Sending backend request", "kind", resource, "namespace", namespace, "name", name, "operation", operation, "method", req.Method, "url", req.URL.String())
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		slog.Debug("Backend request failed", "kind", resource, "namespace", namespace, "name", name, "operation", operation, "status", resp.StatusCode, "duration", time.Since(start))
+		return &statusError{status: resp.StatusCode, msg: fmt.Sprintf("API %s Response - %s %s - Status: %d, Error: %s",
+			actionType, kind, key, resp.StatusCode, string(body))}
+	}
+
+	slog.Debug("Backend request succeeded", "kind", resource, "namespace", namespace, "name", name, "operation", operation, "status", resp.StatusCode, "duration", time.Since(start))
+	return nil
+}
+
+func (s *RESTSink) delete(ctx context.Context, resource, kind, key string, id int) error {
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodDelete,
+		fmt.Sprintf("%s/api/%s/%d", s.endpoint, resource, id),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	slog.Debug("Sending backend request", "kind", resource, "namespace", namespace, "name", name, "operation", operationDelete, "method", req.Method, "url", req.URL.String())
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making DELETE request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		slog.Debug("Backend request failed", "kind", resource, "namespace", namespace, "name", name, "operation", operationDelete, "status", resp.StatusCode, "duration", time.Since(start))
+		return &statusError{status: resp.StatusCode, msg: fmt.Sprintf("API DELETE Response - %s %s - Status: %d, Error: %s",
+			kind, key, resp.StatusCode, string(body))}
+	}
+
+	slog.Debug("Backend request succeeded", "kind", resource, "namespace", namespace, "name", name, "operation", operationDelete, "status", resp.StatusCode, "duration", time.Since(start))
+	return nil
+}
+
+// statusError is a backend response with a status code other than success
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// errorStatus returns the status code the backend answered a failed request
+// with, 0 if it didn't answer
+func errorStatus(err error) int {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status
+	}
+	return 0
+}
+
+// permanentError reports whether err is the backend rejecting a change, which
+// a retry would only repeat. 404 and 409 are left out: they follow a stale id
+// lookup, which the retry does afresh. 408 and 429 ask for a retry.
+func permanentError(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.status {
+	case http.StatusNotFound, http.StatusConflict, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return statusErr.status >= 400 && statusErr.status < 500
+}
+
+type bulkDelete struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+type bulkRequest struct {
+	ClusterName string        `json:"clusterName"`
+	Upserts     []interface{} `json:"upserts"`
+	Deletes     []bulkDelete  `json:"deletes"`
+}
+
+type bulkResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+}
+
+type bulkResponse struct {
+	Results []bulkResult `json:"results"`
+}
+
+// ApplyBatch sends all changes of a kind in one POST to /api/<kind>/bulk. For
+// backends without the bulk endpoint it falls back to individual requests that
+// share a single list lookup instead of one per change.
+func (s *RESTSink) ApplyBatch(ctx context.Context, kind string, changes []Change) ([]error, error) {
+	s.bulkMu.Lock()
+	unsupported := s.bulkUnsupported[kind]
+	s.bulkMu.Unlock()
+
+	if !unsupported {
+		errs, supported, err := s.applyBulk(ctx, kind, changes)
+		if supported {
+			return errs, err
+		}
+
+		slog.Info("Backend has no bulk endpoint, sending changes individually", "kind", kind)
+		s.bulkMu.Lock()
+		s.bulkUnsupported[kind] = true
+		s.bulkMu.Unlock()
+	}
+
+	return s.applyIndividually(ctx, kind, changes)
+}
+
+func (s *RESTSink) applyBulk(ctx context.Context, kind string, changes []Change) ([]error, bool, error) {
+	body := bulkRequest{
+		ClusterName: s.clusterName,
+		Upserts:     []interface{}{},
+		Deletes:     []bulkDelete{},
+	}
+	for _, change := range changes {
+		if change.Operation == operationDelete {
+			body.Deletes = append(body.Deletes, bulkDelete{Namespace: change.Namespace, Name: change.Name})
+		} else {
+			body.Upserts = append(body.Upserts, change.Payload)
+		}
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, true, fmt.Errorf("error marshaling payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s/api/%s/bulk", s.endpoint, kind),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, true, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("Sending backend bulk request", "kind", kind, "upserts", len(body.Upserts), "deletes", len(body.Deletes), "method", req.Method, "url", req.URL.String())
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, false, nil
+	}
+	// 207 reports per-item outcomes when only some of them failed
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+		respBody, _ := io.ReadAll(resp.Body)
+		slog.Debug("Backend bulk request failed", "kind", kind, "status", resp.StatusCode, "count", len(changes), "duration", time.Since(start))
+		return nil, true, fmt.Errorf("API BULK Response - Status: %d, Error: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, true, fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+
+	outcomes := make(map[string]bulkResult, len(result.Results))
+	for _, r := range result.Results {
+		outcomes[r.Operation+" "+Change{Namespace: r.Namespace, Name: r.Name}.Key()] = r
+	}
+
+	errs := make([]error, len(changes))
+	failed := 0
+	for i, change := range changes {
+		r, ok := outcomes[change.Operation+" "+change.Key()]
+		switch {
+		case !ok:
+			errs[i] = fmt.Errorf("no result returned for %s %s", change.Operation, change.Key())
+		case !r.Success:
+			errs[i] = fmt.Errorf("bulk %s failed: %s", change.Operation, r.Error)
+		}
+		if errs[i] != nil {
+			failed++
+		}
+	}
+
+	slog.Debug("Backend bulk request finished", "kind", kind, "status", resp.StatusCode, "count", len(changes), "failed", failed, "duration", time.Since(start))
+	return errs, true, nil
+}
+
+func (s *RESTSink) applyIndividually(ctx context.Context, kind string, changes []Change) ([]error, error) {
+	listCtx, span := tracer.Start(ctx, "find "+kind+" ids", trace.WithAttributes(attribute.Int("count", len(changes))))
+	ids, err := s.listIDs(listCtx, kind)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	kindName := map[string]string{kindIngress: "Ingress", kindService: "Service", kindNode: "Node"}[kind]
+
+	errs := make([]error, len(changes))
+	for i, change := range changes {
+		id, found := ids[change.Key()]
+		if change.Operation == operationDelete {
+			if found {
+				errs[i] = s.delete(ctx, kind, kindName, change.Key(), id)
+			}
+			continue
+		}
+		errs[i] = s.upsert(ctx, kind, kindName, change.Key(), id, found, change.Payload)
+	}
+	return errs, nil
+}
+
+// listIDs maps the keys of a kind's records to their backend IDs
+func (s *RESTSink) listIDs(ctx context.Context, kind string) (map[string]int, error) {
+	ids := make(map[string]int)
+	switch kind {
+	case kindIngress:
+		ingresses, err := s.listIngresses(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing ingresses: %v", err)
+		}
+		for _, ing := range ingresses {
+			ids[ing.Namespace+"/"+ing.IngressName] = ing.ID
+		}
+	case kindService:
+		services, err := s.listServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing services: %v", err)
+		}
+		for _, svc := range services {
+			ids[svc.Namespace+"/"+svc.ServiceName] = svc.ID
+		}
+	case kindNode:
+		nodes, err := s.listNodes(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing nodes: %v", err)
+		}
+		for _, node := range nodes {
+			ids[node.NodeName] = node.ID
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	return ids, nil
+}
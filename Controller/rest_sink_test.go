@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPermanentError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad request", err: &statusError{status: http.StatusBadRequest}, want: true},
+		{name: "unprocessable", err: &statusError{status: http.StatusUnprocessableEntity}, want: true},
+		{name: "stale id", err: &statusError{status: http.StatusNotFound}, want: false},
+		{name: "conflict", err: &statusError{status: http.StatusConflict}, want: false},
+		{name: "rate limited", err: &statusError{status: http.StatusTooManyRequests}, want: false},
+		{name: "server error", err: &statusError{status: http.StatusBadGateway}, want: false},
+		{name: "wrapped", err: fmt.Errorf("sync: %w", &statusError{status: http.StatusBadRequest}), want: true},
+		{name: "network", err: fmt.Errorf("connection refused"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := permanentError(tt.err); got != tt.want {
+				t.Errorf("permanentError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeBackend serves the routes of the tracker backend for the kinds given,
+// keeping records in memory. The bulk routes are only served with bulk set,
+// like a backend from before they were added; anything else is a 404.
+type fakeBackend struct {
+	t       *testing.T
+	mu      sync.Mutex
+	kinds   map[string]bool
+	bulk    bool
+	nextID  int
+	cluster map[string]interface{}
+	records map[string]map[int]map[string]interface{}
+	// rejected names fail in bulk requests
+	rejected map[string]bool
+	// requests counts requests by method and route, with ids as {id}
+	requests map[string]int
+}
+
+func newFakeBackend(t *testing.T, kinds ...string) (*fakeBackend, *httptest.Server) {
+	b := &fakeBackend{
+		t:        t,
+		kinds:    make(map[string]bool),
+		records:  make(map[string]map[int]map[string]interface{}),
+		rejected: make(map[string]bool),
+		requests: make(map[string]int),
+	}
+	for _, kind := range kinds {
+		b.kinds[kind] = true
+		b.records[kind] = make(map[int]map[string]interface{})
+	}
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	return b, server
+}
+
+// add stores a record as if an earlier run had sent it
+func (b *fakeBackend) add(kind string, payload interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.store(kind, 0, payload)
+}
+
+// edit changes every stored record of a kind in place
+func (b *fakeBackend) edit(kind string, change func(record map[string]interface{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, record := range b.records[kind] {
+		change(record)
+	}
+}
+
+func (b *fakeBackend) store(kind string, id int, payload interface{}) map[string]interface{} {
+	if id == 0 {
+		b.nextID++
+		id = b.nextID
+	}
+	data, _ := json.Marshal(payload)
+	var record map[string]interface{}
+	json.Unmarshal(data, &record)
+	record["id"] = id
+	b.records[kind][id] = record
+	return record
+}
+
+// applyBulk applies a bulk request the way the backend does, one result per
+// item. Callers hold b.mu.
+func (b *fakeBackend) applyBulk(kind string, body map[string]interface{}) bulkResponse {
+	nameField := map[string]string{"ingress": "ingressName", "service": "serviceName", "node": "nodeName"}[kind]
+	find := func(namespace, name string) int {
+		for id, record := range b.records[kind] {
+			recordNamespace, _ := record["namespace"].(string)
+			if recordNamespace == namespace && record[nameField] == name {
+				return id
+			}
+		}
+		return 0
+	}
+
+	var response bulkResponse
+	upserts, _ := body["upserts"].([]interface{})
+	for _, upsert := range upserts {
+		payload := upsert.(map[string]interface{})
+		namespace, _ := payload["namespace"].(string)
+		name, _ := payload[nameField].(string)
+		result := bulkResult{Namespace: namespace, Name: name, Operation: operationUpsert, Success: !b.rejected[name]}
+		if result.Success {
+			b.store(kind, find(namespace, name), payload)
+		} else {
+			result.Error = "rejected"
+		}
+		response.Results = append(response.Results, result)
+	}
+	deletes, _ := body["deletes"].([]interface{})
+	for _, del := range deletes {
+		item := del.(map[string]interface{})
+		namespace, _ := item["namespace"].(string)
+		name, _ := item["name"].(string)
+		delete(b.records[kind], find(namespace, name))
+		response.Results = append(response.Results, bulkResult{Namespace: namespace, Name: name, Operation: operationDelete, Success: true})
+	}
+	return response
+}
+
+func (b *fakeBackend) names(kind, field string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for _, record := range b.records[kind] {
+		names = append(names, fmt.Sprint(record[field]))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (b *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	route := make([]string, len(parts))
+	copy(route, parts)
+	if len(route) == 2 {
+		if _, err := strconv.Atoi(route[1]); err == nil {
+			route[1] = "{id}"
+		}
+	}
+	b.requests[r.Method+" /api/"+strings.Join(route, "/")]++
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	respond := func(status int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+
+	if parts[0] == "clusters" {
+		switch {
+		case r.Method == http.MethodGet && len(parts) == 3 && (parts[1] == "uid" || parts[1] == "name"):
+			if b.cluster == nil {
+				respond(http.StatusNotFound, "not found")
+				return
+			}
+			respond(http.StatusOK, b.cluster)
+		case r.Method == http.MethodPost && len(parts) == 1:
+			body["id"] = 1
+			b.cluster = body
+			respond(http.StatusCreated, body)
+		case r.Method == http.MethodPut && len(parts) == 2 && b.cluster != nil:
+			body["id"] = 1
+			b.cluster = body
+			respond(http.StatusOK, body)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	kind := parts[0]
+	if !b.kinds[kind] {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[1] == "cluster":
+		list := []map[string]interface{}{}
+		for _, record := range b.records[kind] {
+			list = append(list, record)
+		}
+		respond(http.StatusOK, list)
+	case r.Method == http.MethodPost && len(parts) == 1:
+		respond(http.StatusCreated, b.store(kind, 0, body))
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "bulk" && b.bulk:
+		response := b.applyBulk(kind, body)
+		status := http.StatusOK
+		for _, result := range response.Results {
+			if !result.Success {
+				status = http.StatusMultiStatus
+			}
+		}
+		respond(status, response)
+	case (r.Method == http.MethodPut || r.Method == http.MethodDelete) && len(parts) == 2:
+		id, err := strconv.Atoi(parts[1])
+		if _, ok := b.records[kind][id]; err != nil || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(b.records[kind], id)
+			respond(http.StatusOK, nil)
+			return
+		}
+		respond(http.StatusOK, b.store(kind, id, body))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestApplyBatchBulk(t *testing.T) {
+	backend, server := newFakeBackend(t, "ingress", "service", "node")
+	backend.bulk = true
+	backend.rejected["bad"] = true
+	backend.add("service", ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "stale"})
+
+	sink := NewRESTSink(server.URL, "test", http.DefaultClient)
+	errs, err := sink.ApplyBatch(context.Background(), kindService, []Change{
+		changeForService(ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "web"}),
+		changeForService(ServicePayload{ClusterName: "test", Namespace: "default", ServiceName: "bad"}),
+		{Kind: kindService, Operation: operationDelete, Namespace: "default", Name: "stale"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("%d errors, want one per change", len(errs))
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("errors = %v, want only the rejected service to fail", errs)
+	}
+	if errs[1] == nil {
+		t.Error("rejected service didn't fail")
+	}
+
+	if got, want := backend.names("service", "serviceName"), []string{"web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backend services = %v, want %v", got, want)
+	}
+	if want := map[string]int{"POST /api/service/bulk": 1}; !reflect.DeepEqual(backend.requests, want) {
+		t.Errorf("requests = %v, want %v", backend.requests, want)
+	}
+}
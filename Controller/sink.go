@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sink is a destination for the inventory the watcher collects. Upserts must
+// be idempotent and deleting an object the sink doesn't know about is not an
+// error, since the watcher retries failed items and replays everything on
+// resync.
+type Sink interface {
+	Name() string
+
+	SendClusterInfo(ctx context.Context, info *ClusterInfo) error
+
+	UpsertIngress(ctx context.Context, payload IngressPayload) error
+	DeleteIngress(ctx context.Context, namespace, name string) error
+	ListIngresses(ctx context.Context) ([]IngressPayload, error)
+
+	UpsertService(ctx context.Context, payload ServicePayload) error
+	DeleteService(ctx context.Context, namespace, name string) error
+	ListServices(ctx context.Context) ([]ServicePayload, error)
+
+	UpsertNode(ctx context.Context, payload NodePayload) error
+	DeleteNode(ctx context.Context, name string) error
+	ListNodes(ctx context.Context) ([]NodePayload, error)
+}
+
+// newSink builds the sinks named in the comma separated SINKS setting,
+// wrapping them in a MultiSink when there is more than one
+func newSink(config *Config, clusterName string, httpClient *http.Client) (Sink, error) {
+	sinks := []Sink{}
+	for _, name := range config.Sinks {
+		// dry runs only stop HTTP writes to the backend, the other sinks
+		// would still publish
+		if config.DryRun && name != "rest" && name != "memory" {
+			return nil, fmt.Errorf("sink %s doesn't support --dry-run", name)
+		}
+
+		switch name {
+		case "rest":
+			sinks = append(sinks, NewRESTSink(config.APIEndpoint, clusterName, httpClient))
+		case "memory":
+			sinks = append(sinks, NewMemorySink())
+		case "kafka":
+			sink, err := NewKafkaSink(config.Kafka, clusterName)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "nats":
+			sink, err := NewNATSSink(config.NATS, clusterName)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "webhook":
+			sinks = append(sinks, NewWebhookSink(config.Webhooks, clusterName))
+		case "file":
+			sink, err := NewFileSink(config.FileSink, clusterName)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown sink %q", name)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("at least one sink is required")
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return NewMultiSink(sinks...), nil
+}
+
+// MultiSink fans every write out to all of its sinks. A write fails if any sink
+// fails, so a retry re-sends to sinks that already succeeded, which the Sink
+// contract allows. Lists are served by the first sink.
+type MultiSink struct {
+	sinks []Sink
+}
+
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+func (m *MultiSink) Name() string {
+	names := make([]string, 0, len(m.sinks))
+	for _, sink := range m.sinks {
+		names = append(names, sink.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (m *MultiSink) each(op string, fn func(Sink) error) error {
+	var failed []string
+	for _, sink := range m.sinks {
+		if err := fn(sink); err != nil {
+			slog.Warn("Sink failed", "sink", sink.Name(), "operation", op, "error", err)
+			failed = append(failed, fmt.Sprintf("%s: %v", sink.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %d of %d sinks: %s", op, len(failed), len(m.sinks), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// ApplyBatch sends the batch to every sink; a change fails if any sink failed
+// it
+func (m *MultiSink) ApplyBatch(ctx context.Context, kind string, changes []Change) ([]error, error) {
+	errs := make([]error, len(changes))
+	for _, sink := range m.sinks {
+		sinkErrs, err := applyBatch(ctx, sink, kind, changes)
+		for i := range changes {
+			itemErr := err
+			if itemErr == nil && i < len(sinkErrs) {
+				itemErr = sinkErrs[i]
+			}
+			if itemErr != nil && errs[i] == nil {
+				errs[i] = fmt.Errorf("%s: %v", sink.Name(), itemErr)
+			}
+		}
+	}
+	return errs, nil
+}
+
+func (m *MultiSink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	return m.each("send cluster info", func(s Sink) error { return s.SendClusterInfo(ctx, info) })
+}
+
+func (m *MultiSink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	return m.each("upsert ingress", func(s Sink) error { return s.UpsertIngress(ctx, payload) })
+}
+
+func (m *MultiSink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	return m.each("delete ingress", func(s Sink) error { return s.DeleteIngress(ctx, namespace, name) })
+}
+
+func (m *MultiSink) ListIngresses(ctx context.Context) ([]IngressPayload, error) {
+	return m.sinks[0].ListIngresses(ctx)
+}
+
+func (m *MultiSink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	return m.each("upsert service", func(s Sink) error { return s.UpsertService(ctx, payload) })
+}
+
+func (m *MultiSink) DeleteService(ctx context.Context, namespace, name string) error {
+	return m.each("delete service", func(s Sink) error { return s.DeleteService(ctx, namespace, name) })
+}
+
+func (m *MultiSink) ListServices(ctx context.Context) ([]ServicePayload, error) {
+	return m.sinks[0].ListServices(ctx)
+}
+
+func (m *MultiSink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	return m.each("upsert node", func(s Sink) error { return s.UpsertNode(ctx, payload) })
+}
+
+func (m *MultiSink) DeleteNode(ctx context.Context, name string) error {
+	return m.each("delete node", func(s Sink) error { return s.DeleteNode(ctx, name) })
+}
+
+func (m *MultiSink) ListNodes(ctx context.Context) ([]NodePayload, error) {
+	return m.sinks[0].ListNodes(ctx)
+}
+
+// MemorySink keeps the latest state of everything it is sent. It is meant for
+// tests and local runs (SINKS=memory) rather than production use.
+type MemorySink struct {
+	mu          sync.RWMutex
+	clusterInfo *ClusterInfo
+	ingresses   map[string]IngressPayload
+	services    map[string]ServicePayload
+	nodes       map[string]NodePayload
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{
+		ingresses: make(map[string]IngressPayload),
+		services:  make(map[string]ServicePayload),
+		nodes:     make(map[string]NodePayload),
+	}
+}
+
+func (m *MemorySink) Name() string {
+	return "memory"
+}
+
+func (m *MemorySink) ClusterInfo() *ClusterInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clusterInfo
+}
+
+func (m *MemorySink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clusterInfo = info
+	return nil
+}
+
+func (m *MemorySink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ingresses[payload.Namespace+"/"+payload.IngressName] = payload
+	return nil
+}
+
+func (m *MemorySink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.ingresses, namespace+"/"+name)
+	return nil
+}
+
+func (m *MemorySink) ListIngresses(ctx context.Context) ([]IngressPayload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ingresses := make([]IngressPayload, 0, len(m.ingresses))
+	for _, ingress := range m.ingresses {
+		ingresses = append(ingresses, ingress)
+	}
+	sort.Slice(ingresses, func(i, j int) bool {
+		return ingresses[i].Namespace+"/"+ingresses[i].IngressName < ingresses[j].Namespace+"/"+ingresses[j].IngressName
+	})
+	return ingresses, nil
+}
+
+func (m *MemorySink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.services[payload.Namespace+"/"+payload.ServiceName] = payload
+	return nil
+}
+
+func (m *MemorySink) DeleteService(ctx context.Context, namespace, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.services, namespace+"/"+name)
+	return nil
+}
+
+func (m *MemorySink) ListServices(ctx context.Context) ([]ServicePayload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	services := make([]ServicePayload, 0, len(m.services))
+	for _, service := range m.services {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Namespace+"/"+services[i].ServiceName < services[j].Namespace+"/"+services[j].ServiceName
+	})
+	return services, nil
+}
+
+func (m *MemorySink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes[payload.NodeName] = payload
+	return nil
+}
+
+func (m *MemorySink) DeleteNode(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemorySink) ListNodes(ctx context.Context) ([]NodePayload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	nodes := make([]NodePayload, 0, len(m.nodes))
+	for _, node := range m.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeName < nodes[j].NodeName
+	})
+	return nodes, nil
+}
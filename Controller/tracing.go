@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var tracer = otel.Tracer("k8s-watcher")
+
+// setupTracing exports spans over OTLP/HTTP when an endpoint is configured.
+// The exporter reads the standard OTEL_EXPORTER_OTLP_* variables itself, so
+// headers, timeouts and plain-text collectors (an http:// endpoint) work as
+// documented for any OpenTelemetry SDK. The returned function flushes
+// spans still buffered.
+func setupTracing(ctx context.Context, endpoint string) (func(), error) {
+	if endpoint == "" {
+		return func() {}, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "k8s-watcher")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		provider.Shutdown(ctx)
+	}, nil
+}
+
+// tracingTransport traces backend requests and passes the trace context on
+// in traceparent headers, so the backend's spans join the same trace
+func tracingTransport(transport http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return req.Method + " " + req.URL.Path
+		}),
+	)
+}
+
+// pendingTrace is the event span of an item waiting in a queue. Queue items
+// are deduplicated by value, so the span can't travel in the item itself;
+// the first event for a key starts the trace, later ones until the item is
+// picked up join it.
+type pendingTrace struct {
+	spanContext trace.SpanContext
+	queued      time.Time
+}
+
+func itemAttributes(kind string, item workQueueItem) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("kind", kind),
+		attribute.String("namespace", item.namespace),
+		attribute.String("name", item.name),
+		attribute.String("operation", item.operation),
+	}
+}
+
+// enqueue adds an item to a queue, tracing the event that caused it
+func (w *ResourceWatcher) enqueue(queue workqueue.RateLimitingInterface, kind string, item workQueueItem) {
+	w.enqueueAfter(queue, kind, item, 0)
+}
+
+// enqueueAfter adds an item to a queue once delay has passed. Items are
+// deduplicated, so events for the same key within the delay cause one sync.
+func (w *ResourceWatcher) enqueueAfter(queue workqueue.RateLimitingInterface, kind string, item workQueueItem, delay time.Duration) {
+	ctx := context.Background()
+	w.pendingTracesMu.Lock()
+	if pending, ok := w.pendingTraces[kind+"/"+item.key]; ok {
+		ctx = trace.ContextWithSpanContext(ctx, pending.spanContext)
+	}
+	w.pendingTracesMu.Unlock()
+
+	ctx, span := tracer.Start(ctx, kind+" event",
+		trace.WithAttributes(itemAttributes(kind, item)...))
+	w.rememberTrace(ctx, kind, item)
+	queue.AddAfter(item, delay)
+	span.End()
+}
+
+func (w *ResourceWatcher) rememberTrace(ctx context.Context, kind string, item workQueueItem) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return
+	}
+
+	w.pendingTracesMu.Lock()
+	defer w.pendingTracesMu.Unlock()
+	if _, ok := w.pendingTraces[kind+"/"+item.key]; !ok {
+		w.pendingTraces[kind+"/"+item.key] = pendingTrace{spanContext: spanContext, queued: time.Now()}
+	}
+}
+
+// dequeued returns a context continuing the trace of an item just taken
+// from its queue, after recording the time it waited there
+func (w *ResourceWatcher) dequeued(ctx context.Context, kind string, item workQueueItem) context.Context {
+	w.pendingTracesMu.Lock()
+	pending, ok := w.pendingTraces[kind+"/"+item.key]
+	delete(w.pendingTraces, kind+"/"+item.key)
+	w.pendingTracesMu.Unlock()
+	if !ok {
+		return ctx
+	}
+
+	ctx = trace.ContextWithSpanContext(ctx, pending.spanContext)
+	_, span := tracer.Start(ctx, kind+" queued",
+		trace.WithTimestamp(pending.queued),
+		trace.WithAttributes(itemAttributes(kind, item)...))
+	span.End()
+	return ctx
+}
+
+// startSync starts the span of syncing a single item
+func (w *ResourceWatcher) startSync(ctx context.Context, kind string, item workQueueItem) (context.Context, trace.Span) {
+	ctx = w.dequeued(ctx, kind, item)
+	return tracer.Start(ctx, kind+" sync", trace.WithAttributes(itemAttributes(kind, item)...))
+}
+
+// endSpan ends a span, marking it failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
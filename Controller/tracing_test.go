@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	spanExporter     *tracetest.InMemoryExporter
+	spanExporterOnce sync.Once
+)
+
+// recordSpans sends spans to an in-memory exporter. The package tracer stays
+// bound to the first provider set, so all tests share one.
+func recordSpans() *tracetest.InMemoryExporter {
+	spanExporterOnce.Do(func() {
+		spanExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+func TestBatchSpans(t *testing.T) {
+	exporter := recordSpans()
+	_, server := newFakeBackend(t, "ingress", "service", "node")
+	w, _, _ := newTestWatcher(t, NewRESTSink(server.URL, "test", http.DefaultClient), testConfig(0))
+
+	w.serviceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"web", "db"} {
+		w.serviceStore.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}})
+		w.serviceQueue.Add(workQueueItem{key: "default/" + name, namespace: "default", name: name, operation: "update"})
+	}
+
+	if !w.processNextBatch(context.Background(), w.serviceQueue, kindService) {
+		t.Fatal("processNextBatch() = false")
+	}
+
+	spans := exporter.GetSpans()
+	byName := make(map[string][]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+
+	if got := len(byName["cache get service"]); got != 2 {
+		t.Errorf("%d cache read spans, want 2", got)
+	}
+	for _, span := range byName["cache get service"] {
+		if !hasAttribute(span.Attributes, attribute.Bool("found", true)) {
+			t.Errorf("cache read span attributes = %v, want found", span.Attributes)
+		}
+	}
+
+	batches := byName["service batch sync"]
+	lookups := byName["find service ids"]
+	if len(batches) != 1 || len(lookups) != 1 {
+		t.Fatalf("%d batch spans and %d id lookup spans, want one of each", len(batches), len(lookups))
+	}
+	if lookups[0].Parent.SpanID() != batches[0].SpanContext.SpanID() {
+		t.Error("id lookup span isn't a child of the batch span")
+	}
+	if !hasAttribute(lookups[0].Attributes, attribute.Int("count", 2)) {
+		t.Errorf("id lookup span attributes = %v, want count 2", lookups[0].Attributes)
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attributes {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const supportCalendarDateFormat = "2006-01-02"
+
+// defaultSupportCalendar maps Kubernetes minor versions to the date upstream
+// stops shipping patch releases for them. Newer releases can be added without
+// a rebuild through SUPPORT_CALENDAR_FILE.
+var defaultSupportCalendar = map[string]string{
+	"1.19": "2021-10-28",
+	"1.20": "2022-02-28",
+	"1.21": "2022-06-28",
+	"1.22": "2022-10-28",
+	"1.23": "2023-02-28",
+	"1.24": "2023-07-28",
+	"1.25": "2023-10-28",
+	"1.26": "2024-02-28",
+	"1.27": "2024-06-28",
+	"1.28": "2024-10-28",
+	"1.29": "2025-02-28",
+	"1.30": "2025-06-28",
+	"1.31": "2025-10-28",
+	"1.32": "2026-02-28",
+	"1.33": "2026-06-28",
+	"1.34": "2026-10-27",
+	"1.35": "2027-02-28",
+}
+
+type NodeVersionFinding struct {
+	NodeName       string `json:"nodeName"`
+	KubeletVersion string `json:"kubeletVersion"`
+	// MinorsBehind is negative when the kubelet is newer than the API server
+	MinorsBehind  int    `json:"minorsBehind"`
+	SkewViolation bool   `json:"skewViolation"`
+	PastEndOfLife bool   `json:"pastEndOfLife"`
+	Message       string `json:"message"`
+}
+
+type VersionAnalysis struct {
+	APIServerVersion       string               `json:"apiServerVersion"`
+	APIServerEndOfLife     string               `json:"apiServerEndOfLife,omitempty"`
+	APIServerPastEndOfLife bool                 `json:"apiServerPastEndOfLife"`
+	MaxKubeletSkew         int                  `json:"maxKubeletSkew"`
+	NodeFindings           []NodeVersionFinding `json:"nodeFindings"`
+	Warnings               []string             `json:"warnings"`
+}
+
+// loadSupportCalendar returns the built-in calendar with any entries from path
+// layered on top. The file is a JSON object of "1.xx": "YYYY-MM-DD".
+func loadSupportCalendar(path string) (map[string]time.Time, error) {
+	entries := make(map[string]string, len(defaultSupportCalendar))
+	for minor, date := range defaultSupportCalendar {
+		entries[minor] = date
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read support calendar: %v", err)
+		}
+
+		overrides := make(map[string]string)
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("failed to parse support calendar: %v", err)
+		}
+		for minor, date := range overrides {
+			entries[minor] = date
+		}
+	}
+
+	calendar := make(map[string]time.Time, len(entries))
+	for minor, date := range entries {
+		t, err := time.Parse(supportCalendarDateFormat, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of life date %q for %s: %v", date, minor, err)
+		}
+		calendar[minor] = t
+	}
+
+	return calendar, nil
+}
+
+// maxKubeletSkew is the number of minors a kubelet may trail the API server:
+// two until 1.28 extended it to three
+func maxKubeletSkew(apiServerMinor int) int {
+	if apiServerMinor >= 28 {
+		return 3
+	}
+	return 2
+}
+
+// analyzeVersions interprets the raw versions gathered for ClusterInfo against
+// the upstream version skew policy and support calendar
+func analyzeVersions(apiServerVersion string, nodes []corev1.Node, calendar map[string]time.Time, now time.Time) (*VersionAnalysis, error) {
+	major, minor, err := parseMinorVersion(apiServerVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &VersionAnalysis{
+		APIServerVersion: apiServerVersion,
+		MaxKubeletSkew:   maxKubeletSkew(minor),
+		NodeFindings:     []NodeVersionFinding{},
+		Warnings:         []string{},
+	}
+
+	apiServerMinor := fmt.Sprintf("%d.%d", major, minor)
+	if eol, ok := calendar[apiServerMinor]; ok {
+		analysis.APIServerEndOfLife = eol.Format(supportCalendarDateFormat)
+		if now.After(eol) {
+			analysis.APIServerPastEndOfLife = true
+			analysis.Warnings = append(analysis.Warnings,
+				fmt.Sprintf("API server %s is past upstream end of life (%s)", apiServerMinor, analysis.APIServerEndOfLife))
+		}
+	} else {
+		analysis.Warnings = append(analysis.Warnings,
+			fmt.Sprintf("API server %s is not in the support calendar", apiServerMinor))
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		kubeletVersion := node.Status.NodeInfo.KubeletVersion
+
+		kubeletMajor, kubeletMinor, err := parseMinorVersion(kubeletVersion)
+		if err != nil {
+			analysis.Warnings = append(analysis.Warnings,
+				fmt.Sprintf("node %s: %v", node.Name, err))
+			continue
+		}
+
+		finding := NodeVersionFinding{
+			NodeName:       node.Name,
+			KubeletVersion: kubeletVersion,
+			MinorsBehind:   minor - kubeletMinor,
+		}
+		if kubeletMajor != major {
+			finding.SkewViolation = true
+			finding.Message = "kubelet major version differs from the API server"
+		} else if finding.MinorsBehind < 0 {
+			finding.SkewViolation = true
+			finding.Message = "kubelet is newer than the API server"
+		} else if finding.MinorsBehind > analysis.MaxKubeletSkew {
+			finding.SkewViolation = true
+			finding.Message = fmt.Sprintf("kubelet is %d minors behind the API server, more than the supported %d",
+				finding.MinorsBehind, analysis.MaxKubeletSkew)
+		} else if finding.MinorsBehind > 1 {
+			finding.Message = fmt.Sprintf("kubelet is %d minors behind the API server", finding.MinorsBehind)
+		}
+
+		if eol, ok := calendar[fmt.Sprintf("%d.%d", kubeletMajor, kubeletMinor)]; ok && now.After(eol) {
+			finding.PastEndOfLife = true
+			if finding.Message == "" {
+				finding.Message = "kubelet version is past upstream end of life"
+			}
+		}
+
+		// only report nodes that need attention
+		if finding.Message != "" {
+			analysis.NodeFindings = append(analysis.NodeFindings, finding)
+		}
+	}
+
+	sort.Slice(analysis.NodeFindings, func(i, j int) bool {
+		return analysis.NodeFindings[i].NodeName < analysis.NodeFindings[j].NodeName
+	})
+
+	if len(analysis.NodeFindings) > 0 {
+		violations := 0
+		for _, finding := range analysis.NodeFindings {
+			if finding.SkewViolation {
+				violations++
+			}
+		}
+		if violations > 0 {
+			analysis.Warnings = append(analysis.Warnings,
+				fmt.Sprintf("%d nodes violate the kubelet version skew policy", violations))
+		}
+	}
+
+	return analysis, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaxKubeletSkew(t *testing.T) {
+	tests := []struct {
+		minor int
+		want  int
+	}{
+		{minor: 25, want: 2},
+		{minor: 27, want: 2},
+		{minor: 28, want: 3},
+		{minor: 32, want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := maxKubeletSkew(tt.minor); got != tt.want {
+			t.Errorf("maxKubeletSkew(%d) = %d, want %d", tt.minor, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeVersions(t *testing.T) {
+	date := func(s string) time.Time {
+		t, _ := time.Parse(supportCalendarDateFormat, s)
+		return t
+	}
+	calendar := map[string]time.Time{
+		"1.26": date("2024-02-28"),
+		"1.27": date("2024-06-28"),
+		"1.28": date("2024-10-28"),
+		"1.29": date("2025-02-28"),
+		"1.30": date("2025-06-28"),
+	}
+	now := date("2024-08-01")
+	node := func(name, kubeletVersion string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KubeletVersion: kubeletVersion}},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		apiServerVersion string
+		nodes            []corev1.Node
+		wantEndOfLife    string
+		wantPastEOL      bool
+		wantSkew         int
+		wantFindings     []NodeVersionFinding
+		wantWarnings     []string
+	}{
+		{
+			name:             "supported and in sync",
+			apiServerVersion: "v1.29.4",
+			nodes:            []corev1.Node{node("a", "v1.29.4"), node("b", "v1.28.9")},
+			wantEndOfLife:    "2025-02-28",
+			wantSkew:         3,
+			wantFindings:     []NodeVersionFinding{},
+			wantWarnings:     []string{},
+		},
+		{
+			name:             "within the skew but worth a look",
+			apiServerVersion: "v1.30.1",
+			nodes:            []corev1.Node{node("a", "v1.28.9")},
+			wantEndOfLife:    "2025-06-28",
+			wantSkew:         3,
+			wantFindings: []NodeVersionFinding{
+				{NodeName: "a", KubeletVersion: "v1.28.9", MinorsBehind: 2, Message: "kubelet is 2 minors behind the API server"},
+			},
+			wantWarnings: []string{},
+		},
+		{
+			name:             "skew violations sorted by node",
+			apiServerVersion: "v1.27.3",
+			nodes:            []corev1.Node{node("c", "v1.28.1"), node("b", "v1.24.0"), node("a", "v2.27.0")},
+			wantEndOfLife:    "2024-06-28",
+			wantPastEOL:      true,
+			wantSkew:         2,
+			wantFindings: []NodeVersionFinding{
+				{NodeName: "a", KubeletVersion: "v2.27.0", MinorsBehind: 0, SkewViolation: true, Message: "kubelet major version differs from the API server"},
+				{NodeName: "b", KubeletVersion: "v1.24.0", MinorsBehind: 3, SkewViolation: true, Message: "kubelet is 3 minors behind the API server, more than the supported 2"},
+				{NodeName: "c", KubeletVersion: "v1.28.1", MinorsBehind: -1, SkewViolation: true, Message: "kubelet is newer than the API server"},
+			},
+			wantWarnings: []string{
+				"API server 1.27 is past upstream end of life (2024-06-28)",
+				"3 nodes violate the kubelet version skew policy",
+			},
+		},
+		{
+			name:             "kubelet past end of life",
+			apiServerVersion: "v1.27.3",
+			nodes:            []corev1.Node{node("a", "v1.26.5")},
+			wantEndOfLife:    "2024-06-28",
+			wantPastEOL:      true,
+			wantSkew:         2,
+			wantFindings: []NodeVersionFinding{
+				{NodeName: "a", KubeletVersion: "v1.26.5", MinorsBehind: 1, PastEndOfLife: true, Message: "kubelet version is past upstream end of life"},
+			},
+			wantWarnings: []string{"API server 1.27 is past upstream end of life (2024-06-28)"},
+		},
+		{
+			name:             "managed distribution suffixes",
+			apiServerVersion: "v1.29.8-eks-a737599",
+			nodes:            []corev1.Node{node("a", "v1.29.8-eks-a737599")},
+			wantEndOfLife:    "2025-02-28",
+			wantSkew:         3,
+			wantFindings:     []NodeVersionFinding{},
+			wantWarnings:     []string{},
+		},
+		{
+			name:             "API server not in the calendar",
+			apiServerVersion: "v1.31.0",
+			wantSkew:         3,
+			wantFindings:     []NodeVersionFinding{},
+			wantWarnings:     []string{"API server 1.31 is not in the support calendar"},
+		},
+		{
+			name:             "unparsable kubelet version",
+			apiServerVersion: "v1.29.4",
+			nodes:            []corev1.Node{node("a", "")},
+			wantEndOfLife:    "2025-02-28",
+			wantSkew:         3,
+			wantFindings:     []NodeVersionFinding{},
+			wantWarnings:     []string{`node a: invalid version ""`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, err := analyzeVersions(tt.apiServerVersion, tt.nodes, calendar, now)
+			if err != nil {
+				t.Fatalf("analyzeVersions() error = %v", err)
+			}
+			if analysis.APIServerEndOfLife != tt.wantEndOfLife || analysis.APIServerPastEndOfLife != tt.wantPastEOL {
+				t.Errorf("API server end of life = %q past %v, want %q past %v",
+					analysis.APIServerEndOfLife, analysis.APIServerPastEndOfLife, tt.wantEndOfLife, tt.wantPastEOL)
+			}
+			if analysis.MaxKubeletSkew != tt.wantSkew {
+				t.Errorf("MaxKubeletSkew = %d, want %d", analysis.MaxKubeletSkew, tt.wantSkew)
+			}
+			if !reflect.DeepEqual(analysis.NodeFindings, tt.wantFindings) {
+				t.Errorf("NodeFindings = %+v, want %+v", analysis.NodeFindings, tt.wantFindings)
+			}
+			if !reflect.DeepEqual(analysis.Warnings, tt.wantWarnings) {
+				t.Errorf("Warnings = %q, want %q", analysis.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+
+	if _, err := analyzeVersions("unknown", nil, calendar, now); err == nil {
+		t.Error("analyzeVersions() with an unparsable API server version succeeded")
+	}
+}
+
+func TestLoadSupportCalendar(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "built in",
+			want: map[string]string{"1.28": "2024-10-28", "1.35": "2027-02-28"},
+		},
+		{
+			name: "overrides and additions",
+			path: write("override.json", `{"1.28": "2024-11-28", "1.40": "2029-06-28"}`),
+			want: map[string]string{"1.28": "2024-11-28", "1.35": "2027-02-28", "1.40": "2029-06-28"},
+		},
+		{
+			name:    "invalid date",
+			path:    write("invalid-date.json", `{"1.40": "June 2029"}`),
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			path:    write("invalid.json", `1.40: 2029-06-28`),
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			path:    filepath.Join(dir, "missing.json"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar, err := loadSupportCalendar(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadSupportCalendar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for minor, want := range tt.want {
+				if got := calendar[minor].Format(supportCalendarDateFormat); got != want {
+					t.Errorf("calendar[%s] = %s, want %s", minor, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// newTestWatcher builds a watcher on a fake clientset holding objects,
+// syncing to sink. started receives a value for every watch an informer
+// opens, since the fake clientset drops events from before its watch.
+func newTestWatcher(t *testing.T, sink Sink, config *Config, objects ...runtime.Object) (*ResourceWatcher, *fake.Clientset, chan struct{}) {
+	t.Helper()
+
+	objects = append(objects, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: "cluster-uid"},
+	})
+	clientset := fake.NewSimpleClientset(objects...)
+	started := make(chan struct{}, 16)
+	clientset.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher, err := clientset.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		started <- struct{}{}
+		return true, watcher, nil
+	})
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		crdGVR:        "CustomResourceDefinitionList",
+		apiServiceGVR: "APIServiceList",
+	})
+
+	return &ResourceWatcher{
+		clientset:          clientset,
+		dynamicClient:      dynamicClient,
+		clusterName:        "test",
+		sink:               sink,
+		breaker:            NewCircuitBreaker(http.DefaultTransport, 5, time.Minute),
+		config:             config,
+		ingressQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ingresses"),
+		serviceQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "services"),
+		nodeQueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "nodes"),
+		endpointCounts:     make(map[string]EndpointCounts),
+		pendingTraces:      make(map[string]pendingTrace),
+		syncFailures:       make(map[string]bool),
+		clusterInfoTrigger: make(chan string, 1),
+	}, clientset, started
+}
+
+func testConfig(batchWindow time.Duration) *Config {
+	return &Config{
+		MetricsAddr:                "127.0.0.1:0",
+		ClusterInfoInterval:        time.Hour,
+		ClusterInfoDebounce:        time.Hour,
+		ClusterVersionPollInterval: time.Hour,
+		BatchWindow:                batchWindow,
+		BatchMaxSize:               500,
+	}
+}
+
+// eventually polls check until it passes or a few seconds have gone by
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchWindow time.Duration
+	}{
+		{name: "single items", batchWindow: 0},
+		{name: "batches", batchWindow: 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "web.example.com"}},
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+			}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+			sink := NewMemorySink()
+			w, clientset, started := newTestWatcher(t, sink, testConfig(tt.batchWindow), ingress, service, node)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- w.WatchResources(ctx) }()
+			defer func() {
+				cancel()
+				if err := <-done; err != nil {
+					t.Errorf("WatchResources() error = %v", err)
+				}
+			}()
+
+			eventually(t, "initial sync", func() bool {
+				ingresses, _ := sink.ListIngresses(ctx)
+				services, _ := sink.ListServices(ctx)
+				nodes, _ := sink.ListNodes(ctx)
+				return len(ingresses) == 1 && len(services) == 1 && len(nodes) == 1
+			})
+			if info := sink.ClusterInfo(); info == nil || info.ClusterUID != "cluster-uid" {
+				t.Errorf("cluster info = %+v, want cluster uid %q", info, "cluster-uid")
+			}
+
+			// endpointslices, ingresses, services, nodes
+			for i := 0; i < 4; i++ {
+				<-started
+			}
+
+			added := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}
+			if _, err := clientset.CoreV1().Services("default").Create(ctx, added, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := clientset.NetworkingV1().Ingresses("default").Delete(ctx, "web", metav1.DeleteOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			eventually(t, "service added and ingress deleted", func() bool {
+				ingresses, _ := sink.ListIngresses(ctx)
+				services, _ := sink.ListServices(ctx)
+				return len(ingresses) == 0 && len(services) == 2
+			})
+
+			slice := &discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      "web-abc",
+					Labels:    map[string]string{discoveryv1.LabelServiceName: "web"},
+				},
+				Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}}},
+			}
+			if _, err := clientset.DiscoveryV1().EndpointSlices("default").Create(ctx, slice, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			eventually(t, "endpoint counts", func() bool {
+				services, _ := sink.ListServices(ctx)
+				for _, payload := range services {
+					if payload.ServiceName == "web" {
+						return payload.ReadyEndpoints == 1
+					}
+				}
+				return false
+			})
+		})
+	}
+}
+
+func TestDeleteHandlersUnwrapTombstones(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid-1", ResourceVersion: "42"}
+	ingress := &networkingv1.Ingress{ObjectMeta: objectMeta}
+	service := &corev1.Service{ObjectMeta: objectMeta}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", UID: "uid-1", ResourceVersion: "42"}}
+	ingressItem := workQueueItem{key: "default/web", namespace: "default", name: "web", operation: "delete", uid: "uid-1", resourceVersion: "42"}
+	serviceItem := ingressItem
+	nodeItem := workQueueItem{key: "node-a", name: "node-a", operation: "delete", uid: "uid-1", resourceVersion: "42"}
+
+	tests := []struct {
+		name     string
+		handle   func(w *ResourceWatcher, obj interface{})
+		queue    func(w *ResourceWatcher) workqueue.RateLimitingInterface
+		obj      interface{}
+		wantItem *workQueueItem
+	}{
+		{name: "ingress", handle: (*ResourceWatcher).handleIngressDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.ingressQueue }, obj: ingress, wantItem: &ingressItem},
+		{name: "ingress tombstone", handle: (*ResourceWatcher).handleIngressDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.ingressQueue }, obj: cache.DeletedFinalStateUnknown{Key: "default/web", Obj: ingress}, wantItem: &ingressItem},
+		{name: "ingress unexpected type", handle: (*ResourceWatcher).handleIngressDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.ingressQueue }, obj: service},
+		{name: "service", handle: (*ResourceWatcher).handleServiceDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.serviceQueue }, obj: service, wantItem: &serviceItem},
+		{name: "service tombstone", handle: (*ResourceWatcher).handleServiceDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.serviceQueue }, obj: cache.DeletedFinalStateUnknown{Key: "default/web", Obj: service}, wantItem: &serviceItem},
+		{name: "service tombstone of an unexpected type", handle: (*ResourceWatcher).handleServiceDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.serviceQueue }, obj: cache.DeletedFinalStateUnknown{Key: "default/web", Obj: ingress}},
+		{name: "node tombstone", handle: (*ResourceWatcher).handleNodeDelete, queue: func(w *ResourceWatcher) workqueue.RateLimitingInterface { return w.nodeQueue }, obj: cache.DeletedFinalStateUnknown{Key: "node-a", Obj: node}, wantItem: &nodeItem},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _, _ := newTestWatcher(t, nil, testConfig(0))
+			queue := tt.queue(w)
+
+			tt.handle(w, tt.obj)
+
+			if tt.wantItem == nil {
+				if queue.Len() != 0 {
+					t.Errorf("queue length = %d, want 0", queue.Len())
+				}
+				return
+			}
+			if queue.Len() != 1 {
+				t.Fatalf("queue length = %d, want 1", queue.Len())
+			}
+			item, _ := queue.Get()
+			if item != *tt.wantItem {
+				t.Errorf("queued %+v, want %+v", item, *tt.wantItem)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	cloudEventsContentType = "application/cloudevents+json"
+	cloudEventTypePrefix   = "com.blacktoaster.k8s-tracker."
+
+	webhookAttempts = 4
+)
+
+// Webhook is one entry of WEBHOOKS_FILE. Empty Kinds or Namespaces match
+// everything; Namespaces are glob patterns and never match cluster scoped
+// kinds (node and cluster).
+type Webhook struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Kinds      []string `json:"kinds"`
+	Namespaces []string `json:"namespaces"`
+	// SecretEnv names the environment variable holding the HMAC key used to
+	// sign requests, which are unsigned without one
+	SecretEnv string `json:"secretEnv"`
+
+	secret []byte
+}
+
+func (h *Webhook) matches(change Change) bool {
+	if len(h.Kinds) > 0 && !contains(h.Kinds, change.Kind) {
+		return false
+	}
+	if len(h.Namespaces) == 0 {
+		return true
+	}
+	if change.Namespace == "" {
+		return false
+	}
+	for _, pattern := range h.Namespaces {
+		if ok, _ := path.Match(pattern, change.Namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWebhooks reads the JSON list of webhooks from file
+func loadWebhooks(file string) ([]Webhook, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %v", err)
+	}
+
+	var webhooks []Webhook
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks: %v", err)
+	}
+
+	for i := range webhooks {
+		hook := &webhooks[i]
+		if hook.URL == "" {
+			return nil, fmt.Errorf("webhook %d has no url", i)
+		}
+		if hook.Name == "" {
+			hook.Name = hook.URL
+		}
+		for _, pattern := range hook.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("webhook %s has an invalid namespace pattern %q", hook.Name, pattern)
+			}
+		}
+		if hook.SecretEnv != "" {
+			secret := os.Getenv(hook.SecretEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("webhook %s: %s is not set", hook.Name, hook.SecretEnv)
+			}
+			hook.secret = []byte(secret)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// CloudEvent is a CloudEvents 1.0 event in structured JSON mode
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            ChangeEvent `json:"data"`
+}
+
+// WebhookSink POSTs a CloudEvent for every change to each webhook whose
+// filters match it. A failed delivery is retried with backoff a few times and
+// then fails the item back to the workqueue, which retries it with every
+// webhook again. Receivers must tolerate duplicates; the event ID is derived
+// from the change, so every delivery of the same change carries the same one.
+//
+// Requests with a secret carry X-Tracker-Timestamp and an X-Tracker-Signature
+// of "sha256=" plus the hex HMAC-SHA256 of "<timestamp>.<body>".
+type WebhookSink struct {
+	webhooks    []Webhook
+	clusterName string
+	httpClient  *http.Client
+	events      *eventTracker
+	// first wait between delivery attempts, doubled after each
+	backoff time.Duration
+
+	// cluster info is resent periodically, only changes are forwarded
+	clusterInfoMu   sync.Mutex
+	lastClusterInfo []byte
+}
+
+func NewWebhookSink(webhooks []Webhook, clusterName string) *WebhookSink {
+	return &WebhookSink{
+		webhooks:    webhooks,
+		clusterName: clusterName,
+		// the receivers aren't the backend, so they don't share its client
+		// and circuit breaker
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		events:     newEventTracker(),
+		backoff:    time.Second,
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) cloudEvent(change Change) CloudEvent {
+	event := s.events.newEvent(s.clusterName, change)
+	return CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              cloudEventID(change),
+		Source:          "/k8s-tracker/" + s.clusterName,
+		Type:            cloudEventTypePrefix + change.Kind + "." + event.Type,
+		Subject:         change.Key(),
+		Time:            event.Time,
+		DataContentType: "application/json",
+		Data:            event,
+	}
+}
+
+// cloudEventID identifies a change by its object, operation, payload and the
+// UID and resourceVersion it was read from. Retries from the workqueue, the
+// outbox or a restart reuse the ID, while a later change gets a new one even
+// when it brings the object back to an earlier payload or deletes a recreated
+// object of the same name. The payload stays part of it since endpoint counts
+// change a service payload without a new resourceVersion.
+func cloudEventID(change Change) string {
+	payload, _ := json.Marshal(change.Payload)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00", change.Kind, change.Key(), change.Operation, change.UID, change.ResourceVersion)
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (s *WebhookSink) send(ctx context.Context, change Change) error {
+	errs, _ := s.ApplyBatch(ctx, change.Kind, []Change{change})
+	return errs[0]
+}
+
+// ApplyBatch delivers a batch to every webhook in parallel, each receiving its
+// matching changes in order. A webhook that is still unavailable after its
+// retries is skipped for the rest of the batch, so a receiver that is down
+// costs the batch one round of backoff rather than one per change, and
+// doesn't hold up the others.
+func (s *WebhookSink) ApplyBatch(ctx context.Context, kind string, changes []Change) ([]error, error) {
+	errs := make([]error, len(changes))
+	bodies := make([][]byte, len(changes))
+	for i, change := range changes {
+		body, err := json.Marshal(s.cloudEvent(change))
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal %s event: %v", change.Kind, err)
+			continue
+		}
+		bodies[i] = body
+	}
+
+	var mu sync.Mutex
+	failed := make([][]string, len(changes))
+	var wg sync.WaitGroup
+	for h := range s.webhooks {
+		hook := &s.webhooks[h]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unavailable := false
+			for i, change := range changes {
+				if bodies[i] == nil || !hook.matches(change) {
+					continue
+				}
+				if !unavailable {
+					retry, err := s.deliver(ctx, hook, bodies[i])
+					if err == nil {
+						continue
+					}
+					slog.Warn("Webhook delivery failed", "webhook", hook.Name, "kind", change.Kind, "namespace", change.Namespace, "name", change.Name, "error", err)
+					unavailable = retry
+				}
+				mu.Lock()
+				failed[i] = append(failed[i], hook.Name)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, change := range changes {
+		if bodies[i] == nil {
+			continue
+		}
+		if len(failed[i]) > 0 {
+			sort.Strings(failed[i])
+			errs[i] = fmt.Errorf("delivery failed for webhooks %v", failed[i])
+			continue
+		}
+		s.events.sent(change)
+	}
+	return errs, nil
+}
+
+// deliver POSTs body to one webhook, retrying server errors, rate limiting
+// and transport errors with exponential backoff. It reports whether the last
+// failure was one of those, meaning the webhook itself is unavailable.
+func (s *WebhookSink) deliver(ctx context.Context, hook *Webhook, body []byte) (bool, error) {
+	backoff := s.backoff
+	var retry bool
+	var err error
+
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		retry, err = s.post(ctx, hook, body)
+		if err == nil || !retry || attempt == webhookAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return retry, err
+}
+
+func (s *WebhookSink) post(ctx context.Context, hook *Webhook, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", cloudEventsContentType)
+
+	if hook.secret != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, hook.secret)
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		req.Header.Set("X-Tracker-Timestamp", timestamp)
+		req.Header.Set("X-Tracker-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
+
+func (s *WebhookSink) SendClusterInfo(ctx context.Context, info *ClusterInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster info: %v", err)
+	}
+
+	s.clusterInfoMu.Lock()
+	unchanged := bytes.Equal(data, s.lastClusterInfo)
+	s.clusterInfoMu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	if err := s.send(ctx, Change{Kind: kindCluster, Operation: operationUpsert, Name: s.clusterName, Payload: info}); err != nil {
+		return err
+	}
+
+	s.clusterInfoMu.Lock()
+	s.lastClusterInfo = data
+	s.clusterInfoMu.Unlock()
+	return nil
+}
+
+func (s *WebhookSink) UpsertIngress(ctx context.Context, payload IngressPayload) error {
+	return s.send(ctx, changeForIngress(payload))
+}
+
+func (s *WebhookSink) DeleteIngress(ctx context.Context, namespace, name string) error {
+	return s.send(ctx, Change{Kind: kindIngress, Operation: operationDelete, Namespace: namespace, Name: name})
+}
+
+func (s *WebhookSink) ListIngresses(ctx context.Context) ([]IngressPayload, error) {
+	return nil, fmt.Errorf("the webhook sink can't list ingresses")
+}
+
+func (s *WebhookSink) UpsertService(ctx context.Context, payload ServicePayload) error {
+	return s.send(ctx, changeForService(payload))
+}
+
+func (s *WebhookSink) DeleteService(ctx context.Context, namespace, name string) error {
+	return s.send(ctx, Change{Kind: kindService, Operation: operationDelete, Namespace: namespace, Name: name})
+}
+
+func (s *WebhookSink) ListServices(ctx context.Context) ([]ServicePayload, error) {
+	return nil, fmt.Errorf("the webhook sink can't list services")
+}
+
+func (s *WebhookSink) UpsertNode(ctx context.Context, payload NodePayload) error {
+	return s.send(ctx, changeForNode(payload))
+}
+
+func (s *WebhookSink) DeleteNode(ctx context.Context, name string) error {
+	return s.send(ctx, Change{Kind: kindNode, Operation: operationDelete, Name: name})
+}
+
+func (s *WebhookSink) ListNodes(ctx context.Context) ([]NodePayload, error) {
+	return nil, fmt.Errorf("the webhook sink can't list nodes")
+}
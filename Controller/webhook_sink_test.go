@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// webhookReceiver records the CloudEvent IDs it is sent, answering status
+type webhookReceiver struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *webhookReceiver) serve(t *testing.T, status int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event CloudEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			t.Errorf("decoding event: %v", err)
+		}
+		r.mu.Lock()
+		r.ids = append(r.ids, event.ID)
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookSinkApplyBatch(t *testing.T) {
+	var healthy, down webhookReceiver
+	sink := NewWebhookSink([]Webhook{
+		{Name: "healthy", URL: healthy.serve(t, http.StatusOK).URL},
+		{Name: "down", URL: down.serve(t, http.StatusServiceUnavailable).URL},
+	}, "test")
+	sink.backoff = time.Millisecond
+
+	changes := []Change{
+		changeForService(ServicePayload{Namespace: "default", ServiceName: "a"}),
+		changeForService(ServicePayload{Namespace: "default", ServiceName: "b"}),
+		changeForService(ServicePayload{Namespace: "default", ServiceName: "c"}),
+	}
+	errs, err := sink.ApplyBatch(context.Background(), kindService, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, err := range errs {
+		if err == nil || !strings.Contains(err.Error(), "down") || strings.Contains(err.Error(), "healthy") {
+			t.Errorf("change %d error = %v, want a failure of only the down webhook", i, err)
+		}
+	}
+	if len(healthy.ids) != len(changes) {
+		t.Errorf("healthy webhook got %d events, want %d", len(healthy.ids), len(changes))
+	}
+	// the down webhook is given up on after the first change's retries
+	if len(down.ids) != webhookAttempts {
+		t.Errorf("down webhook got %d requests, want %d", len(down.ids), webhookAttempts)
+	}
+	for _, id := range down.ids {
+		if id != down.ids[0] {
+			t.Errorf("retries carried IDs %v, want the same one", down.ids)
+			break
+		}
+	}
+
+	// the workqueue's retry of the batch resends the same events
+	sink.ApplyBatch(context.Background(), kindService, changes)
+	for i := range changes {
+		if healthy.ids[i] != healthy.ids[len(changes)+i] {
+			t.Errorf("change %d resent as %s, first sent as %s", i, healthy.ids[len(changes)+i], healthy.ids[i])
+		}
+	}
+}
+
+func TestCloudEventID(t *testing.T) {
+	a := ServicePayload{Namespace: "default", ServiceName: "web", ServiceType: "ClusterIP"}
+	b := ServicePayload{Namespace: "default", ServiceName: "web", ServiceType: "NodePort"}
+	upsert := func(payload ServicePayload, uid, resourceVersion string) Change {
+		change := changeForService(payload)
+		change.UID, change.ResourceVersion = uid, resourceVersion
+		return change
+	}
+	remove := func(uid, resourceVersion string) Change {
+		return Change{Kind: kindService, Operation: operationDelete, Namespace: "default", Name: "web", UID: uid, ResourceVersion: resourceVersion}
+	}
+
+	tests := []struct {
+		name    string
+		changes []Change
+	}{
+		{name: "A to B and back to A", changes: []Change{upsert(a, "uid-1", "1"), upsert(b, "uid-1", "2"), upsert(a, "uid-1", "3")}},
+		{name: "delete, recreate, delete", changes: []Change{remove("uid-1", "4"), upsert(a, "uid-2", "5"), remove("uid-2", "6")}},
+		{name: "endpoint counts without a new resourceVersion", changes: []Change{
+			upsert(ServicePayload{Namespace: "default", ServiceName: "web", ReadyEndpoints: 1}, "uid-1", "1"),
+			upsert(ServicePayload{Namespace: "default", ServiceName: "web", ReadyEndpoints: 2}, "uid-1", "1"),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]int)
+			for i, change := range tt.changes {
+				id := cloudEventID(change)
+				if first, ok := seen[id]; ok {
+					t.Errorf("changes %d and %d share the ID %s", first, i, id)
+				}
+				seen[id] = i
+
+				if retry := cloudEventID(change); retry != id {
+					t.Errorf("change %d got %s on retry, first %s", i, retry, id)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookDeleteRecreateDelete(t *testing.T) {
+	var receiver webhookReceiver
+	sink := NewWebhookSink([]Webhook{{Name: "receiver", URL: receiver.serve(t, http.StatusOK).URL}}, "test")
+	w, _, _ := newTestWatcher(t, sink, testConfig(0))
+	w.serviceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	service := func(uid, resourceVersion string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: types.UID(uid), ResourceVersion: resourceVersion}}
+	}
+	sync := func() {
+		t.Helper()
+		if !w.processNextBatch(context.Background(), w.serviceQueue, kindService) {
+			t.Fatal("processNextBatch() = false")
+		}
+	}
+
+	w.handleServiceDelete(service("uid-1", "4"))
+	sync()
+	w.serviceStore.Add(service("uid-2", "5"))
+	w.handleServiceChange(service("uid-2", "5"))
+	sync()
+	w.serviceStore.Delete(service("uid-2", "6"))
+	w.handleServiceDelete(service("uid-2", "6"))
+	sync()
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.ids) != 3 {
+		t.Fatalf("receiver got %d events, want 3", len(receiver.ids))
+	}
+	if receiver.ids[0] == receiver.ids[2] {
+		t.Errorf("both deletes carried the ID %s", receiver.ids[0])
+	}
+}